@@ -15,26 +15,56 @@ type CgroupManager struct {
 
 func NewCgroupManager(path string) *CgroupManager {
 	ins := &CgroupManager{Path: path}
-	ins.resourceItem = []limit.ResourceItem{
+	ins.initResourceItem()
+	return ins
+}
+
+// 从容器信息中反序列化出来的manager没有resourceItem 需要重新初始化
+// 宿主机只有cgroup v2时 所有资源都在同一个资源组目录中
+func (t *CgroupManager) initResourceItem() {
+	if t.resourceItem != nil {
+		return
+	}
+	if limit.IsCgroupV2() {
+		t.resourceItem = []limit.ResourceItem{&limit.UnifiedItem{}}
+		return
+	}
+	t.resourceItem = []limit.ResourceItem{
 		&limit.CpuItem{},
 		&limit.CpusetItem{},
 		&limit.MemoryItem{},
+		&limit.PidsItem{},
+		&limit.BlkioItem{},
+		&limit.HugetlbItem{},
 	}
-	return ins
 }
 
 // 设置cgroup资源限制
 func (t *CgroupManager) Set(res *limit.ResourceConfig) error {
+	t.initResourceItem()
 	for _, subSysIns := range t.resourceItem {
 		if err := subSysIns.CreateLimitFile(t.Path, res); err != nil {
 			return err
 		}
 	}
+	t.Resource = res
+	return nil
+}
+
+// 检查新的资源限制是否可以应用到当前的cgroup上
+func (t *CgroupManager) Check(res *limit.ResourceConfig) error {
+	t.initResourceItem()
+	for _, subSysIns := range t.resourceItem {
+		if err := subSysIns.Check(t.Path, res); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // 将进程pid加入到这个cgroup中
 func (t *CgroupManager) Apply(pid int) error {
+	t.initResourceItem()
 	for _, subSysIns := range t.resourceItem {
 		if err := subSysIns.Apply(pid); err != nil {
 			return err
@@ -45,6 +75,7 @@ func (t *CgroupManager) Apply(pid int) error {
 
 // 释放cgroup
 func (t *CgroupManager) Destroy() error {
+	t.initResourceItem()
 	for _, subSysIns := range t.resourceItem {
 		if err := subSysIns.Remove(); err != nil {
 			return err
@@ -52,3 +83,31 @@ func (t *CgroupManager) Destroy() error {
 	}
 	return nil
 }
+
+// 删除所有控制器中的资源组目录 包括没有设置限制时创建的空目录
+func (t *CgroupManager) Clean() error {
+	t.initResourceItem()
+	for _, subSysIns := range t.resourceItem {
+		if err := limit.RemoveCgroup(subSysIns.GetType(), t.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 资源组当前的使用情况
+func (t *CgroupManager) Stats() (*limit.ResourceStats, error) {
+	t.initResourceItem()
+	stats := &limit.ResourceStats{}
+	for _, subSysIns := range t.resourceItem {
+		if err := subSysIns.Stat(t.Path, stats); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// 资源组中因为超出内存限制被kill的进程数
+func (t *CgroupManager) OomKillCount() (int64, error) {
+	return limit.ReadOomKillCount(t.Path)
+}
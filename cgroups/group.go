@@ -0,0 +1,106 @@
+package cgroups
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/kehaha-5/go-low-level-container/cgroups/limit"
+	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/kehaha-5/go-low-level-container/store"
+	"github.com/pkg/errors"
+)
+
+// 容器默认都放在这个资源组下 方便统一管理
+const DefaultCgroupParent = "mydocker"
+
+const defaultGroupSavename = "config.json"
+
+var defaultGroupSavefilepath string = common.ROOTPATH + "/cgroup/"
+
+// 检查父资源组 必须是cgroup根目录下的相对路径
+func CleanParent(parent string) (string, error) {
+	if parent == "" {
+		return DefaultCgroupParent, nil
+	}
+	cleaned := path.Clean(strings.Trim(parent, "/"))
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("invalid cgroup parent %s", parent)
+	}
+	return cleaned, nil
+}
+
+// 容器的资源组路径 parent/name
+func ContainerPath(parent string, name string) (string, error) {
+	parent, err := CleanParent(parent)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(parent, name), nil
+}
+
+func getGroupSavePath(parent string) string {
+	return path.Join(defaultGroupSavefilepath, parent, defaultGroupSavename)
+}
+
+// 父资源组的manager 会读取之前设置过的组限制
+func NewGroupManager(parent string) (*CgroupManager, error) {
+	parent, err := CleanParent(parent)
+	if err != nil {
+		return nil, err
+	}
+	ins := NewCgroupManager(parent)
+	if _, err := store.ReadJSON(getGroupSavePath(parent), ins); err != nil {
+		return nil, errors.Wrap(err, "fail to read cgroup group config")
+	}
+	ins.Path = parent
+	return ins, nil
+}
+
+// 设置整个组的资源限制 组里所有容器共享这些限制 记录下来以便重启后恢复
+func (t *CgroupManager) SetGroup(res *limit.ResourceConfig) error {
+	if err := t.Check(res); err != nil {
+		return err
+	}
+	if err := t.Set(res); err != nil {
+		return err
+	}
+	return store.WriteJSON(getGroupSavePath(t.Path), t)
+}
+
+// 容器的资源组创建前 把记录的组限制重新写入每一级父资源组
+// 宿主机重启后资源组目录会消失 需要从上到下恢复
+func RestoreGroup(parent string) error {
+	parent, err := CleanParent(parent)
+	if err != nil {
+		return err
+	}
+	current := ""
+	for _, dir := range strings.Split(parent, "/") {
+		current = path.Join(current, dir)
+		group, err := NewGroupManager(current)
+		if err != nil {
+			return err
+		}
+		if group.Resource == nil {
+			continue
+		}
+		if err := group.Set(group.Resource); err != nil {
+			return errors.Wrapf(err, "fail to restore cgroup group %s", current)
+		}
+	}
+	return nil
+}
+
+// 删除父资源组和它下面所有的资源组 以及记录的组限制
+func RemoveGroup(parent string) error {
+	parent, err := CleanParent(parent)
+	if err != nil {
+		return err
+	}
+	if err := NewCgroupManager(parent).Clean(); err != nil {
+		return err
+	}
+	return errors.WithStack(os.RemoveAll(path.Join(defaultGroupSavefilepath, parent)))
+}
@@ -0,0 +1,148 @@
+package limit
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/kehaha-5/go-low-level-container/common"
+	"golang.org/x/sys/unix"
+)
+
+const limitBlkioWeightFilename = "blkio.weight"
+const limitBlkioBfqWeightFilename = "blkio.bfq.weight"
+const limitBlkioReadBpsFilename = "blkio.throttle.read_bps_device"
+const limitBlkioWriteBpsFilename = "blkio.throttle.write_bps_device"
+const limitBlkioReadIopsFilename = "blkio.throttle.read_iops_device"
+const limitBlkioWriteIopsFilename = "blkio.throttle.write_iops_device"
+
+const minBlkioWeight = 10
+const maxBlkioWeight = 1000
+
+// 块设备的读写限制 如 /dev/sda:1mb
+type ThrottleDevice struct {
+	Path  string `json:"path"`
+	Major uint32 `json:"major"`
+	Minor uint32 `json:"minor"`
+	Rate  uint64 `json:"rate"`
+}
+
+func (t *ThrottleDevice) String() string {
+	return fmt.Sprintf("%d:%d %d", t.Major, t.Minor, t.Rate)
+}
+
+// 解析 --device-read-bps /dev/sda:1mb 这样的参数 把设备路径换算成major:minor
+// isBps为true时速率可以带单位 否则是每秒的io次数
+func ParseThrottleDevices(values []string, isBps bool) ([]ThrottleDevice, error) {
+	res := []ThrottleDevice{}
+	for _, value := range values {
+		devicePath, rateStr, ok := strings.Cut(value, ":")
+		if !ok || !strings.HasPrefix(devicePath, "/dev/") {
+			return nil, fmt.Errorf("invalid device limit %s (expected /dev/xxx:rate)", value)
+		}
+		var rate int64
+		var err error
+		if isBps {
+			rate, err = common.ParseSize(rateStr)
+		} else {
+			rate, err = strconv.ParseInt(rateStr, 10, 64)
+		}
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("invalid rate of device limit %s", value)
+		}
+		var st unix.Stat_t
+		if err := unix.Stat(devicePath, &st); err != nil {
+			return nil, fmt.Errorf("fail to stat device %s %v", devicePath, err)
+		}
+		if st.Mode&unix.S_IFMT != unix.S_IFBLK {
+			return nil, fmt.Errorf("%s is not a block device", devicePath)
+		}
+		res = append(res, ThrottleDevice{
+			Path:  devicePath,
+			Major: unix.Major(st.Rdev),
+			Minor: unix.Minor(st.Rdev),
+			Rate:  uint64(rate),
+		})
+	}
+	return res, nil
+}
+
+func checkBlkioWeight(weight uint16) error {
+	if weight != 0 && (weight < minBlkioWeight || weight > maxBlkioWeight) {
+		return fmt.Errorf("invalid blkio weight %d (range is %d-%d)", weight, minBlkioWeight, maxBlkioWeight)
+	}
+	return nil
+}
+
+type BlkioItem struct {
+	cgfilepath string //保存当前资源组root路径
+	isApply    bool
+}
+
+func (*BlkioItem) GetType() string {
+	return "blkio"
+}
+
+func (t *BlkioItem) CreateLimitFile(name string, conf *ResourceConfig) error {
+	if err := checkBlkioWeight(conf.BlkioWeight); err != nil {
+		return err
+	}
+	cgfilepath, err := findAndCreateCgroupFilePath(t.GetType(), name, true)
+	if err != nil {
+		return err
+	}
+	t.cgfilepath = cgfilepath
+	if conf.BlkioWeight != 0 {
+		// 只有CFQ和BFQ调度器支持权重 不同内核的文件名不一样
+		weightFilename := limitBlkioWeightFilename
+		if _, err := os.Stat(path.Join(cgfilepath, weightFilename)); err != nil {
+			weightFilename = limitBlkioBfqWeightFilename
+		}
+		if err = os.WriteFile(path.Join(cgfilepath, weightFilename), []byte(strconv.Itoa(int(conf.BlkioWeight))), 0664); err != nil {
+			return fmt.Errorf("create cg file error %v", err)
+		}
+		t.isApply = true
+	}
+	for filename, devices := range map[string][]ThrottleDevice{
+		limitBlkioReadBpsFilename:   conf.DeviceReadBps,
+		limitBlkioWriteBpsFilename:  conf.DeviceWriteBps,
+		limitBlkioReadIopsFilename:  conf.DeviceReadIops,
+		limitBlkioWriteIopsFilename: conf.DeviceWriteIops,
+	} {
+		// 每次只能写入一个设备
+		for _, device := range devices {
+			if err = os.WriteFile(path.Join(cgfilepath, filename), []byte(device.String()), 0664); err != nil {
+				return fmt.Errorf("create cg file error %v", err)
+			}
+			t.isApply = true
+		}
+	}
+	return nil
+}
+
+func (t *BlkioItem) Check(name string, conf *ResourceConfig) error {
+	return checkBlkioWeight(conf.BlkioWeight)
+}
+
+func (t *BlkioItem) Apply(pid int) error {
+	if t.cgfilepath == "" {
+		return fmt.Errorf("create the limit file before use this pls")
+	}
+	if err := os.WriteFile(path.Join(t.cgfilepath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		return fmt.Errorf("set cgroup proc fail %v type is %s", err, t.GetType())
+	}
+	return nil
+}
+
+func (t *BlkioItem) Stat(name string, stats *ResourceStats) error {
+	return nil
+}
+
+func (t *BlkioItem) Remove() error {
+	if t.cgfilepath == "" {
+		return nil
+	}
+	return os.RemoveAll(t.cgfilepath)
+}
@@ -18,25 +18,41 @@ func (*CpuItem) GetType() string {
 
 func (t *CpuItem) CreateLimitFile(name string, conf *ResourceConfig) error {
 	cgfilepath, err := findAndCreateCgroupFilePath(t.GetType(), name, true)
-	if err == nil {
-		if conf.Cpu != 0 {
-			if err = os.WriteFile(path.Join(cgfilepath, limitCpuFilename), []byte(string(rune(conf.Cpu))), 0664); err == nil {
-				t.cgfilepath = cgfilepath
-				t.isApply = true
-			} else {
-				return fmt.Errorf("create cg file error %v", err)
-			}
+	if err != nil {
+		return err
+	}
+	t.cgfilepath = cgfilepath
+	if conf.Cpu != 0 {
+		if err = os.WriteFile(path.Join(cgfilepath, limitCpuFilename), []byte(strconv.Itoa(conf.Cpu)), 0664); err != nil {
+			return fmt.Errorf("create cg file error %v", err)
 		}
-		t.isApply = false
-		return nil
+		t.isApply = true
+	}
+	quota, period, err := cpuQuotaPeriod(conf)
+	if err != nil {
+		return err
+	}
+	if period != 0 {
+		if err = os.WriteFile(path.Join(cgfilepath, limitCpuPeriodFilename), []byte(strconv.FormatInt(period, 10)), 0664); err != nil {
+			return fmt.Errorf("create cg file error %v", err)
+		}
+		t.isApply = true
+	}
+	if quota != 0 {
+		if err = os.WriteFile(path.Join(cgfilepath, limitCpuQuotaFilename), []byte(strconv.FormatInt(quota, 10)), 0664); err != nil {
+			return fmt.Errorf("create cg file error %v", err)
+		}
+		t.isApply = true
 	}
+	return nil
+}
+
+func (t *CpuItem) Check(name string, conf *ResourceConfig) error {
+	_, _, err := cpuQuotaPeriod(conf)
 	return err
 }
 
 func (t *CpuItem) Apply(pid int) error {
-	if !t.isApply {
-		return nil
-	}
 	if t.cgfilepath == "" {
 		return fmt.Errorf("create the limit file before use this pls")
 	}
@@ -46,9 +62,51 @@ func (t *CpuItem) Apply(pid int) error {
 	return nil
 }
 
+func (t *CpuItem) Stat(name string, stats *ResourceStats) error {
+	return nil
+}
+
 func (t *CpuItem) Remove() error {
-	if !t.isApply {
+	if t.cgfilepath == "" {
 		return nil
 	}
 	return os.RemoveAll(t.cgfilepath)
 }
+
+// 根据 --cpus 或者 --cpu-quota --cpu-period 计算cfs的quota和period 为0表示不需要修改
+// quota为-1表示不限制
+func cpuQuotaPeriod(conf *ResourceConfig) (int64, int64, error) {
+	if conf.Cpus != "" && conf.CpuQuota != 0 {
+		return 0, 0, fmt.Errorf("cpus and cpu-quota can not be set together")
+	}
+	period := conf.CpuPeriod
+	if period != 0 && (period < minCpuPeriod || period > maxCpuPeriod) {
+		return 0, 0, fmt.Errorf("invalid cpu period %d (range is %d-%d)", period, minCpuPeriod, maxCpuPeriod)
+	}
+	if conf.Cpus != "" {
+		if period == 0 {
+			period = defaultCpuPeriod
+		}
+		quota, err := cpusToQuota(conf.Cpus, period)
+		if err != nil {
+			return 0, 0, err
+		}
+		if quota < minCpuQuota {
+			return 0, 0, fmt.Errorf("cpus %s is too small", conf.Cpus)
+		}
+		return quota, period, nil
+	}
+	if conf.CpuQuota != 0 && conf.CpuQuota != -1 && conf.CpuQuota < minCpuQuota {
+		return 0, 0, fmt.Errorf("invalid cpu quota %d (minimum is %d)", conf.CpuQuota, minCpuQuota)
+	}
+	return conf.CpuQuota, period, nil
+}
+
+// 把 --cpus 1.5 这样的核数换算成period周期内可使用的cpu时间
+func cpusToQuota(cpus string, period int64) (int64, error) {
+	n, err := strconv.ParseFloat(cpus, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid cpus value %s", cpus)
+	}
+	return int64(n * float64(period)), nil
+}
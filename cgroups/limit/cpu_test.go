@@ -0,0 +1,44 @@
+package limit
+
+import "testing"
+
+func TestCpuQuotaPeriod(t *testing.T) {
+	tests := []struct {
+		name       string
+		conf       ResourceConfig
+		wantQuota  int64
+		wantPeriod int64
+		wantErr    bool
+	}{
+		{name: "empty", conf: ResourceConfig{}},
+		{name: "cpus default period", conf: ResourceConfig{Cpus: "1.5"}, wantQuota: 150000, wantPeriod: 100000},
+		{name: "cpus custom period", conf: ResourceConfig{Cpus: "1.5", CpuPeriod: 50000}, wantQuota: 75000, wantPeriod: 50000},
+		{name: "cpus max period", conf: ResourceConfig{Cpus: "0.5", CpuPeriod: 1000000}, wantQuota: 500000, wantPeriod: 1000000},
+		{name: "quota and period", conf: ResourceConfig{CpuQuota: 20000, CpuPeriod: 10000}, wantQuota: 20000, wantPeriod: 10000},
+		{name: "unlimited quota", conf: ResourceConfig{CpuQuota: -1}, wantQuota: -1},
+		{name: "period only", conf: ResourceConfig{CpuPeriod: 200000}, wantPeriod: 200000},
+		{name: "cpus with quota", conf: ResourceConfig{Cpus: "1", CpuQuota: 50000}, wantErr: true},
+		{name: "period too small", conf: ResourceConfig{CpuPeriod: 999}, wantErr: true},
+		{name: "period too large", conf: ResourceConfig{CpuPeriod: 1000001}, wantErr: true},
+		{name: "cpus too small", conf: ResourceConfig{Cpus: "0.001", CpuPeriod: 100000}, wantErr: true},
+		{name: "cpus zero", conf: ResourceConfig{Cpus: "0"}, wantErr: true},
+		{name: "cpus invalid", conf: ResourceConfig{Cpus: "two"}, wantErr: true},
+		{name: "quota too small", conf: ResourceConfig{CpuQuota: 999}, wantErr: true},
+	}
+	for _, tt := range tests {
+		quota, period, err := cpuQuotaPeriod(&tt.conf)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error, got quota %d period %d", tt.name, quota, period)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if quota != tt.wantQuota || period != tt.wantPeriod {
+			t.Errorf("%s: got quota %d period %d, want quota %d period %d", tt.name, quota, period, tt.wantQuota, tt.wantPeriod)
+		}
+	}
+}
@@ -5,8 +5,12 @@ import (
 	"os"
 	"path"
 	"strconv"
+	"strings"
 )
 
+const onlineCpusFile = "/sys/devices/system/cpu/online"
+const onlineNodesFile = "/sys/devices/system/node/online"
+
 type CpusetItem struct {
 	cgfilepath string //保存当前资源组root路径
 	isApply    bool
@@ -17,26 +21,38 @@ func (*CpusetItem) GetType() string {
 }
 
 func (t *CpusetItem) CreateLimitFile(name string, conf *ResourceConfig) error {
+	if err := checkCpuset(conf); err != nil {
+		return err
+	}
 	cgfilepath, err := findAndCreateCgroupFilePath(t.GetType(), name, true)
-	if err == nil {
-		if conf.Cpuset != 0 {
-			if err = os.WriteFile(path.Join(cgfilepath, limitCpusetFilename), []byte(string(rune(conf.Cpuset))), 0664); err == nil {
-				t.cgfilepath = cgfilepath
-				t.isApply = true
-			} else {
-				return fmt.Errorf("create cg file error %v", err)
-			}
+	if err != nil {
+		return err
+	}
+	t.cgfilepath = cgfilepath
+	// 新建的cpuset资源组cpus和mems为空 不能加入进程 需要先从父资源组继承
+	if err := populateCpuset(t.GetType(), name); err != nil {
+		return err
+	}
+	if conf.Cpuset != "" {
+		if err = os.WriteFile(path.Join(cgfilepath, limitCpusetFilename), []byte(conf.Cpuset), 0664); err != nil {
+			return fmt.Errorf("create cg file error %v", err)
 		}
-		t.isApply = false
-		return nil
+		t.isApply = true
 	}
-	return err
+	if conf.CpusetMems != "" {
+		if err = os.WriteFile(path.Join(cgfilepath, limitCpusetMemsFilename), []byte(conf.CpusetMems), 0664); err != nil {
+			return fmt.Errorf("create cg file error %v", err)
+		}
+		t.isApply = true
+	}
+	return nil
+}
+
+func (t *CpusetItem) Check(name string, conf *ResourceConfig) error {
+	return checkCpuset(conf)
 }
 
 func (t *CpusetItem) Apply(pid int) error {
-	if !t.isApply {
-		return nil
-	}
 	if t.cgfilepath == "" {
 		return fmt.Errorf("create the limit file before use this pls")
 	}
@@ -46,9 +62,100 @@ func (t *CpusetItem) Apply(pid int) error {
 	return nil
 }
 
+func (t *CpusetItem) Stat(name string, stats *ResourceStats) error {
+	return nil
+}
+
 func (t *CpusetItem) Remove() error {
-	if !t.isApply {
+	if t.cgfilepath == "" {
 		return nil
 	}
 	return os.RemoveAll(t.cgfilepath)
 }
+
+// 从上到下把父资源组的cpus和mems复制到为空的子资源组中
+func populateCpuset(limitType string, cgroupName string) error {
+	cgrouproot, err := findCgroupRootByResType(limitType)
+	if err != nil {
+		return err
+	}
+	parent := cgrouproot
+	for _, dir := range strings.Split(path.Clean(cgroupName), "/") {
+		current := path.Join(parent, dir)
+		for _, filename := range []string{limitCpusetFilename, limitCpusetMemsFilename} {
+			content, err := os.ReadFile(path.Join(current, filename))
+			if err != nil {
+				return fmt.Errorf("read cpuset file error %v", err)
+			}
+			if strings.TrimSpace(string(content)) != "" {
+				continue
+			}
+			parentContent, err := os.ReadFile(path.Join(parent, filename))
+			if err != nil {
+				return fmt.Errorf("read cpuset file error %v", err)
+			}
+			if err := os.WriteFile(path.Join(current, filename), parentContent, 0664); err != nil {
+				return fmt.Errorf("populate cpuset file error %v", err)
+			}
+		}
+		parent = current
+	}
+	return nil
+}
+
+// cpu和内存节点必须是宿主机上在线的
+func checkCpuset(conf *ResourceConfig) error {
+	if conf.Cpuset != "" {
+		if err := checkListSubset(conf.Cpuset, onlineCpusFile, "cpus"); err != nil {
+			return err
+		}
+	}
+	if conf.CpusetMems != "" {
+		if err := checkListSubset(conf.CpusetMems, onlineNodesFile, "mems"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkListSubset(list string, onlineFile string, kind string) error {
+	requested, err := parseList(list)
+	if err != nil {
+		return fmt.Errorf("invalid cpuset %s %s", kind, list)
+	}
+	online := map[int]bool{0: true}
+	// 没有NUMA的机器上没有node目录 只有节点0
+	if content, err := os.ReadFile(onlineFile); err == nil {
+		if online, err = parseList(strings.TrimSpace(string(content))); err != nil {
+			return fmt.Errorf("invalid online list in %s", onlineFile)
+		}
+	}
+	for n := range requested {
+		if !online[n] {
+			return fmt.Errorf("requested cpuset %s %s is not available on this host", kind, list)
+		}
+	}
+	return nil
+}
+
+// 解析 0-3,6 这样的列表
+func parseList(list string) (map[int]bool, error) {
+	res := map[int]bool{}
+	for _, item := range strings.Split(list, ",") {
+		startStr, endStr, isRange := strings.Cut(strings.TrimSpace(item), "-")
+		start, err := strconv.Atoi(startStr)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid list %s", list)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(endStr); err != nil || end < start {
+				return nil, fmt.Errorf("invalid list %s", list)
+			}
+		}
+		for i := start; i <= end; i++ {
+			res[i] = true
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,76 @@
+package limit
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestParseList(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []int
+		wantErr bool
+	}{
+		{list: "0", want: []int{0}},
+		{list: "0-3,6", want: []int{0, 1, 2, 3, 6}},
+		{list: " 1 , 3-4", want: []int{1, 3, 4}},
+		{list: "2-2", want: []int{2}},
+		{list: "0,0-1", want: []int{0, 1}},
+		{list: "3-1", wantErr: true},
+		{list: "", wantErr: true},
+		{list: "-1", wantErr: true},
+		{list: "a-b", wantErr: true},
+		{list: "0-", wantErr: true},
+		{list: "1,,2", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseList(tt.list)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseList(%q) expected error, got %v", tt.list, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseList(%q) unexpected error %v", tt.list, err)
+			continue
+		}
+		want := map[int]bool{}
+		for _, n := range tt.want {
+			want[n] = true
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("parseList(%q) = %v, want %v", tt.list, got, want)
+		}
+	}
+}
+
+func TestCheckListSubset(t *testing.T) {
+	dir := t.TempDir()
+	online := path.Join(dir, "online")
+	if err := os.WriteFile(online, []byte("0-3,6\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		list       string
+		onlineFile string
+		wantErr    bool
+	}{
+		{list: "0-3,6", onlineFile: online},
+		{list: "1,6", onlineFile: online},
+		{list: "4", onlineFile: online, wantErr: true},
+		{list: "0-7", onlineFile: online, wantErr: true},
+		{list: "3-0", onlineFile: online, wantErr: true},
+		// 没有online文件时只有节点0
+		{list: "0", onlineFile: path.Join(dir, "missing")},
+		{list: "1", onlineFile: path.Join(dir, "missing"), wantErr: true},
+	}
+	for _, tt := range tests {
+		err := checkListSubset(tt.list, tt.onlineFile, "cpus")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("checkListSubset(%q, %s) error = %v, wantErr %t", tt.list, path.Base(tt.onlineFile), err, tt.wantErr)
+		}
+	}
+}
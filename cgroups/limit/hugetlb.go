@@ -0,0 +1,152 @@
+package limit
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/kehaha-5/go-low-level-container/common"
+)
+
+const hugepagesDir = "/sys/kernel/mm/hugepages"
+
+// v1为 hugetlb.2MB.limit_in_bytes v2为 hugetlb.2MB.max
+const limitHugetlbFilenameFormat = "hugetlb.%s.limit_in_bytes"
+const limitHugetlbMaxFilenameFormat = "hugetlb.%s.max"
+
+// 某种大小的大页可以使用的总量 如 2MB:1G
+type HugepageLimit struct {
+	PageSize string `json:"pageSize"` // 和cgroup文件名中的一致 如2MB 1GB
+	Limit    uint64 `json:"limit"`
+}
+
+// 解析 --hugepage-limit 2MB:1G 这样的参数
+func ParseHugepageLimits(values []string) ([]HugepageLimit, error) {
+	res := []HugepageLimit{}
+	for _, value := range values {
+		sizeStr, limitStr, ok := strings.Cut(value, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid hugepage limit %s (expected pagesize:limit)", value)
+		}
+		pageSize, err := common.ParseSize(sizeStr)
+		if err != nil || pageSize == 0 {
+			return nil, fmt.Errorf("invalid hugepage size of %s", value)
+		}
+		limit, err := common.ParseSize(limitStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid limit of hugepage limit %s", value)
+		}
+		res = append(res, HugepageLimit{PageSize: hugepageSizeString(pageSize), Limit: uint64(limit)})
+	}
+	return res, nil
+}
+
+// 转换成内核使用的格式 2097152 -> 2MB
+func hugepageSizeString(size int64) string {
+	units := []string{"B", "KB", "MB", "GB"}
+	i := 0
+	for i < len(units)-1 && size >= 1024 && size%1024 == 0 {
+		size /= 1024
+		i++
+	}
+	return strconv.FormatInt(size, 10) + units[i]
+}
+
+// 宿主机支持的大页大小 目录名为 hugepages-2048kB
+func supportedHugepageSizes() (map[string]bool, error) {
+	entries, err := os.ReadDir(hugepagesDir)
+	if err != nil {
+		return nil, fmt.Errorf("read hugepages dir error %v", err)
+	}
+	res := map[string]bool{}
+	for _, entry := range entries {
+		kb, ok := strings.CutPrefix(entry.Name(), "hugepages-")
+		if !ok {
+			continue
+		}
+		size, err := strconv.ParseInt(strings.TrimSuffix(kb, "kB"), 10, 64)
+		if err != nil {
+			continue
+		}
+		res[hugepageSizeString(size*1024)] = true
+	}
+	return res, nil
+}
+
+func checkHugepageLimits(conf *ResourceConfig) error {
+	if len(conf.HugepageLimits) == 0 {
+		return nil
+	}
+	supported, err := supportedHugepageSizes()
+	if err != nil {
+		return err
+	}
+	for _, item := range conf.HugepageLimits {
+		if !supported[item.PageSize] {
+			return fmt.Errorf("hugepage size %s is not supported on this host", item.PageSize)
+		}
+	}
+	return nil
+}
+
+type HugetlbItem struct {
+	cgfilepath string //保存当前资源组root路径
+	isApply    bool
+}
+
+func (*HugetlbItem) GetType() string {
+	return "hugetlb"
+}
+
+func (t *HugetlbItem) CreateLimitFile(name string, conf *ResourceConfig) error {
+	// 很多宿主机没有挂载hugetlb控制器 没有限制时不创建资源组
+	if len(conf.HugepageLimits) == 0 {
+		return nil
+	}
+	if err := checkHugepageLimits(conf); err != nil {
+		return err
+	}
+	cgfilepath, err := findAndCreateCgroupFilePath(t.GetType(), name, true)
+	if err != nil {
+		return err
+	}
+	t.cgfilepath = cgfilepath
+	for _, item := range conf.HugepageLimits {
+		filename := fmt.Sprintf(limitHugetlbFilenameFormat, item.PageSize)
+		if err = os.WriteFile(path.Join(cgfilepath, filename), []byte(strconv.FormatUint(item.Limit, 10)), 0664); err != nil {
+			return fmt.Errorf("create cg file error %v", err)
+		}
+	}
+	t.isApply = true
+	return nil
+}
+
+func (t *HugetlbItem) Check(name string, conf *ResourceConfig) error {
+	return checkHugepageLimits(conf)
+}
+
+func (t *HugetlbItem) Apply(pid int) error {
+	if !t.isApply {
+		return nil
+	}
+	if t.cgfilepath == "" {
+		return fmt.Errorf("create the limit file before use this pls")
+	}
+	if err := os.WriteFile(path.Join(t.cgfilepath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		return fmt.Errorf("set cgroup proc fail %v type is %s", err, t.GetType())
+	}
+	return nil
+}
+
+func (t *HugetlbItem) Stat(name string, stats *ResourceStats) error {
+	return nil
+}
+
+func (t *HugetlbItem) Remove() error {
+	if !t.isApply {
+		return nil
+	}
+	return os.RemoveAll(t.cgfilepath)
+}
@@ -0,0 +1,43 @@
+package limit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHugepageLimits(t *testing.T) {
+	tests := []struct {
+		values  []string
+		want    []HugepageLimit
+		wantErr bool
+	}{
+		{values: nil, want: []HugepageLimit{}},
+		{values: []string{"2MB:1G"}, want: []HugepageLimit{{PageSize: "2MB", Limit: 1 << 30}}},
+		{values: []string{"2m:512m", "1GB:2g"}, want: []HugepageLimit{
+			{PageSize: "2MB", Limit: 512 << 20},
+			{PageSize: "1GB", Limit: 2 << 30},
+		}},
+		{values: []string{"2048k:0"}, want: []HugepageLimit{{PageSize: "2MB", Limit: 0}}},
+		{values: []string{"64KB:1m"}, want: []HugepageLimit{{PageSize: "64KB", Limit: 1 << 20}}},
+		{values: []string{"2MB"}, wantErr: true},
+		{values: []string{"0:1G"}, wantErr: true},
+		{values: []string{"huge:1G"}, wantErr: true},
+		{values: []string{"2MB:lots"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseHugepageLimits(tt.values)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseHugepageLimits(%q) expected error, got %v", tt.values, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseHugepageLimits(%q) unexpected error %v", tt.values, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseHugepageLimits(%q) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
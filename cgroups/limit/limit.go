@@ -5,24 +5,80 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 )
 
 const mountinfofile string = "/proc/self/mountinfo"
 const limitCpuFilename = "cpu.shares"
+const limitCpuQuotaFilename = "cpu.cfs_quota_us"
+const limitCpuPeriodFilename = "cpu.cfs_period_us"
 const limitCpusetFilename = "cpuset.cpus"
+const limitCpusetMemsFilename = "cpuset.mems"
 const limitMemoryFilename = "memory.limit_in_bytes"
+const limitMemorySwapFilename = "memory.memsw.limit_in_bytes"
+const limitMemorySoftFilename = "memory.soft_limit_in_bytes"
+const limitKernelMemoryFilename = "memory.kmem.limit_in_bytes"
+const usageMemoryFilename = "memory.usage_in_bytes"
+const oomControlFilename = "memory.oom_control"
+const limitPidsFilename = "pids.max"
+const usagePidsFilename = "pids.current"
+const pidsEventsFilename = "pids.events"
+
+const defaultCpuPeriod = 100000
+
+// 内核允许的cfs周期为1ms到1s quota最小为1ms
+const minCpuPeriod = 1000
+const maxCpuPeriod = 1000000
+const minCpuQuota = 1000
+
+// 太小的内存限制容器进程无法启动
+const minMemoryLimit = 6 * 1024 * 1024
 
 type ResourceConfig struct {
-	Cpu    int
-	Cpuset int
-	Memory string
+	Cpu        int    // cpu.shares
+	Cpus       string // 可使用的cpu核数 如1.5 会转换成 cfs quota/period
+	CpuPeriod  int64  // cfs调度周期 单位微秒
+	CpuQuota   int64  // 每个周期内可以使用的cpu时间 单位微秒 和Cpus不能同时设置
+	Cpuset     string // 可使用的cpu列表 如0-3,6
+	CpusetMems string // 可使用的NUMA内存节点列表 如0,1
+	Memory     string
+	// 内存使用超过后会被限流和回收 只在cgroup v2生效
+	MemoryHigh string
+	// 内存加swap的总量 -1表示不限制swap
+	MemorySwap string
+	// 内存不足时尽量保留的内存 v1为soft limit v2为memory.low
+	MemoryReservation string
+	// 内核内存限制 只有旧版本内核的v1支持
+	KernelMemory   string
+	OomKillDisable bool
+	PidsLimit      int64
+	// 块设备io的权重 10-1000
+	BlkioWeight     uint16
+	DeviceReadBps   []ThrottleDevice
+	DeviceWriteBps  []ThrottleDevice
+	DeviceReadIops  []ThrottleDevice
+	DeviceWriteIops []ThrottleDevice
+	// 每种大小的大页可以使用的总量
+	HugepageLimits []HugepageLimit
+}
+
+// 资源组当前的使用情况 限制为0表示不限制
+type ResourceStats struct {
+	MemoryUsage int64
+	MemoryLimit int64
+	Pids        int64
+	PidsLimit   int64
+	// 因为达到pids.max而fork失败的次数
+	PidsMaxEvents int64
 }
 
 type ResourceItem interface {
 	GetType() string                                               //获取该资源的类型
 	CreateLimitFile(cgroupName string, conf *ResourceConfig) error //在资源组中创建该资源的限制文件
-	Apply(pid int) error                                           //添加pid到该资源组
+	Check(cgroupName string, conf *ResourceConfig) error           //检查新的限制是否满足资源组当前的使用情况
+	Apply(pid int) error                                           //添加pid到该资源组 没有设置限制时也要加入
+	Stat(cgroupName string, stats *ResourceStats) error            //读取该资源当前的使用情况
 	Remove() error                                                 //删除真个资源组
 }
 
@@ -67,3 +123,87 @@ func findCgroupRootByResType(limitType string) (string, error) {
 	return "", fmt.Errorf("can not find the rootfile of the %s type", limitType)
 }
 
+// 读取资源组中的数值文件 如memory.usage_in_bytes pids.current
+func readCgroupInt(cgfilepath string, filename string) (int64, error) {
+	content, err := os.ReadFile(path.Join(cgfilepath, filename))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// 读取资源组中的限制文件 max或者超大的值表示不限制 返回0
+func readCgroupLimit(cgfilepath string, filename string) (int64, error) {
+	content, err := os.ReadFile(path.Join(cgfilepath, filename))
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(content))
+	if value == "max" {
+		return 0, nil
+	}
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	// v1中不限制内存时是接近int64最大值的页对齐的数
+	if limit >= 1<<62 {
+		return 0, nil
+	}
+	return limit, nil
+}
+
+// 读取 pids.events memory.events 这类 key value 格式文件中的一项
+func readCgroupEvent(cgfilepath string, filename string, key string) (int64, error) {
+	content, err := os.ReadFile(path.Join(cgfilepath, filename))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if count, ok := strings.CutPrefix(line, key+" "); ok {
+			return strconv.ParseInt(strings.TrimSpace(count), 10, 64)
+		}
+	}
+	return 0, nil
+}
+
+// 从下往上删除资源组及其子资源组 还有进程的资源组内核会拒绝删除
+// 资源组不存在时直接返回
+func RemoveCgroup(limitType string, cgroupName string) error {
+	if cgroupName = path.Clean(cgroupName); cgroupName == "." || cgroupName == "/" {
+		return fmt.Errorf("can not remove the root cgroup")
+	}
+	var cgfilepath string
+	var err error
+	if limitType == (&UnifiedItem{}).GetType() {
+		cgfilepath, err = findAndCreateUnifiedPath(cgroupName, false)
+	} else {
+		cgfilepath, err = findAndCreateCgroupFilePath(limitType, cgroupName, false)
+	}
+	if err != nil {
+		return nil
+	}
+	return removeCgroupDir(cgfilepath)
+}
+
+func removeCgroupDir(cgfilepath string) error {
+	entries, err := os.ReadDir(cgfilepath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			if err := removeCgroupDir(path.Join(cgfilepath, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	// cgroup目录中的文件不能删除 只能rmdir
+	if err := os.Remove(cgfilepath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove cgroup %s error %v", cgfilepath, err)
+	}
+	return nil
+}
@@ -2,14 +2,17 @@ package limit
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"path"
 	"strconv"
+
+	"github.com/kehaha-5/go-low-level-container/common"
 )
 
 type MemoryItem struct {
 	cgfilepath string //保存当前资源组root路径
-	isApply bool
+	isApply    bool
 }
 
 func (*MemoryItem) GetType() string {
@@ -17,26 +20,75 @@ func (*MemoryItem) GetType() string {
 }
 
 func (t *MemoryItem) CreateLimitFile(name string, conf *ResourceConfig) error {
+	if err := checkMemory(conf); err != nil {
+		return err
+	}
 	cgfilepath, err := findAndCreateCgroupFilePath(t.GetType(), name, true)
-	if err == nil {
-		if conf.Memory != "" {
-			if err = os.WriteFile(path.Join(cgfilepath, limitMemoryFilename), []byte(conf.Memory), 0664); err == nil {
-				t.cgfilepath = cgfilepath
-				t.isApply = true
-			} else {
-				return fmt.Errorf("create cg file error %v", err)
+	if err != nil {
+		return err
+	}
+	t.cgfilepath = cgfilepath
+	if conf.Memory != "" {
+		err := os.WriteFile(path.Join(cgfilepath, limitMemoryFilename), []byte(conf.Memory), 0664)
+		// 内存加swap的总量不能小于内存限制 调大内存限制时需要先调大总量
+		if err != nil && conf.MemorySwap != "" {
+			if err = os.WriteFile(path.Join(cgfilepath, limitMemorySwapFilename), []byte(conf.MemorySwap), 0664); err == nil {
+				err = os.WriteFile(path.Join(cgfilepath, limitMemoryFilename), []byte(conf.Memory), 0664)
 			}
 		}
-		t.isApply = false
-		return nil
+		if err != nil {
+			return fmt.Errorf("create cg file error %v", err)
+		}
+		t.isApply = true
 	}
-	return err
+	// 需要内核开启swapaccount 必须在memory.limit_in_bytes之后写入
+	if conf.MemorySwap != "" {
+		if err = os.WriteFile(path.Join(cgfilepath, limitMemorySwapFilename), []byte(conf.MemorySwap), 0664); err != nil {
+			return fmt.Errorf("create cg file error %v", err)
+		}
+		t.isApply = true
+	}
+	if conf.MemoryReservation != "" {
+		if err = os.WriteFile(path.Join(cgfilepath, limitMemorySoftFilename), []byte(conf.MemoryReservation), 0664); err != nil {
+			return fmt.Errorf("create cg file error %v", err)
+		}
+		t.isApply = true
+	}
+	if conf.KernelMemory != "" {
+		// 新的内核已经移除了kmem的限制
+		if _, err := os.Stat(path.Join(cgfilepath, limitKernelMemoryFilename)); err != nil {
+			slog.Warn("kernel memory limit is not supported by the kernel, ignored")
+		} else if err = os.WriteFile(path.Join(cgfilepath, limitKernelMemoryFilename), []byte(conf.KernelMemory), 0664); err != nil {
+			return fmt.Errorf("create cg file error %v", err)
+		} else {
+			t.isApply = true
+		}
+	}
+	if conf.OomKillDisable {
+		if err = os.WriteFile(path.Join(cgfilepath, oomControlFilename), []byte("1"), 0664); err != nil {
+			return fmt.Errorf("create cg file error %v", err)
+		}
+		t.isApply = true
+	}
+	return nil
 }
 
-func (t *MemoryItem) Apply(pid int) error {
-	if !t.isApply {
+// 新的内存限制不能低于当前已使用的内存
+func (t *MemoryItem) Check(name string, conf *ResourceConfig) error {
+	if err := checkMemory(conf); err != nil {
+		return err
+	}
+	if conf.Memory == "" {
+		return nil
+	}
+	cgfilepath, err := findAndCreateCgroupFilePath(t.GetType(), name, false)
+	if err != nil {
 		return nil
 	}
+	return checkMemoryUsage(conf, cgfilepath, usageMemoryFilename)
+}
+
+func (t *MemoryItem) Apply(pid int) error {
 	if t.cgfilepath == "" {
 		return fmt.Errorf("create the limit file before use this pls")
 	}
@@ -46,9 +98,106 @@ func (t *MemoryItem) Apply(pid int) error {
 	return nil
 }
 
+func (t *MemoryItem) Stat(name string, stats *ResourceStats) error {
+	cgfilepath, err := findAndCreateCgroupFilePath(t.GetType(), name, false)
+	if err != nil {
+		return err
+	}
+	if stats.MemoryUsage, err = readCgroupInt(cgfilepath, usageMemoryFilename); err != nil {
+		return err
+	}
+	stats.MemoryLimit, err = readCgroupLimit(cgfilepath, limitMemoryFilename)
+	return err
+}
+
 func (t *MemoryItem) Remove() error {
-	if !t.isApply {
+	if t.cgfilepath == "" {
 		return nil
 	}
 	return os.RemoveAll(t.cgfilepath)
 }
+
+// 读取资源组中因为超出内存限制被kill的进程数
+func ReadOomKillCount(cgroupName string) (int64, error) {
+	if IsCgroupV2() {
+		return readUnifiedOomKillCount(cgroupName)
+	}
+	cgfilepath, err := findAndCreateCgroupFilePath("memory", cgroupName, false)
+	if err != nil {
+		return 0, err
+	}
+	return readCgroupEvent(cgfilepath, oomControlFilename, "oom_kill")
+}
+
+// 把 512m 1g 这样带单位的值换算成字节 -1表示不限制
+func NormalizeMemory(value string) (string, error) {
+	if value == "" || value == "-1" {
+		return value, nil
+	}
+	size, err := common.ParseSize(value)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(size, 10), nil
+}
+
+// 内存相关的限制之间的关系
+func checkMemory(conf *ResourceConfig) error {
+	memory, err := parseMemoryValue(conf.Memory)
+	if err != nil {
+		return err
+	}
+	if memory > 0 && memory < minMemoryLimit {
+		return fmt.Errorf("minimum memory limit allowed is %s", common.SizeHumanReadable(minMemoryLimit))
+	}
+	if conf.MemorySwap != "" && conf.MemorySwap != "-1" {
+		swap, err := parseMemoryValue(conf.MemorySwap)
+		if err != nil {
+			return err
+		}
+		if memory <= 0 {
+			return fmt.Errorf("memory swap requires memory limit")
+		}
+		if swap < memory {
+			return fmt.Errorf("memory swap %s must be larger than memory limit %s", conf.MemorySwap, conf.Memory)
+		}
+	}
+	reservation, err := parseMemoryValue(conf.MemoryReservation)
+	if err != nil {
+		return err
+	}
+	if memory > 0 && reservation > memory {
+		return fmt.Errorf("memory reservation %s must be smaller than memory limit %s", conf.MemoryReservation, conf.Memory)
+	}
+	if _, err := parseMemoryValue(conf.KernelMemory); err != nil {
+		return err
+	}
+	return nil
+}
+
+// 空字符串和-1返回-1 兼容旧版本记录的带单位的值
+func parseMemoryValue(value string) (int64, error) {
+	if value == "" || value == "-1" {
+		return -1, nil
+	}
+	size, err := common.ParseSize(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory value %s", value)
+	}
+	return size, nil
+}
+
+func checkMemoryUsage(conf *ResourceConfig, cgfilepath string, usageFilename string) error {
+	limit, err := parseMemoryValue(conf.Memory)
+	if err != nil || limit < 0 {
+		return err
+	}
+	usage, err := readCgroupInt(cgfilepath, usageFilename)
+	if err != nil {
+		return nil
+	}
+	if limit < usage {
+		return fmt.Errorf("memory limit %d is lower than current usage %d", limit, usage)
+	}
+	return nil
+}
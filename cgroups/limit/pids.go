@@ -0,0 +1,91 @@
+package limit
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+)
+
+type PidsItem struct {
+	cgfilepath string //保存当前资源组root路径
+	isApply    bool
+}
+
+func (*PidsItem) GetType() string {
+	return "pids"
+}
+
+func (t *PidsItem) CreateLimitFile(name string, conf *ResourceConfig) error {
+	cgfilepath, err := findAndCreateCgroupFilePath(t.GetType(), name, true)
+	if err != nil {
+		return err
+	}
+	t.cgfilepath = cgfilepath
+	if conf.PidsLimit != 0 {
+		if err = os.WriteFile(path.Join(cgfilepath, limitPidsFilename), []byte(pidsLimitValue(conf.PidsLimit)), 0664); err != nil {
+			return fmt.Errorf("create cg file error %v", err)
+		}
+		t.isApply = true
+	}
+	return nil
+}
+
+// 新的进程数限制不能低于资源组中当前的进程数
+func (t *PidsItem) Check(name string, conf *ResourceConfig) error {
+	if conf.PidsLimit <= 0 {
+		return nil
+	}
+	cgfilepath, err := findAndCreateCgroupFilePath(t.GetType(), name, false)
+	if err != nil {
+		return nil
+	}
+	current, err := readCgroupInt(cgfilepath, usagePidsFilename)
+	if err != nil {
+		return nil
+	}
+	if conf.PidsLimit < current {
+		return fmt.Errorf("pids limit %d is lower than current pids %d", conf.PidsLimit, current)
+	}
+	return nil
+}
+
+func (t *PidsItem) Apply(pid int) error {
+	if t.cgfilepath == "" {
+		return fmt.Errorf("create the limit file before use this pls")
+	}
+	if err := os.WriteFile(path.Join(t.cgfilepath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		return fmt.Errorf("set cgroup proc fail %v type is %s", err, t.GetType())
+	}
+	return nil
+}
+
+func (t *PidsItem) Stat(name string, stats *ResourceStats) error {
+	cgfilepath, err := findAndCreateCgroupFilePath(t.GetType(), name, false)
+	if err != nil {
+		return err
+	}
+	if stats.Pids, err = readCgroupInt(cgfilepath, usagePidsFilename); err != nil {
+		return err
+	}
+	if stats.PidsLimit, err = readCgroupLimit(cgfilepath, limitPidsFilename); err != nil {
+		return err
+	}
+	stats.PidsMaxEvents, err = readCgroupEvent(cgfilepath, pidsEventsFilename, "max")
+	return err
+}
+
+func (t *PidsItem) Remove() error {
+	if t.cgfilepath == "" {
+		return nil
+	}
+	return os.RemoveAll(t.cgfilepath)
+}
+
+// 小于0表示不限制
+func pidsLimitValue(limit int64) string {
+	if limit < 0 {
+		return "max"
+	}
+	return strconv.FormatInt(limit, 10)
+}
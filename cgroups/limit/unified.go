@@ -0,0 +1,402 @@
+package limit
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kehaha-5/go-low-level-container/common"
+	"golang.org/x/sys/unix"
+)
+
+const defaultCgroupMountPoint = "/sys/fs/cgroup"
+
+const cgroupControllersFilename = "cgroup.controllers"
+const cgroupSubtreeControlFilename = "cgroup.subtree_control"
+const cgroupProcsFilename = "cgroup.procs"
+const limitCpuWeightFilename = "cpu.weight"
+const limitCpuMaxFilename = "cpu.max"
+const limitCpusetCpusFilename = "cpuset.cpus"
+const limitMemoryMaxFilename = "memory.max"
+const limitMemoryHighFilename = "memory.high"
+const limitMemoryLowFilename = "memory.low"
+const limitMemorySwapMaxFilename = "memory.swap.max"
+const usageMemoryCurrentFilename = "memory.current"
+const memoryEventsFilename = "memory.events"
+const limitIoWeightFilename = "io.weight"
+const limitIoMaxFilename = "io.max"
+
+// 容器需要用到的控制器
+var unifiedControllers = []string{"cpu", "cpuset", "memory", "pids", "io", "hugetlb"}
+
+// 宿主机是否只挂载了cgroup v2 (unified hierarchy)
+func IsCgroupV2() bool {
+	var st unix.Statfs_t
+	if err := unix.Statfs(defaultCgroupMountPoint, &st); err != nil {
+		return false
+	}
+	return st.Type == unix.CGROUP2_SUPER_MAGIC
+}
+
+// cgroup v2 所有控制器在同一个目录中 一个item负责全部资源
+type UnifiedItem struct {
+	cgfilepath string //保存当前资源组root路径
+	isApply    bool
+}
+
+func (*UnifiedItem) GetType() string {
+	return "unified"
+}
+
+func (t *UnifiedItem) CreateLimitFile(name string, conf *ResourceConfig) error {
+	cgfilepath, err := findAndCreateUnifiedPath(name, true)
+	if err != nil {
+		return err
+	}
+	t.cgfilepath = cgfilepath
+	// 和v1一样 所有容器都放到自己的资源组中
+	t.isApply = true
+
+	files := map[string]string{}
+	if conf.Cpu != 0 {
+		files[limitCpuWeightFilename] = strconv.FormatInt(sharesToWeight(int64(conf.Cpu)), 10)
+	}
+	quota, period, err := cpuQuotaPeriod(conf)
+	if err != nil {
+		return err
+	}
+	if quota != 0 || period != 0 {
+		files[limitCpuMaxFilename] = cpuMaxValue(cgfilepath, quota, period)
+	}
+	if err := checkCpuset(conf); err != nil {
+		return err
+	}
+	if conf.Cpuset != "" {
+		files[limitCpusetCpusFilename] = conf.Cpuset
+	}
+	if conf.CpusetMems != "" {
+		files[limitCpusetMemsFilename] = conf.CpusetMems
+	}
+	if conf.Memory != "" {
+		files[limitMemoryMaxFilename] = unifiedMemoryValue(conf.Memory, "max")
+	}
+	if err := checkMemory(conf); err != nil {
+		return err
+	}
+	if conf.MemoryHigh != "" {
+		files[limitMemoryHighFilename] = unifiedMemoryValue(conf.MemoryHigh, "max")
+	}
+	if conf.MemoryReservation != "" {
+		files[limitMemoryLowFilename] = unifiedMemoryValue(conf.MemoryReservation, "0")
+	}
+	// v2没有对应的限制
+	if conf.KernelMemory != "" {
+		slog.Warn("kernel memory limit is not supported by cgroup v2, ignored")
+	}
+	if conf.OomKillDisable {
+		slog.Warn("oom kill disable is not supported by cgroup v2, ignored")
+	}
+	if conf.MemorySwap != "" {
+		swap, err := swapMaxValue(conf.Memory, conf.MemorySwap)
+		if err != nil {
+			return err
+		}
+		files[limitMemorySwapMaxFilename] = swap
+	}
+	if conf.PidsLimit != 0 {
+		files[limitPidsFilename] = pidsLimitValue(conf.PidsLimit)
+	}
+	if conf.BlkioWeight != 0 {
+		if err := checkBlkioWeight(conf.BlkioWeight); err != nil {
+			return err
+		}
+		files[limitIoWeightFilename] = fmt.Sprintf("default %d", blkioWeightToIoWeight(conf.BlkioWeight))
+	}
+	// memory.swap.max 依赖 memory.max 按固定顺序写入
+	for _, filename := range []string{
+		limitCpuWeightFilename, limitCpuMaxFilename, limitCpusetCpusFilename, limitCpusetMemsFilename,
+		limitMemoryMaxFilename, limitMemoryHighFilename, limitMemoryLowFilename, limitMemorySwapMaxFilename,
+		limitPidsFilename, limitIoWeightFilename,
+	} {
+		value, ok := files[filename]
+		if !ok {
+			continue
+		}
+		if err := os.WriteFile(path.Join(cgfilepath, filename), []byte(value), 0664); err != nil {
+			return fmt.Errorf("create cg file %s error %v", filename, err)
+		}
+	}
+	if err := checkHugepageLimits(conf); err != nil {
+		return err
+	}
+	for _, item := range conf.HugepageLimits {
+		filename := fmt.Sprintf(limitHugetlbMaxFilenameFormat, item.PageSize)
+		if err := os.WriteFile(path.Join(cgfilepath, filename), []byte(strconv.FormatUint(item.Limit, 10)), 0664); err != nil {
+			return fmt.Errorf("create cg file %s error %v", filename, err)
+		}
+	}
+	// io.max 每次写入一个设备的所有限制
+	for _, line := range ioMaxLines(conf) {
+		if err := os.WriteFile(path.Join(cgfilepath, limitIoMaxFilename), []byte(line), 0664); err != nil {
+			return fmt.Errorf("create cg file %s error %v", limitIoMaxFilename, err)
+		}
+	}
+	return nil
+}
+
+// 新的限制不能低于资源组当前的使用情况
+func (t *UnifiedItem) Check(name string, conf *ResourceConfig) error {
+	if err := checkBlkioWeight(conf.BlkioWeight); err != nil {
+		return err
+	}
+	if err := checkHugepageLimits(conf); err != nil {
+		return err
+	}
+	if _, _, err := cpuQuotaPeriod(conf); err != nil {
+		return err
+	}
+	if err := checkCpuset(conf); err != nil {
+		return err
+	}
+	if err := checkMemory(conf); err != nil {
+		return err
+	}
+	cgfilepath, err := findAndCreateUnifiedPath(name, false)
+	if err != nil {
+		return nil
+	}
+	if err := checkMemoryUsage(conf, cgfilepath, usageMemoryCurrentFilename); err != nil {
+		return err
+	}
+	if conf.PidsLimit > 0 {
+		if current, err := readCgroupInt(cgfilepath, usagePidsFilename); err == nil && conf.PidsLimit < current {
+			return fmt.Errorf("pids limit %d is lower than current pids %d", conf.PidsLimit, current)
+		}
+	}
+	return nil
+}
+
+func (t *UnifiedItem) Apply(pid int) error {
+	if !t.isApply {
+		return nil
+	}
+	if t.cgfilepath == "" {
+		return fmt.Errorf("create the limit file before use this pls")
+	}
+	if err := os.WriteFile(path.Join(t.cgfilepath, cgroupProcsFilename), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		return fmt.Errorf("set cgroup proc fail %v type is %s", err, t.GetType())
+	}
+	return nil
+}
+
+func (t *UnifiedItem) Stat(name string, stats *ResourceStats) error {
+	cgfilepath, err := findAndCreateUnifiedPath(name, false)
+	if err != nil {
+		return err
+	}
+	if stats.MemoryUsage, err = readCgroupInt(cgfilepath, usageMemoryCurrentFilename); err != nil {
+		return err
+	}
+	if stats.MemoryLimit, err = readCgroupLimit(cgfilepath, limitMemoryMaxFilename); err != nil {
+		return err
+	}
+	if stats.Pids, err = readCgroupInt(cgfilepath, usagePidsFilename); err != nil {
+		return err
+	}
+	if stats.PidsLimit, err = readCgroupLimit(cgfilepath, limitPidsFilename); err != nil {
+		return err
+	}
+	stats.PidsMaxEvents, err = readCgroupEvent(cgfilepath, pidsEventsFilename, "max")
+	return err
+}
+
+func (t *UnifiedItem) Remove() error {
+	if !t.isApply {
+		return nil
+	}
+	// cgroup目录中的文件不能删除 只能rmdir
+	if err := os.Remove(t.cgfilepath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// 在cgroup v2的根目录下创建资源组 并在每一级父目录中开启需要的控制器
+func findAndCreateUnifiedPath(cgroupName string, autoCreate bool) (string, error) {
+	cgrouproot, err := findUnifiedRoot()
+	if err != nil {
+		return "", err
+	}
+	cgfilepath := path.Join(cgrouproot, cgroupName)
+	if _, err := os.Stat(cgfilepath); err == nil {
+		return cgfilepath, nil
+	} else if !autoCreate || !os.IsNotExist(err) {
+		return "", fmt.Errorf("cgrouproot not exist %s", cgfilepath)
+	}
+
+	// 子目录的控制器由父目录的cgroup.subtree_control决定
+	parent := cgrouproot
+	for _, dir := range strings.Split(path.Clean(cgroupName), "/") {
+		if err := enableControllers(parent); err != nil {
+			return "", err
+		}
+		parent = path.Join(parent, dir)
+		if err := os.Mkdir(parent, 0755); err != nil && !os.IsExist(err) {
+			return "", fmt.Errorf("create cgroup %v", err)
+		}
+	}
+	return cgfilepath, nil
+}
+
+// 开启父目录中可用的控制器 已经开启的重复写入没有影响
+func enableControllers(cgfilepath string) error {
+	content, err := os.ReadFile(path.Join(cgfilepath, cgroupControllersFilename))
+	if err != nil {
+		return fmt.Errorf("read cgroup controllers %v", err)
+	}
+	available := strings.Fields(string(content))
+	for _, controller := range unifiedControllers {
+		if !slices.Contains(available, controller) {
+			continue
+		}
+		if err := os.WriteFile(path.Join(cgfilepath, cgroupSubtreeControlFilename), []byte("+"+controller), 0644); err != nil {
+			return fmt.Errorf("enable controller %s in %s error %v", controller, cgfilepath, err)
+		}
+	}
+	return nil
+}
+
+// cgroup2文件系统的挂载点
+func findUnifiedRoot() (string, error) {
+	f, err := os.Open(mountinfofile)
+	if err != nil {
+		return "", fmt.Errorf("open mountinfofile %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 可选字段后面以 - 分隔 之后是文件系统类型
+		_, after, ok := strings.Cut(scanner.Text(), " - ")
+		if !ok {
+			continue
+		}
+		if fields := strings.Fields(after); len(fields) != 0 && fields[0] == "cgroup2" {
+			return strings.Split(scanner.Text(), " ")[4], nil
+		}
+	}
+	return "", fmt.Errorf("can not find the cgroup2 mount point")
+}
+
+// cpu.max 的格式为 "quota period" 只修改其中一个时另一个保持原来的值
+func cpuMaxValue(cgfilepath string, quota int64, period int64) string {
+	oldQuota, oldPeriod := "max", strconv.Itoa(defaultCpuPeriod)
+	if content, err := os.ReadFile(path.Join(cgfilepath, limitCpuMaxFilename)); err == nil {
+		if fields := strings.Fields(string(content)); len(fields) == 2 {
+			oldQuota, oldPeriod = fields[0], fields[1]
+		}
+	}
+	quotaStr, periodStr := oldQuota, oldPeriod
+	if quota == -1 {
+		quotaStr = "max"
+	} else if quota != 0 {
+		quotaStr = strconv.FormatInt(quota, 10)
+	}
+	if period != 0 {
+		periodStr = strconv.FormatInt(period, 10)
+	}
+	return quotaStr + " " + periodStr
+}
+
+// cpu.shares [2, 262144] 换算成 cpu.weight [1, 10000]
+func sharesToWeight(shares int64) int64 {
+	if shares < 2 {
+		shares = 2
+	}
+	if shares > 262144 {
+		shares = 262144
+	}
+	return 1 + ((shares-2)*9999)/262142
+}
+
+// blkio.weight [10, 1000] 换算成 io.weight [1, 10000]
+func blkioWeightToIoWeight(weight uint16) uint64 {
+	return 1 + (uint64(weight)-minBlkioWeight)*9999/(maxBlkioWeight-minBlkioWeight)
+}
+
+// 按设备合并读写限制 如 8:0 rbps=1048576 wiops=100
+func ioMaxLines(conf *ResourceConfig) []string {
+	devices := []string{}
+	limits := map[string][]string{}
+	for _, item := range []struct {
+		key     string
+		devices []ThrottleDevice
+	}{
+		{"rbps", conf.DeviceReadBps},
+		{"wbps", conf.DeviceWriteBps},
+		{"riops", conf.DeviceReadIops},
+		{"wiops", conf.DeviceWriteIops},
+	} {
+		for _, device := range item.devices {
+			id := fmt.Sprintf("%d:%d", device.Major, device.Minor)
+			if _, ok := limits[id]; !ok {
+				devices = append(devices, id)
+			}
+			// 0表示取消限制
+			rate := "max"
+			if device.Rate != 0 {
+				rate = strconv.FormatUint(device.Rate, 10)
+			}
+			limits[id] = append(limits[id], item.key+"="+rate)
+		}
+	}
+	lines := []string{}
+	for _, id := range devices {
+		lines = append(lines, id+" "+strings.Join(limits[id], " "))
+	}
+	return lines
+}
+
+// v1中-1表示不限制 v2需要写入对应的默认值
+func unifiedMemoryValue(value string, unlimited string) string {
+	if value == "-1" {
+		return unlimited
+	}
+	return value
+}
+
+// memory-swap是内存加swap的总量 v2的memory.swap.max只包含swap
+func swapMaxValue(memory string, memorySwap string) (string, error) {
+	if memorySwap == "-1" {
+		return "max", nil
+	}
+	total, err := common.ParseSize(memorySwap)
+	if err != nil {
+		return "", fmt.Errorf("invalid memory swap %s", memorySwap)
+	}
+	if memory == "" {
+		return "", fmt.Errorf("memory swap requires memory limit")
+	}
+	mem, err := common.ParseSize(memory)
+	if err != nil {
+		return "", fmt.Errorf("invalid memory %s", memory)
+	}
+	if total < mem {
+		return "", fmt.Errorf("memory swap %s must be larger than memory limit %s", memorySwap, memory)
+	}
+	return strconv.FormatInt(total-mem, 10), nil
+}
+
+// 资源组中因为超出内存限制被kill的进程数
+func readUnifiedOomKillCount(cgroupName string) (int64, error) {
+	cgfilepath, err := findAndCreateUnifiedPath(cgroupName, false)
+	if err != nil {
+		return 0, err
+	}
+	return readCgroupEvent(cgfilepath, memoryEventsFilename, "oom_kill")
+}
@@ -0,0 +1,72 @@
+package limit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIoMaxLines(t *testing.T) {
+	tests := []struct {
+		name string
+		conf ResourceConfig
+		want []string
+	}{
+		{name: "empty", conf: ResourceConfig{}, want: []string{}},
+		{
+			name: "one device",
+			conf: ResourceConfig{DeviceReadBps: []ThrottleDevice{{Major: 8, Minor: 0, Rate: 1048576}}},
+			want: []string{"8:0 rbps=1048576"},
+		},
+		{
+			name: "merged by device",
+			conf: ResourceConfig{
+				DeviceReadBps:   []ThrottleDevice{{Major: 8, Minor: 0, Rate: 1048576}},
+				DeviceWriteBps:  []ThrottleDevice{{Major: 8, Minor: 16, Rate: 2048}},
+				DeviceReadIops:  []ThrottleDevice{{Major: 8, Minor: 16, Rate: 10}},
+				DeviceWriteIops: []ThrottleDevice{{Major: 8, Minor: 0, Rate: 100}},
+			},
+			want: []string{"8:0 rbps=1048576 wiops=100", "8:16 wbps=2048 riops=10"},
+		},
+		{
+			name: "zero rate removes limit",
+			conf: ResourceConfig{DeviceWriteBps: []ThrottleDevice{{Major: 253, Minor: 1, Rate: 0}}},
+			want: []string{"253:1 wbps=max"},
+		},
+	}
+	for _, tt := range tests {
+		if got := ioMaxLines(&tt.conf); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ioMaxLines = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSwapMaxValue(t *testing.T) {
+	tests := []struct {
+		memory     string
+		memorySwap string
+		want       string
+		wantErr    bool
+	}{
+		{memory: "", memorySwap: "-1", want: "max"},
+		{memory: "536870912", memorySwap: "-1", want: "max"},
+		{memory: "536870912", memorySwap: "1073741824", want: "536870912"},
+		{memory: "512m", memorySwap: "1g", want: "536870912"},
+		{memory: "1g", memorySwap: "1g", want: "0"},
+		{memory: "1g", memorySwap: "512m", wantErr: true},
+		{memory: "", memorySwap: "1g", wantErr: true},
+		{memory: "1g", memorySwap: "lots", wantErr: true},
+		{memory: "much", memorySwap: "1g", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := swapMaxValue(tt.memory, tt.memorySwap)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("swapMaxValue(%q, %q) expected error, got %q", tt.memory, tt.memorySwap, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("swapMaxValue(%q, %q) = %q, %v, want %q", tt.memory, tt.memorySwap, got, err, tt.want)
+		}
+	}
+}
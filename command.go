@@ -1,18 +1,26 @@
 package main
 
 import (
+	"context"
 	"fmt"
 
-	"github.com/kehaha-5/go-low-level-container/cgroups/limit"
+	"github.com/kehaha-5/go-low-level-container/cgroups"
 	"github.com/kehaha-5/go-low-level-container/common"
 	"github.com/kehaha-5/go-low-level-container/container"
-	"github.com/kehaha-5/go-low-level-container/network"
-	_ "github.com/kehaha-5/go-low-level-container/nsenter"
+	"github.com/kehaha-5/go-low-level-container/daemon"
+	"github.com/kehaha-5/go-low-level-container/events"
+	"github.com/kehaha-5/go-low-level-container/mydocker"
 
 	"log/slog"
+	"math"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"text/tabwriter"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/urfave/cli"
 )
 
@@ -22,27 +30,85 @@ var RunCmd = cli.Command{
 	Flags: []cli.Flag{
 		cli.BoolFlag{
 			Name:  "it",
-			Usage: "Keep STDIN open even if not attached and Allocate a pseudo-TTY",
+			Usage: "Keep STDIN open even if not attached and Allocate a pseudo-TTY (the exited container is kept until rm, use --rm to remove it)",
 		},
 		cli.BoolFlag{
 			Name:  "d",
 			Usage: "detach container",
 		},
 		cli.IntFlag{
-			Name:  "cpushare",
+			Name:  "cpu-shares",
 			Usage: "CPU shares (relative weight)",
 		},
+		cli.StringFlag{
+			Name:  "cpus",
+			Usage: "Number of CPUs (e.g. 1.5)",
+		},
+		cli.Int64Flag{
+			Name:  "cpu-period",
+			Usage: "Limit CPU CFS (Completely Fair Scheduler) period",
+		},
+		cli.Int64Flag{
+			Name:  "cpu-quota",
+			Usage: "Limit CPU CFS (Completely Fair Scheduler) quota",
+		},
+		cli.StringFlag{
+			Name:  "cpuset-cpus",
+			Usage: "CPUs in which to allow execution (0-3, 0,1)",
+		},
+		cli.StringFlag{
+			Name:  "cpuset-mems",
+			Usage: "MEMs in which to allow execution (0-3, 0,1)",
+		},
+		cli.StringFlag{
+			Name:  "m,memory",
+			Usage: "Memory limit (e.g. 512m 1g)",
+		},
+		cli.StringFlag{
+			Name:  "memory-reservation",
+			Usage: "Memory soft limit",
+		},
+		cli.StringFlag{
+			Name:  "kernel-memory",
+			Usage: "Kernel memory limit (cgroup v1 only)",
+		},
+		cli.BoolFlag{
+			Name:  "oom-kill-disable",
+			Usage: "Disable OOM Killer (cgroup v1 only)",
+		},
 		cli.IntFlag{
-			Name:  "cpusset",
-			Usage: "CPUs in which to allow execution",
+			Name:  "oom-score-adj",
+			Usage: "Tune host's OOM preferences (-1000 to 1000)",
+		},
+		cli.StringFlag{
+			Name:  "memory-high",
+			Usage: "Memory usage throttle limit (cgroup v2 only)",
 		},
 		cli.StringFlag{
-			Name:  "m",
-			Usage: "Memory limit ",
+			Name:  "memory-swap",
+			Usage: "Swap limit equal to memory plus swap: -1 to enable unlimited swap",
+		},
+		cli.Int64Flag{
+			Name:  "pids-limit",
+			Usage: "Tune container pids limit (set -1 for unlimited)",
+		},
+		blkioWeightFlag,
+		deviceReadBpsFlag,
+		deviceWriteBpsFlag,
+		deviceReadIopsFlag,
+		deviceWriteIopsFlag,
+		hugepageLimitFlag,
+		cli.StringSliceFlag{
+			Name:  "ulimit",
+			Usage: "Ulimit options (e.g. nofile=1024:2048)",
+		},
+		cli.StringSliceFlag{
+			Name:  "sysctl",
+			Usage: "Namespaced sysctl options (e.g. net.core.somaxconn=1024)",
 		},
 		cli.StringSliceFlag{
 			Name:  "v",
-			Usage: "Bind mount a volume",
+			Usage: "Bind mount a volume (host:container, or volume:<name>:container for a named volume kept until volume prune)",
 		},
 		cli.StringFlag{
 			Name:  "name",
@@ -54,100 +120,396 @@ var RunCmd = cli.Command{
 		},
 		cli.StringFlag{
 			Name:  "net",
-			Usage: "set container network name",
+			Usage: "set container network name, or host|none|container:<name>",
+		},
+		cli.StringFlag{
+			Name:  "pid",
+			Usage: "PID namespace to use (host|container:<name>)",
+		},
+		cli.StringFlag{
+			Name:  "ipc",
+			Usage: "IPC mode to use (host|private|shareable|container:<name>)",
+		},
+		cli.StringFlag{
+			Name:  "uts",
+			Usage: "UTS namespace to use (host)",
+		},
+		cli.StringFlag{
+			Name:  "pod",
+			Usage: "run the container in a pod, sharing its network ipc and uts namespaces",
 		},
 		cli.StringFlag{
 			Name:  "p",
 			Usage: "set container prot mapping",
 		},
+		cli.StringSliceFlag{
+			Name:  "label",
+			Usage: "set metadata on container key=value",
+		},
+		cli.BoolFlag{
+			Name:  "rm",
+			Usage: "automatically remove the container when it exits (only for it, otherwise it is kept as exited like a detached container)",
+		},
+		cli.StringFlag{
+			Name:  "restart",
+			Usage: "restart policy applied by the daemon when the container exits (no|always|unless-stopped|on-failure[:max-retries])",
+			Value: container.RestartNo,
+		},
+		cli.BoolFlag{
+			Name:  "record",
+			Usage: "record the session in asciicast format (only for it)",
+		},
+		cli.StringFlag{
+			Name:  "cgroup-parent",
+			Usage: "Parent cgroup for the container",
+			Value: cgroups.DefaultCgroupParent,
+		},
+		cli.StringFlag{
+			Name:  "log-driver",
+			Usage: "log driver for container (json-file syslog gelf none)",
+			Value: "json-file",
+		},
+		cli.StringSliceFlag{
+			Name:  "log-opt",
+			Usage: "log driver options (json-file: max-size=10m max-file=3 compress=true syslog: syslog-address=udp://host:514 syslog-facility=daemon tag=app gelf: gelf-address=udp://host:12201 gelf-compression-type=gzip tag=app)",
+		},
 	},
 	Action: func(c *cli.Context) error {
 		if len(c.Args()) < 2 {
 			return fmt.Errorf("miss exec cmd")
 		}
-
-		var runArgs = &container.RunCommandArgs{
-			Tty:       c.Bool("it"),
-			VolumeArg: c.StringSlice("v"),
-			LimitResConf: &limit.ResourceConfig{
-				Cpu:    c.Int("c"),
-				Cpuset: c.Int("cs-c"),
-				Memory: c.String("m"),
-			},
-			CommandArgs:   c.Args()[1:],
-			Detach:        c.Bool("d"),
-			ContainerName: c.String("name"),
-			EnvList:       c.StringSlice("e"),
-			ImageName:     c.Args()[0],
-			Net:           c.String("net"),
-			PortMapping:   c.String("p"),
+		client, err := daemonClient(c)
+		if err != nil {
+			return err
+		}
+		if client != nil {
+			return runWithDaemon(c, client)
 		}
 
-		if runArgs.Tty && runArgs.Detach {
+		resources, err := parseResourceFlags(c)
+		if err != nil {
+			return err
+		}
+		resources.OomKillDisable = c.Bool("oom-kill-disable")
+		if c.Bool("it") && c.Bool("d") {
 			return fmt.Errorf("it and d param can not work together")
 		}
-
-		if err := container.RunContainer(runArgs); err != nil {
+		opts := &mydocker.ContainerOptions{
+			Name:        c.String("name"),
+			Image:       c.Args()[0],
+			Cmd:         c.Args()[1:],
+			Env:         c.StringSlice("e"),
+			Volumes:     c.StringSlice("v"),
+			Network:     c.String("net"),
+			Ports:       strings.Fields(c.String("p")),
+			Labels:      common.ParseLabels(c.StringSlice("label")),
+			Resources:   resources,
+			Restart:     c.String("restart"),
+			LogDriver:   c.String("log-driver"),
+			LogOpts:     c.StringSlice("log-opt"),
+			OomScoreAdj: c.Int("oom-score-adj"),
+			Ulimits:     c.StringSlice("ulimit"),
+			Sysctls:     c.StringSlice("sysctl"),
+			Pid:         c.String("pid"),
+			Ipc:         c.String("ipc"),
+			Uts:         c.String("uts"),
+			Pod:         c.String("pod"),
+		}
+		// 没有指定时由运行时决定 pod中的容器默认放在pod的资源组中
+		if c.IsSet("cgroup-parent") {
+			opts.CgroupParent = c.String("cgroup-parent")
+		}
+		run := &mydocker.RunOptions{
+			Tty:        c.Bool("it"),
+			AutoRemove: c.Bool("rm"),
+			Record:     c.Bool("record"),
+		}
+		info, err := rt.Run(cmdCtx, opts, run)
+		if err != nil {
 			return fmt.Errorf("run container error %+v", err)
 		}
+		if !run.Tty {
+			fmt.Fprintln(os.Stdout, info.Name)
+		}
 		return nil
 	},
 }
 
-var InitCmd = cli.Command{
-	Name:  "init",
+var ShimCmd = cli.Command{
+	Name:  daemon.ShimCommand,
 	Usage: "can not be useed outside",
 	Action: func(c *cli.Context) error {
-		if err := container.RunContainerProgram(); err != nil {
-			return fmt.Errorf("init error %+v", err)
+		if len(c.Args()) < 1 {
+			return fmt.Errorf("miss container name")
+		}
+		if err := daemon.RunShim(c.Args()[0]); err != nil {
+			return fmt.Errorf("shim error %+v", err)
 		}
 		return nil
 	},
 }
 
+var podCmd = cli.Command{
+	Name:  "pod",
+	Usage: "manage pods, containers in a pod share network ipc and uts namespaces",
+	Subcommands: []cli.Command{
+		{
+			Name:  "create",
+			Usage: "create and start a pod [name]",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "net",
+					Usage: "network of the pod",
+				},
+				cli.StringSliceFlag{
+					Name:  "p",
+					Usage: "port mapping of the pod (e.g. 8080:80)",
+				},
+				cli.StringSliceFlag{
+					Name:  "label",
+					Usage: "set metadata on pod key=value",
+				},
+			},
+			Action: func(context *cli.Context) error {
+				if len(context.Args()) < 1 {
+					return fmt.Errorf("missing pod name")
+				}
+				return rt.CreatePod(cmdCtx, &mydocker.PodOptions{
+					Name:    context.Args()[0],
+					Network: context.String("net"),
+					Ports:   context.StringSlice("p"),
+					Labels:  common.ParseLabels(context.StringSlice("label")),
+				})
+			},
+		}, {
+			Name:  "ls",
+			Usage: "list all pods",
+			Action: func(context *cli.Context) error {
+				return rt.WritePods(cmdCtx, os.Stdout)
+			},
+		}, {
+			Name:  "start",
+			Usage: "start pods and their containers [name...]",
+			Action: func(context *cli.Context) error {
+				if len(context.Args()) < 1 {
+					return fmt.Errorf("missing pod name")
+				}
+				for _, item := range context.Args() {
+					if err := rt.StartPod(cmdCtx, item); err != nil {
+						return fmt.Errorf("start pod err %v", err)
+					}
+				}
+				return nil
+			},
+		}, {
+			Name:  "stop",
+			Usage: "stop pods and their containers [name...]",
+			Action: func(context *cli.Context) error {
+				if len(context.Args()) < 1 {
+					return fmt.Errorf("missing pod name")
+				}
+				for _, item := range context.Args() {
+					if err := rt.StopPod(cmdCtx, item); err != nil {
+						return fmt.Errorf("stop pod err %v", err)
+					}
+				}
+				return nil
+			},
+		}, {
+			Name:  "rm",
+			Usage: "remove pods [name...]",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "f",
+					Usage: "stop the pod and remove its containers",
+				},
+			},
+			Action: func(context *cli.Context) error {
+				if len(context.Args()) < 1 {
+					return fmt.Errorf("missing pod name")
+				}
+				for _, item := range context.Args() {
+					if err := rt.RemovePod(cmdCtx, item, context.Bool("f")); err != nil {
+						return fmt.Errorf("rm pod err %v", err)
+					}
+				}
+				return nil
+			},
+		},
+	},
+}
+
+var projectFileFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "f,file",
+		Usage: "project file (yaml or json)",
+		Value: "project.yml",
+	},
+	cli.StringFlag{
+		Name:  "p,project-name",
+		Usage: "project name (default: name in the file or the directory name)",
+	},
+}
+
+func projectOptions(c *cli.Context) *mydocker.ProjectOptions {
+	return &mydocker.ProjectOptions{File: c.String("f"), Name: c.String("p")}
+}
+
+var projectCmd = cli.Command{
+	Name:  "project",
+	Usage: "manage multi-container projects described by a project file",
+	Subcommands: []cli.Command{
+		{
+			Name:  "up",
+			Usage: "create networks and containers of the project in dependency order",
+			Flags: projectFileFlags,
+			Action: func(context *cli.Context) error {
+				if err := rt.ProjectUp(cmdCtx, projectOptions(context)); err != nil {
+					return fmt.Errorf("project up err %+v", err)
+				}
+				return nil
+			},
+		}, {
+			Name:  "down",
+			Usage: "remove containers and networks of the project",
+			Flags: projectFileFlags,
+			Action: func(context *cli.Context) error {
+				if err := rt.ProjectDown(cmdCtx, projectOptions(context)); err != nil {
+					return fmt.Errorf("project down err %+v", err)
+				}
+				return nil
+			},
+		}, {
+			Name:  "ps",
+			Usage: "list containers of the project",
+			Flags: projectFileFlags,
+			Action: func(context *cli.Context) error {
+				return rt.WriteProject(cmdCtx, os.Stdout, projectOptions(context))
+			},
+		}, {
+			Name:  "logs",
+			Usage: "show logs of the project services [service...]",
+			Flags: append([]cli.Flag{
+				cli.BoolFlag{
+					Name:  "follow",
+					Usage: "follow log output until the containers exit",
+				},
+				cli.StringFlag{
+					Name:  "tail",
+					Usage: "number of lines to show from the end of the logs",
+					Value: "all",
+				},
+				cli.BoolFlag{
+					Name:  "t,timestamps",
+					Usage: "show timestamps",
+				},
+			}, projectFileFlags...),
+			Action: func(context *cli.Context) error {
+				opts := &mydocker.LogsOptions{
+					Follow:     context.Bool("follow"),
+					Tail:       context.String("tail"),
+					Timestamps: context.Bool("t"),
+				}
+				return rt.ProjectLogs(cmdCtx, projectOptions(context), context.Args(), opts, os.Stdout, os.Stderr)
+			},
+		},
+	},
+}
+
 var listContainer = cli.Command{
 	Name:  "ps",
 	Usage: "list all container",
 	Action: func(c *cli.Context) error {
-		isExist, err := common.PathExist(container.GetConfigSavePath())
-		if err != nil || !isExist {
-			return nil
+		client, err := daemonClient(c)
+		if err != nil {
+			return err
 		}
-		files, err := os.ReadDir(container.GetConfigSavePath())
+		if client != nil {
+			return listWithDaemon(client)
+		}
+		containers, err := rt.List(cmdCtx, &mydocker.ListOptions{All: true})
 		if err != nil {
-			return fmt.Errorf("read configfile error %v", err)
+			return err
 		}
 		w := tabwriter.NewWriter(os.Stdout, 12, 1, 5, ' ', tabwriter.TabIndent)
 		fmt.Fprint(w, "ID\tNAME\tPID\tSTATUS\tCOMMAND\tCREATED\n")
 
-		for _, file := range files {
-			var info container.ContainerInfos
-			if err := container.GetInfoByContainerName(file.Name(), &info); err != nil {
-				slog.Error("GetInfoByContainerName", "err", err)
-				continue
-			}
-			info.WirteInfoToTabwriter(w)
+		for _, item := range containers {
+			wirteContainerToTabwriter(w, item)
 		}
 		w.Flush()
 		return nil
 	},
 }
 
+var statsCmd = cli.Command{
+	Name:  "stats",
+	Usage: "display resource usage statistics of containers [name...]",
+	Action: func(c *cli.Context) error {
+		return rt.WriteStats(cmdCtx, os.Stdout, c.Args())
+	},
+}
+
 var logsContainer = cli.Command{
-	Name:  "log",
-	Usage: "show container log",
+	Name:    "log",
+	Aliases: []string{"logs"},
+	Usage:   "show container log",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "f",
+			Usage: "follow log output until the container exits",
+		},
+		cli.StringFlag{
+			Name:  "tail",
+			Usage: "number of lines to show from the end of the logs",
+			Value: "all",
+		},
+		cli.StringFlag{
+			Name:  "since",
+			Usage: "show logs since timestamp (e.g. 10m, 2006-01-02T15:04:05Z07:00)",
+		},
+		cli.StringFlag{
+			Name:  "until",
+			Usage: "show logs before timestamp (e.g. 10m, 2006-01-02T15:04:05Z07:00)",
+		},
+		cli.BoolFlag{
+			Name:  "t,timestamps",
+			Usage: "show timestamps",
+		},
+		cli.BoolFlag{
+			Name:  "stdout",
+			Usage: "only show stdout",
+		},
+		cli.BoolFlag{
+			Name:  "stderr",
+			Usage: "only show stderr",
+		},
+	},
 	Action: func(c *cli.Context) error {
 		slog.Info("log command")
 		if len(c.Args()) == 0 {
-			slog.Error("too less args to run log command")
-			return nil
+			return fmt.Errorf("specify container name")
 		}
 		containerName := c.Args()[0]
-		log, err := container.GetLogByContainerName(containerName)
+		client, err := daemonClient(c)
 		if err != nil {
-			slog.Error("GetLogByContainerName", "err", err)
+			return err
+		}
+		if client != nil {
+			return logsWithDaemon(c, client, containerName)
+		}
+		opts := &mydocker.LogsOptions{
+			Follow:     c.Bool("f"),
+			Tail:       c.String("tail"),
+			Since:      c.String("since"),
+			Until:      c.String("until"),
+			Timestamps: c.Bool("t"),
+			Stdout:     c.Bool("stdout"),
+			Stderr:     c.Bool("stderr"),
+		}
+		if err := rt.Logs(cmdCtx, containerName, opts, os.Stdout, os.Stderr); err != nil {
+			return fmt.Errorf("logs err %v", err)
 		}
-		fmt.Fprintln(os.Stdout, log)
 		return nil
 	},
 }
@@ -160,6 +522,10 @@ var execContainer = cli.Command{
 			Name:  "it",
 			Usage: "Keep STDIN open even if not attached and Allocate a pseudo-TTY",
 		},
+		cli.BoolFlag{
+			Name:  "record",
+			Usage: "record the session in asciicast format (only for it)",
+		},
 	},
 	Action: func(c *cli.Context) error {
 		//This is for callback
@@ -172,13 +538,58 @@ var execContainer = cli.Command{
 		}
 		containerName := c.Args()[0]
 		containerCmd := c.Args()[1:]
-		if err := container.Exce(containerName, containerCmd, c.Bool("it")); err != nil {
+		exitCode, err := rt.Exec(cmdCtx, containerName, containerCmd, &mydocker.ExecOptions{
+			Tty:    c.Bool("it"),
+			Record: c.Bool("record"),
+			Stdout: os.Stdout,
+			Stderr: os.Stderr,
+		})
+		if err != nil {
 			return fmt.Errorf("exec err %v", err)
 		}
+		if exitCode != 0 {
+			return fmt.Errorf("exec err exit status %d", exitCode)
+		}
 		return nil
 	},
 }
 
+var replayCmd = cli.Command{
+	Name:  "replay",
+	Usage: "replay a recorded session of container [name] [session]",
+	Flags: []cli.Flag{
+		cli.Float64Flag{
+			Name:  "speed",
+			Usage: "playback speed multiplier",
+			Value: 1,
+		},
+		cli.DurationFlag{
+			Name:  "idle-limit",
+			Usage: "limit idle time between output (e.g. 2s)",
+		},
+		cli.BoolFlag{
+			Name:  "l,list",
+			Usage: "list recorded sessions",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if len(c.Args()) == 0 {
+			return fmt.Errorf("miss container name")
+		}
+		if c.Bool("l") {
+			sessions, err := rt.Sessions(cmdCtx, c.Args()[0])
+			if err != nil {
+				return err
+			}
+			for _, session := range sessions {
+				fmt.Fprintln(os.Stdout, session)
+			}
+			return nil
+		}
+		return rt.Replay(cmdCtx, c.Args()[0], c.Args().Get(1), c.Float64("speed"), c.Duration("idle-limit"), os.Stdout)
+	},
+}
+
 var stopContainer = cli.Command{
 	Name:  "stop",
 	Usage: "stop container name",
@@ -186,9 +597,17 @@ var stopContainer = cli.Command{
 		if len(c.Args()) == 0 {
 			return fmt.Errorf("less cmd too run")
 		}
+		client, err := daemonClient(c)
+		if err != nil {
+			return err
+		}
 		containerName := c.Args()
 		for _, itme := range containerName {
-			err := container.StopContainerByName(itme)
+			if client != nil {
+				err = client.ContainerStop(itme)
+			} else {
+				err = rt.Stop(cmdCtx, itme)
+			}
 			if err != nil {
 				return fmt.Errorf("stop err %v", err)
 			}
@@ -210,9 +629,17 @@ var rmContainer = cli.Command{
 		if len(c.Args()) == 0 {
 			return fmt.Errorf("specify container name")
 		}
+		client, err := daemonClient(c)
+		if err != nil {
+			return err
+		}
 		containerName := c.Args()
 		for _, itme := range containerName {
-			err := container.Rm(itme, c.Bool("f"))
+			if client != nil {
+				err = client.ContainerRemove(itme, c.Bool("f"))
+			} else {
+				err = rt.Remove(cmdCtx, itme, &mydocker.RemoveOptions{Force: c.Bool("f")})
+			}
 			if err != nil {
 				slog.Error("rm", "error", fmt.Errorf("name %s err %v", itme, err))
 			}
@@ -234,7 +661,7 @@ var commitContainer = cli.Command{
 		if len(c.Args()) == 0 {
 			return fmt.Errorf("specify container name")
 		}
-		if err := container.ExportCommitContainer(c.Args().Get(0), c.String("o")); err != nil {
+		if err := rt.Export(cmdCtx, c.Args().Get(0), c.String("o")); err != nil {
 			return fmt.Errorf("export %v", err)
 		}
 		return nil
@@ -259,15 +686,34 @@ var networkCmd = cli.Command{
 					Usage:    "subnet cidr",
 					Required: true,
 				},
+				cli.StringSliceFlag{
+					Name:  "label",
+					Usage: "set metadata on network key=value",
+				},
 			},
 			Action: func(context *cli.Context) error {
 				if len(context.Args()) < 1 {
 					return fmt.Errorf("missing network name")
 				}
-				if err := network.Init(); err != nil {
-					return fmt.Errorf("network init error: %+v", err)
+				client, err := daemonClient(context)
+				if err != nil {
+					return err
+				}
+				if client != nil {
+					_, err := client.NetworkCreate(&daemon.NetworkCreateRequest{
+						Name:   context.Args()[0],
+						Driver: context.String("d"),
+						IPAM:   daemon.IPAM{Config: []daemon.IPAMConfig{{Subnet: context.String("subnet")}}},
+						Labels: common.ParseLabels(context.StringSlice("label")),
+					})
+					return err
 				}
-				err := network.CreateNetwork(context.String("d"), context.String("subnet"), context.Args()[0])
+				_, err = rt.CreateNetwork(cmdCtx, &mydocker.NetworkOptions{
+					Name:   context.Args()[0],
+					Driver: context.String("d"),
+					Subnet: context.String("subnet"),
+					Labels: common.ParseLabels(context.StringSlice("label")),
+				})
 				if err != nil {
 					return fmt.Errorf("create network error: %+v", err)
 				}
@@ -277,12 +723,33 @@ var networkCmd = cli.Command{
 			Name:  "ls",
 			Usage: "list all container network",
 			Action: func(context *cli.Context) error {
-				if err := network.Init(); err != nil {
-					return fmt.Errorf("network init error: %+v", err)
+				client, err := daemonClient(context)
+				if err != nil {
+					return err
 				}
 				w := tabwriter.NewWriter(os.Stdout, 12, 1, 5, ' ', tabwriter.TabIndent)
 				fmt.Fprint(w, "ID\tNAME\tIpRange\tDriver\n")
-				network.ShowAllNetworks(w)
+				if client != nil {
+					networks, err := client.NetworkList()
+					if err != nil {
+						return err
+					}
+					for _, n := range networks {
+						ipRange := ""
+						if len(n.IPAM.Config) != 0 {
+							ipRange = n.IPAM.Config[0].Subnet
+						}
+						fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", n.Id, n.Name, ipRange, n.Driver)
+					}
+					return w.Flush()
+				}
+				networks, err := rt.Networks(cmdCtx)
+				if err != nil {
+					return err
+				}
+				for _, n := range networks {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", n.Id, n.Name, n.Subnet, n.Driver)
+				}
 				w.Flush()
 				return nil
 			},
@@ -293,16 +760,34 @@ var networkCmd = cli.Command{
 				if len(context.Args()) < 1 {
 					return fmt.Errorf("missing network name")
 				}
-				if err := network.Init(); err != nil {
-					return fmt.Errorf("network init error: %+v", err)
+				client, err := daemonClient(context)
+				if err != nil {
+					return err
+				}
+				if client != nil {
+					for _, item := range context.Args() {
+						if err := client.NetworkRemove(item); err != nil {
+							return err
+						}
+					}
+					return nil
 				}
 				for _, item := range context.Args() {
-					if err := network.RemoveNetwork(item); err != nil {
+					if err := rt.RemoveNetwork(cmdCtx, item); err != nil {
 						return err
 					}
 				}
 				return nil
 			},
+		}, {
+			Name:  "prune",
+			Usage: "remove all unused networks",
+			Flags: []cli.Flag{
+				pruneFilterFlag,
+			},
+			Action: func(context *cli.Context) error {
+				return pruneNetworks(context.StringSlice("filter"))
+			},
 		},
 	},
 }
@@ -310,13 +795,36 @@ var networkCmd = cli.Command{
 var startCmd = cli.Command{
 	Name:  "start",
 	Usage: "restart container name ",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "checkpoint",
+			Usage: "restore from this checkpoint",
+		},
+	},
 	Action: func(c *cli.Context) error {
 		if len(c.Args()) == 0 {
 			return fmt.Errorf("specify container name")
 		}
 		containerName := c.Args()
+		if checkpointId := c.String("checkpoint"); checkpointId != "" {
+			if len(containerName) != 1 {
+				return fmt.Errorf("checkpoint can only restore one container")
+			}
+			if err := rt.Restore(cmdCtx, containerName[0], checkpointId); err != nil {
+				return fmt.Errorf("restore err %v", err)
+			}
+			return nil
+		}
+		client, err := daemonClient(c)
+		if err != nil {
+			return err
+		}
 		for _, itme := range containerName {
-			err := container.StartContainerByName(itme)
+			if client != nil {
+				err = client.ContainerStart(itme)
+			} else {
+				err = rt.Start(cmdCtx, itme)
+			}
 			if err != nil {
 				return fmt.Errorf("restart err %v", err)
 			}
@@ -334,7 +842,7 @@ var restartCmd = cli.Command{
 		}
 		containerName := c.Args()
 		for _, itme := range containerName {
-			err := container.RestartContainer(itme)
+			err := rt.Restart(cmdCtx, itme)
 			if err != nil {
 				return fmt.Errorf("restart err %v", err)
 			}
@@ -351,22 +859,41 @@ var loadCmd = cli.Command{
 			return fmt.Errorf("specify imagefilepath")
 		}
 		imagefilepath := c.Args().Get(0)
-		return container.LoadImage(imagefilepath)
+		return rt.LoadImage(cmdCtx, imagefilepath)
 	},
 }
 
 var imagesCmd = cli.Command{
-	Name:  "images",
-	Usage: "container images commands",
+	Name:    "images",
+	Aliases: []string{"image"},
+	Usage:   "container images commands",
 	Subcommands: []cli.Command{
 		{
 			Name:  "ls",
 			Usage: "list all container images",
 			Action: func(context *cli.Context) error {
+				client, err := daemonClient(context)
+				if err != nil {
+					return err
+				}
 				w := tabwriter.NewWriter(os.Stdout, 12, 1, 5, ' ', tabwriter.TabIndent)
 				fmt.Fprint(w, "ID\tNAME\tSize\tCREATED\n")
-				if err := container.WirteImagesInfoToTabwriter(w); err != nil {
-					return err
+				if client != nil {
+					images, err := client.ImageList()
+					if err != nil {
+						return err
+					}
+					for _, item := range images {
+						fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", item.Id, strings.Join(item.RepoTags, ","), common.SizeHumanReadable(item.Size), time.Unix(item.Created, 0).Format(time.RFC3339))
+					}
+				} else {
+					images, err := rt.Images(cmdCtx)
+					if err != nil {
+						return err
+					}
+					for _, item := range images {
+						fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", item.Id, item.Name, item.Size, item.Created.Format(time.RFC3339))
+					}
 				}
 				w.Flush()
 				return nil
@@ -379,9 +906,670 @@ var imagesCmd = cli.Command{
 				if len(context.Args()) == 0 {
 					return fmt.Errorf("specify image name")
 				}
-				imageNames := context.Args()
-				return container.DelImageByName(imageNames)
+				for _, item := range context.Args() {
+					image, err := rt.RemoveImage(cmdCtx, item)
+					if errors.Is(err, mydocker.ErrNotFound) {
+						slog.Info(fmt.Sprintf("delete image name %s not existed", item))
+						continue
+					}
+					if err != nil {
+						return err
+					}
+					fmt.Println(image.Name)
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "prune",
+			Usage: "remove unused image layers",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "a",
+					Usage: "remove all images not used by any container",
+				},
+				pruneFilterFlag,
+			},
+			Action: func(context *cli.Context) error {
+				report, err := rt.PruneImages(cmdCtx, context.StringSlice("filter"), context.Bool("a"))
+				if err != nil {
+					return fmt.Errorf("prune images err %v", err)
+				}
+				report.WirteReport(os.Stdout, "Images")
+				return nil
+			},
+		},
+	},
+}
+
+var updateCmd = cli.Command{
+	Name:  "update",
+	Usage: "update [Option] container name ...",
+	Flags: updateResourceFlags,
+	Action: func(c *cli.Context) error {
+		if len(c.Args()) == 0 {
+			return fmt.Errorf("specify container name")
+		}
+		res, err := parseResourceFlags(c)
+		if err != nil {
+			return err
+		}
+		for _, itme := range c.Args() {
+			if err := rt.Update(cmdCtx, itme, res); err != nil {
+				return fmt.Errorf("update err %v", err)
+			}
+		}
+		return nil
+	},
+}
+
+// update和cgroup update共用的资源限制参数
+var updateResourceFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "memory",
+		Usage: "Memory limit (e.g. 512m 1g), -1 to remove the limit",
+	},
+	cli.StringFlag{
+		Name:  "memory-reservation",
+		Usage: "Memory soft limit, -1 to remove the limit",
+	},
+	cli.StringFlag{
+		Name:  "kernel-memory",
+		Usage: "Kernel memory limit (cgroup v1 only), -1 to remove the limit",
+	},
+	cli.StringFlag{
+		Name:  "memory-high",
+		Usage: "Memory usage throttle limit (cgroup v2 only), -1 to remove the limit",
+	},
+	cli.StringFlag{
+		Name:  "memory-swap",
+		Usage: "Swap limit equal to memory plus swap: -1 to enable unlimited swap",
+	},
+	cli.IntFlag{
+		Name:  "cpu-shares",
+		Usage: "CPU shares (relative weight)",
+	},
+	cli.StringFlag{
+		Name:  "cpus",
+		Usage: "Number of CPUs, -1 to remove the cpu quota",
+	},
+	cli.Int64Flag{
+		Name:  "cpu-period",
+		Usage: "Limit CPU CFS (Completely Fair Scheduler) period",
+	},
+	cli.Int64Flag{
+		Name:  "cpu-quota",
+		Usage: "Limit CPU CFS (Completely Fair Scheduler) quota, -1 to remove the limit",
+	},
+	cli.StringFlag{
+		Name:  "cpuset-cpus",
+		Usage: "CPUs in which to allow execution (0-3, 0,1)",
+	},
+	cli.StringFlag{
+		Name:  "cpuset-mems",
+		Usage: "MEMs in which to allow execution (0-3, 0,1)",
+	},
+	cli.Int64Flag{
+		Name:  "pids-limit",
+		Usage: "Tune container pids limit (set -1 for unlimited)",
+	},
+	blkioWeightFlag,
+	deviceReadBpsFlag,
+	deviceWriteBpsFlag,
+	deviceReadIopsFlag,
+	deviceWriteIopsFlag,
+	hugepageLimitFlag,
+}
+
+// 内存大小和设备参数由运行时解析
+func parseResourceFlags(c *cli.Context) (*mydocker.Resources, error) {
+	blkioWeight, err := parseBlkioWeight(c)
+	if err != nil {
+		return nil, err
+	}
+	return &mydocker.Resources{
+		CpuShares:         c.Int("cpu-shares"),
+		Cpus:              c.String("cpus"),
+		CpuPeriod:         c.Int64("cpu-period"),
+		CpuQuota:          c.Int64("cpu-quota"),
+		CpusetCpus:        c.String("cpuset-cpus"),
+		CpusetMems:        c.String("cpuset-mems"),
+		Memory:            c.String("memory"),
+		MemoryHigh:        c.String("memory-high"),
+		MemorySwap:        c.String("memory-swap"),
+		MemoryReservation: c.String("memory-reservation"),
+		KernelMemory:      c.String("kernel-memory"),
+		PidsLimit:         c.Int64("pids-limit"),
+		BlkioWeight:       blkioWeight,
+		DeviceReadBps:     c.StringSlice("device-read-bps"),
+		DeviceWriteBps:    c.StringSlice("device-write-bps"),
+		DeviceReadIops:    c.StringSlice("device-read-iops"),
+		DeviceWriteIops:   c.StringSlice("device-write-iops"),
+		HugepageLimits:    c.StringSlice("hugepage-limit"),
+	}, nil
+}
+
+var cgroupCmd = cli.Command{
+	Name:  "cgroup",
+	Usage: "manage cgroup parents which group containers",
+	Subcommands: []cli.Command{
+		{
+			Name:  "ls",
+			Usage: "list cgroup parents used by containers",
+			Action: func(c *cli.Context) error {
+				return rt.WriteCgroups(cmdCtx, os.Stdout)
+			},
+		}, {
+			Name:  "update",
+			Usage: "update [Option] parent, limits are shared by all containers in the parent",
+			Flags: updateResourceFlags,
+			Action: func(c *cli.Context) error {
+				if len(c.Args()) == 0 {
+					return fmt.Errorf("specify cgroup parent")
+				}
+				res, err := parseResourceFlags(c)
+				if err != nil {
+					return err
+				}
+				return rt.UpdateCgroup(cmdCtx, c.Args()[0], res)
+			},
+		}, {
+			Name:  "stats",
+			Usage: "display resource usage of all containers in the parent",
+			Action: func(c *cli.Context) error {
+				if len(c.Args()) == 0 {
+					return fmt.Errorf("specify cgroup parent")
+				}
+				return rt.WriteCgroupStats(cmdCtx, os.Stdout, c.Args()[0])
+			},
+		}, {
+			Name:  "rm",
+			Usage: "remove the parent with its sub cgroups and limits",
+			Action: func(c *cli.Context) error {
+				if len(c.Args()) == 0 {
+					return fmt.Errorf("specify cgroup parent")
+				}
+				for _, item := range c.Args() {
+					if err := rt.RemoveCgroup(cmdCtx, item); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+	},
+}
+
+var (
+	blkioWeightFlag = cli.UintFlag{
+		Name:  "blkio-weight",
+		Usage: "Block IO (relative weight), between 10 and 1000",
+	}
+	deviceReadBpsFlag = cli.StringSliceFlag{
+		Name:  "device-read-bps",
+		Usage: "Limit read rate (bytes per second) from a device (e.g. /dev/sda:1mb), rate 0 removes the limit",
+	}
+	deviceWriteBpsFlag = cli.StringSliceFlag{
+		Name:  "device-write-bps",
+		Usage: "Limit write rate (bytes per second) to a device (e.g. /dev/sda:1mb), rate 0 removes the limit",
+	}
+	deviceReadIopsFlag = cli.StringSliceFlag{
+		Name:  "device-read-iops",
+		Usage: "Limit read rate (IO per second) from a device (e.g. /dev/sda:1000), rate 0 removes the limit",
+	}
+	deviceWriteIopsFlag = cli.StringSliceFlag{
+		Name:  "device-write-iops",
+		Usage: "Limit write rate (IO per second) to a device (e.g. /dev/sda:1000), rate 0 removes the limit",
+	}
+	hugepageLimitFlag = cli.StringSliceFlag{
+		Name:  "hugepage-limit",
+		Usage: "Limit hugepage usage of a page size (e.g. 2MB:1G)",
+	}
+)
+
+// 具体的范围由cgroup检查 这里只保证不溢出
+func parseBlkioWeight(c *cli.Context) (uint16, error) {
+	if c.Uint("blkio-weight") > math.MaxUint16 {
+		return 0, fmt.Errorf("invalid blkio weight %d", c.Uint("blkio-weight"))
+	}
+	return uint16(c.Uint("blkio-weight")), nil
+}
+
+var pruneFilterFlag = cli.StringSliceFlag{
+	Name:  "filter",
+	Usage: "provide filter values (e.g. until=24h label=key=value label!=key)",
+}
+
+var containerCmd = cli.Command{
+	Name:  "container",
+	Usage: "container commands",
+	Subcommands: []cli.Command{
+		{
+			Name:  "prune",
+			Usage: "remove all stopped containers",
+			Flags: []cli.Flag{
+				pruneFilterFlag,
+			},
+			Action: func(context *cli.Context) error {
+				report, err := rt.PruneContainers(cmdCtx, context.StringSlice("filter"))
+				if err != nil {
+					return fmt.Errorf("prune containers err %v", err)
+				}
+				report.WirteReport(os.Stdout, "Containers")
+				return nil
+			},
+		},
+	},
+}
+
+var volumeCmd = cli.Command{
+	Name:  "volume",
+	Usage: "container volume commands",
+	Subcommands: []cli.Command{
+		{
+			Name:  "prune",
+			Usage: "remove all unused named volumes",
+			Flags: []cli.Flag{
+				pruneFilterFlag,
+			},
+			Action: func(context *cli.Context) error {
+				report, err := rt.PruneVolumes(cmdCtx, context.StringSlice("filter"))
+				if err != nil {
+					return fmt.Errorf("prune volumes err %v", err)
+				}
+				report.WirteReport(os.Stdout, "Volumes")
+				return nil
 			},
 		},
 	},
 }
+
+var systemCmd = cli.Command{
+	Name:  "system",
+	Usage: "manage mydocker",
+	Subcommands: []cli.Command{
+		{
+			Name:  "prune",
+			Usage: "remove stopped containers, unused networks and image layers",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "a",
+					Usage: "remove all images not used by any container",
+				},
+				cli.BoolFlag{
+					Name:  "volumes",
+					Usage: "prune named volumes",
+				},
+				pruneFilterFlag,
+			},
+			Action: func(context *cli.Context) error {
+				filters := context.StringSlice("filter")
+				total := &mydocker.PruneReport{}
+				containerReport, err := rt.PruneContainers(cmdCtx, filters)
+				if err != nil {
+					return fmt.Errorf("prune containers err %v", err)
+				}
+				containerReport.WirteDeleted(os.Stdout, "Containers")
+				total.SpaceReclaimed += containerReport.SpaceReclaimed
+
+				if err := pruneNetworks(filters); err != nil {
+					return err
+				}
+
+				if context.Bool("volumes") {
+					volumeReport, err := rt.PruneVolumes(cmdCtx, filters)
+					if err != nil {
+						return fmt.Errorf("prune volumes err %v", err)
+					}
+					volumeReport.WirteDeleted(os.Stdout, "Volumes")
+					total.SpaceReclaimed += volumeReport.SpaceReclaimed
+				}
+
+				imageReport, err := rt.PruneImages(cmdCtx, filters, context.Bool("a"))
+				if err != nil {
+					return fmt.Errorf("prune images err %v", err)
+				}
+				imageReport.WirteDeleted(os.Stdout, "Images")
+				total.SpaceReclaimed += imageReport.SpaceReclaimed
+				total.WirteReport(os.Stdout, "")
+				return nil
+			},
+		},
+		{
+			Name:  "repair",
+			Usage: "repair stale container state after crashes or reboots",
+			Action: func(context *cli.Context) error {
+				actions, err := rt.Repair(cmdCtx)
+				for _, item := range actions {
+					fmt.Fprintln(os.Stdout, item)
+				}
+				if err != nil {
+					return fmt.Errorf("repair err %+v", err)
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "df",
+			Usage: "show mydocker disk usage",
+			Action: func(context *cli.Context) error {
+				return rt.WriteDiskUsage(cmdCtx, os.Stdout)
+			},
+		},
+		{
+			Name:  "defaults",
+			Usage: "show or set defaults for new containers",
+			Flags: []cli.Flag{
+				cli.StringSliceFlag{
+					Name:  "default-ulimit",
+					Usage: "default ulimit for new containers (e.g. nofile=1024:2048)",
+				},
+				cli.BoolFlag{
+					Name:  "reset",
+					Usage: "drop the current default ulimits before setting",
+				},
+			},
+			Action: func(context *cli.Context) error {
+				ulimits := context.StringSlice("default-ulimit")
+				if len(ulimits) != 0 || context.Bool("reset") {
+					if err := rt.SetDefaultUlimits(cmdCtx, ulimits, context.Bool("reset")); err != nil {
+						return err
+					}
+				}
+				defaults, err := rt.DefaultUlimits(cmdCtx)
+				if err != nil {
+					return err
+				}
+				for _, item := range defaults {
+					fmt.Fprintf(os.Stdout, "ulimit %s\n", item)
+				}
+				return nil
+			},
+		},
+	},
+}
+
+func pruneNetworks(filters []string) error {
+	report, err := rt.PruneNetworks(cmdCtx, filters)
+	if err != nil {
+		return fmt.Errorf("prune networks err %v", err)
+	}
+	report.WirteDeleted(os.Stdout, "Networks")
+	return nil
+}
+
+var eventsCmd = cli.Command{
+	Name:  "events",
+	Usage: "get real time events from the container lifecycle journal",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "since",
+			Usage: "show all events created since timestamp (e.g. 10m, 2006-01-02T15:04:05Z07:00)",
+		},
+		cli.StringFlag{
+			Name:  "until",
+			Usage: "stream events until this timestamp",
+		},
+		cli.StringSliceFlag{
+			Name:  "filter",
+			Usage: "filter output based on conditions provided (type= event= container= network= image=)",
+		},
+		cli.BoolFlag{
+			Name:  "f",
+			Usage: "follow new events",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		filter, err := events.ParseFilter(c.String("since"), c.String("until"), c.Bool("f"), c.StringSlice("filter"))
+		if err != nil {
+			return err
+		}
+		return events.WriteEvents(os.Stdout, filter)
+	},
+}
+
+var checkpointCmd = cli.Command{
+	Name:  "checkpoint",
+	Usage: "manage checkpoints",
+	Subcommands: []cli.Command{
+		{
+			Name:  "create",
+			Usage: "create a checkpoint from a running container [name] [checkpoint]",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "leave-running",
+					Usage: "leave the container running after checkpoint",
+				},
+			},
+			Action: func(context *cli.Context) error {
+				if len(context.Args()) < 2 {
+					return fmt.Errorf("specify container name and checkpoint id")
+				}
+				if err := rt.Checkpoint(cmdCtx, context.Args()[0], context.Args()[1], context.Bool("leave-running")); err != nil {
+					return fmt.Errorf("checkpoint err %v", err)
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "ls",
+			Usage: "list checkpoints of a container [name]",
+			Action: func(context *cli.Context) error {
+				if len(context.Args()) == 0 {
+					return fmt.Errorf("specify container name")
+				}
+				return rt.WriteCheckpoints(cmdCtx, os.Stdout, context.Args()[0])
+			},
+		},
+		{
+			Name:  "rm",
+			Usage: "remove a checkpoint [name] [checkpoint]",
+			Action: func(context *cli.Context) error {
+				if len(context.Args()) < 2 {
+					return fmt.Errorf("specify container name and checkpoint id")
+				}
+				return rt.RemoveCheckpoint(cmdCtx, context.Args()[0], context.Args()[1])
+			},
+		},
+	},
+}
+
+var daemonCmd = cli.Command{
+	Name:  "daemon",
+	Usage: "run the daemon serving the rest api on a unix socket",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "socket",
+			Usage: "unix socket to listen on",
+			Value: daemon.DefaultSocket,
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if err := daemon.Run(c.String("socket")); err != nil {
+			return fmt.Errorf("daemon error %+v", err)
+		}
+		return nil
+	},
+}
+
+var waitCmd = cli.Command{
+	Name:  "wait",
+	Usage: "block until containers stop, then print their exit codes [name...]",
+	Action: func(c *cli.Context) error {
+		if len(c.Args()) == 0 {
+			return fmt.Errorf("specify container name")
+		}
+		client, err := daemonClient(c)
+		if err != nil {
+			return err
+		}
+		for _, item := range c.Args() {
+			var exitCode int
+			if client != nil {
+				exitCode, err = client.ContainerWait(item)
+			} else {
+				exitCode, err = rt.Wait(cmdCtx, item)
+			}
+			if err != nil {
+				return fmt.Errorf("wait err %v", err)
+			}
+			fmt.Fprintln(os.Stdout, exitCode)
+		}
+		return nil
+	},
+}
+
+// 本地执行命令时使用的运行时 在app.Before中创建
+var rt *mydocker.Runtime
+
+// 命令行的操作不需要超时 中断时直接结束进程
+var cmdCtx = context.Background()
+
+func wirteContainerToTabwriter(w *tabwriter.Writer, item *mydocker.Container) {
+	// "ID\tNAME\tPID\tSTATUS\tCOMMAND\tCREATED\n"
+	id, pid, status, created := item.Id, "", item.Status, ""
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	if item.Pid != 0 {
+		pid = strconv.Itoa(item.Pid)
+	}
+	if item.OOMKilled && item.Status != mydocker.StatusRunning {
+		status += " (OOMKilled)"
+	}
+	if !item.Created.IsZero() {
+		created = item.Created.Format(time.RFC3339)
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", id, item.Name, pid, status, item.Command, created)
+}
+
+// 设置了--host时命令通过daemon执行 否则返回nil
+func daemonClient(c *cli.Context) (*daemon.Client, error) {
+	host := c.GlobalString("host")
+	if host == "" {
+		return nil, nil
+	}
+	return daemon.NewClient(host)
+}
+
+// 通过daemon运行容器 只支持后台运行和部分参数
+func runWithDaemon(c *cli.Context, client *daemon.Client) error {
+	supported := map[string]bool{
+		"d": true, "name": true, "e": true, "v": true, "net": true, "p": true, "label": true,
+		"restart": true, "m": true, "cpus": true, "cpu-shares": true, "pids-limit": true, "cgroup-parent": true,
+	}
+	for _, name := range c.FlagNames() {
+		if c.IsSet(name) && !supported[name] {
+			return fmt.Errorf("%s param is not supported by the daemon", name)
+		}
+	}
+	if !c.Bool("d") {
+		return fmt.Errorf("only detached containers can run through the daemon")
+	}
+	restart, err := container.ParseRestartPolicy(c.String("restart"))
+	if err != nil {
+		return err
+	}
+	config := &daemon.ContainerCreateConfig{
+		Image:  c.Args()[0],
+		Cmd:    c.Args()[1:],
+		Env:    c.StringSlice("e"),
+		Labels: common.ParseLabels(c.StringSlice("label")),
+		HostConfig: daemon.HostConfig{
+			Binds:         c.StringSlice("v"),
+			NetworkMode:   c.String("net"),
+			PortBindings:  map[string][]daemon.PortBinding{},
+			RestartPolicy: daemon.RestartPolicy{Name: restart.Name, MaximumRetryCount: restart.MaximumRetryCount},
+			CpuShares:     c.Int("cpu-shares"),
+			PidsLimit:     c.Int64("pids-limit"),
+		},
+	}
+	if c.IsSet("cgroup-parent") {
+		config.HostConfig.CgroupParent = c.String("cgroup-parent")
+	}
+	for _, mapping := range strings.Fields(c.String("p")) {
+		host, port, ok := strings.Cut(mapping, ":")
+		if !ok {
+			return fmt.Errorf("invalid port mapping %s", mapping)
+		}
+		config.HostConfig.PortBindings[port+"/tcp"] = append(config.HostConfig.PortBindings[port+"/tcp"], daemon.PortBinding{HostPort: host})
+	}
+	if memory := c.String("m"); memory != "" {
+		if config.HostConfig.Memory, err = common.ParseSize(memory); err != nil {
+			return err
+		}
+	}
+	if cpus := c.String("cpus"); cpus != "" {
+		n, err := strconv.ParseFloat(cpus, 64)
+		if err != nil {
+			return fmt.Errorf("invalid cpus %s", cpus)
+		}
+		config.HostConfig.NanoCpus = int64(n * 1e9)
+	}
+
+	created, err := client.ContainerCreate(c.String("name"), config)
+	if err != nil {
+		return fmt.Errorf("create container error %v", err)
+	}
+	if err := client.ContainerStart(created.Id); err != nil {
+		return fmt.Errorf("start container error %v", err)
+	}
+	info, err := client.ContainerInspect(created.Id)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stdout, strings.TrimPrefix(info.Name, "/"))
+	return nil
+}
+
+func listWithDaemon(client *daemon.Client) error {
+	summaries, err := client.ContainerList(true)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 12, 1, 5, ' ', tabwriter.TabIndent)
+	fmt.Fprint(w, "ID\tNAME\tIMAGE\tSTATUS\tCOMMAND\tCREATED\n")
+	for _, item := range summaries {
+		id := item.Id
+		if len(id) > 12 {
+			id = id[:12]
+		}
+		name := ""
+		if len(item.Names) != 0 {
+			name = strings.TrimPrefix(item.Names[0], "/")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", id, name, item.Image, item.Status, item.Command, time.Unix(item.Created, 0).Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+func logsWithDaemon(c *cli.Context, client *daemon.Client, containerName string) error {
+	query := url.Values{}
+	query.Set("tail", c.String("tail"))
+	for _, item := range []struct {
+		key   string
+		value string
+	}{
+		{"since", c.String("since")},
+		{"until", c.String("until")},
+	} {
+		if item.value == "" {
+			continue
+		}
+		t, err := common.ParseTime(item.value)
+		if err != nil {
+			return err
+		}
+		query.Set(item.key, strconv.FormatInt(t.Unix(), 10))
+	}
+	// 都没有指定时输出两个流
+	stdout, stderr := c.Bool("stdout"), c.Bool("stderr")
+	if !stdout && !stderr {
+		stdout, stderr = true, true
+	}
+	query.Set("stdout", strconv.FormatBool(stdout))
+	query.Set("stderr", strconv.FormatBool(stderr))
+	query.Set("follow", strconv.FormatBool(c.Bool("f")))
+	query.Set("timestamps", strconv.FormatBool(c.Bool("t")))
+	return client.ContainerLogs(containerName, query, os.Stdout, os.Stderr)
+}
@@ -0,0 +1,114 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// prune等命令的 --filter 参数 支持 until=<时间> label=key[=value] label!=key[=value]
+type Filter struct {
+	Until     time.Time
+	Labels    []LabelFilter
+	NotLabels []LabelFilter
+}
+
+type LabelFilter struct {
+	Key   string
+	Value string // 为空时只判断key是否存在
+}
+
+func ParseFilters(filters []string) (*Filter, error) {
+	f := &Filter{}
+	for _, item := range filters {
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("bad format of filter %s (expected name=value)", item)
+		}
+		switch key {
+		case "until":
+			until, err := ParseTime(value)
+			if err != nil {
+				return nil, err
+			}
+			f.Until = until
+		case "label":
+			f.Labels = append(f.Labels, parseLabelFilter(value))
+		case "label!":
+			f.NotLabels = append(f.NotLabels, parseLabelFilter(value))
+		default:
+			return nil, fmt.Errorf("invalid filter %s", key)
+		}
+	}
+	return f, nil
+}
+
+// 时间参数支持 10m 这样的相对时长 RFC3339 时间 日期 或者 unix时间戳
+func ParseTime(value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	var sec int64
+	if _, err := fmt.Sscanf(value, "%d", &sec); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time value %s", value)
+}
+
+func parseLabelFilter(value string) LabelFilter {
+	k, v, _ := strings.Cut(value, "=")
+	return LabelFilter{Key: k, Value: v}
+}
+
+// 创建时间在until之前才匹配 createTime为RFC3339格式
+func (t *Filter) MatchTime(createTime string) bool {
+	if t.Until.IsZero() {
+		return true
+	}
+	created, err := time.Parse(time.RFC3339, createTime)
+	if err != nil {
+		return false
+	}
+	return created.Before(t.Until)
+}
+
+func (t *Filter) MatchLabels(labels map[string]string) bool {
+	for _, item := range t.Labels {
+		if !item.match(labels) {
+			return false
+		}
+	}
+	for _, item := range t.NotLabels {
+		if item.match(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t LabelFilter) match(labels map[string]string) bool {
+	v, exist := labels[t.Key]
+	if !exist {
+		return false
+	}
+	return t.Value == "" || t.Value == v
+}
+
+// 把 key=value 形式的参数转成map
+func ParseLabels(labels []string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	res := make(map[string]string, len(labels))
+	for _, item := range labels {
+		k, v, _ := strings.Cut(item, "=")
+		res[k] = v
+	}
+	return res
+}
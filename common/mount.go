@@ -0,0 +1,38 @@
+package common
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const mountinfofile string = "/proc/self/mountinfo"
+
+// 获取dir下(包括dir本身)的所有挂载点 层级深的排在前面 方便依次卸载
+func MountPointsUnder(dir string) ([]string, error) {
+	f, err := os.Open(mountinfofile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dir = filepath.Clean(dir)
+	res := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), " ")
+		if len(fields) < 5 {
+			continue
+		}
+		mountPoint := fields[4] // 第4个位置即挂载点
+		if mountPoint == dir || strings.HasPrefix(mountPoint, dir+"/") {
+			res = append(res, mountPoint)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return strings.Count(res[i], "/") > strings.Count(res[j], "/")
+	})
+	return res, scanner.Err()
+}
@@ -1,8 +1,14 @@
 package common
 
 import (
+	"fmt"
+	"io/fs"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 )
 
@@ -35,3 +41,73 @@ func RangeStr(n int) string {
 	}
 	return string(b)
 }
+
+// 统计文件夹占用的大小 不跨越挂载点
+func DirSize(root string) (int64, error) {
+	rootInfo, err := os.Lstat(root)
+	if err != nil {
+		return 0, err
+	}
+	rootDev := deviceOf(rootInfo)
+	var size int64
+	err = filepath.Walk(root, func(p string, info fs.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() && deviceOf(info) != rootDev {
+			return filepath.SkipDir
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+func deviceOf(info fs.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Dev)
+	}
+	return 0
+}
+
+func SizeHumanReadable(size int64) string {
+	unit := []string{"B", "KB", "MB", "GB", "TB"}
+	unitI := 0
+	for 1024 <= size {
+		unitI++
+		size = size / 1024
+	}
+	return strconv.FormatInt(size, 10) + unit[unitI]
+}
+
+// 解析 512m 1g 100k 这样带单位的大小 不带单位时为字节
+func ParseSize(size string) (int64, error) {
+	s := strings.ToLower(strings.TrimSpace(size))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "ib"), "b")
+	multiplier := int64(1)
+	if len(s) != 0 {
+		switch s[len(s)-1] {
+		case 'k':
+			multiplier = 1 << 10
+		case 'm':
+			multiplier = 1 << 20
+		case 'g':
+			multiplier = 1 << 30
+		case 't':
+			multiplier = 1 << 40
+		}
+		if multiplier != 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %s", size)
+	}
+	return int64(n * float64(multiplier)), nil
+}
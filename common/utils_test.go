@@ -0,0 +1,41 @@
+package common
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size    string
+		want    int64
+		wantErr bool
+	}{
+		{size: "0", want: 0},
+		{size: "1024", want: 1024},
+		{size: "100k", want: 100 << 10},
+		{size: "512m", want: 512 << 20},
+		{size: "512mb", want: 512 << 20},
+		{size: "512MiB", want: 512 << 20},
+		{size: "1g", want: 1 << 30},
+		{size: "1.5g", want: 3 << 29},
+		{size: "2MB", want: 2 << 20},
+		{size: "1t", want: 1 << 40},
+		{size: " 4K ", want: 4 << 10},
+		{size: "10b", want: 10},
+		{size: "", wantErr: true},
+		{size: "m", wantErr: true},
+		{size: "-1", wantErr: true},
+		{size: "1x", wantErr: true},
+		{size: "1.5.g", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.size)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseSize(%q) expected error, got %d", tt.size, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", tt.size, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package container
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kehaha-5/go-low-level-container/cgroups"
+	"github.com/kehaha-5/go-low-level-container/cgroups/limit"
+	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/pkg/errors"
+)
+
+// 修改父资源组的限制 组内所有容器共享 res中为零值的字段保持原来的限制
+func UpdateGroupResource(parent string, res *limit.ResourceConfig) error {
+	group, err := cgroups.NewGroupManager(parent)
+	if err != nil {
+		return err
+	}
+	newRes, err := mergeResourceConfig(group.Resource, res)
+	if err != nil {
+		return err
+	}
+	if err := group.SetGroup(newRes); err != nil {
+		return errors.Wrapf(err, "fail to set resource of cgroup group %s", group.Path)
+	}
+	slog.Info("update group", "parent", group.Path, "resource", fmt.Sprintf("%+v", *newRes))
+	return nil
+}
+
+// 资源组在parent下的容器 包括子资源组中的
+func listGroupContainers(parent string) ([]ContainerInfos, error) {
+	infos, err := ListContainerInfos()
+	if err != nil {
+		return nil, err
+	}
+	res := []ContainerInfos{}
+	for _, info := range infos {
+		if info.Cg.Path != "" && strings.HasPrefix(info.Cg.Path, parent+"/") {
+			res = append(res, info)
+		}
+	}
+	return res, nil
+}
+
+// 输出容器所在的父资源组 以及每个组中的容器数量
+func WirteGroupsToTabwriter(w *tabwriter.Writer) error {
+	infos, err := ListContainerInfos()
+	if err != nil {
+		return err
+	}
+	total := map[string]int{}
+	running := map[string]int{}
+	for _, info := range infos {
+		if info.Cg.Path == "" {
+			continue
+		}
+		// 旧版本的资源组直接以容器名称命名 没有父资源组
+		parent := path.Dir(info.Cg.Path)
+		if parent == "." {
+			continue
+		}
+		total[parent]++
+		if info.Status == Running {
+			running[parent]++
+		}
+	}
+	parents := make([]string, 0, len(total))
+	for parent := range total {
+		parents = append(parents, parent)
+	}
+	sort.Strings(parents)
+
+	fmt.Fprint(w, "PARENT\tCONTAINERS\tRUNNING\tGROUP LIMIT\n")
+	for _, parent := range parents {
+		group, err := cgroups.NewGroupManager(parent)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%t\n", parent, total[parent], running[parent], group.Resource != nil)
+	}
+	return nil
+}
+
+// 输出整个组的资源使用情况 资源组的用量包含所有子资源组
+func WirteGroupStatsToTabwriter(w *tabwriter.Writer, parent string) error {
+	group, err := cgroups.NewGroupManager(parent)
+	if err != nil {
+		return err
+	}
+	containers, err := listGroupContainers(group.Path)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(w, "PARENT\tCONTAINERS\tMEM USAGE / LIMIT\tPIDS / LIMIT\tPIDS LIMIT HIT\n")
+	stats, err := group.Stats()
+	if err != nil {
+		// 组内还没有运行过容器时资源组目录不存在
+		fmt.Fprintf(w, "%s\t%d\t--\t--\t--\n", group.Path, len(containers))
+		return nil
+	}
+	fmt.Fprintf(
+		w, "%s\t%d\t%s / %s\t%d / %s\t%d\n",
+		group.Path,
+		len(containers),
+		common.SizeHumanReadable(stats.MemoryUsage),
+		limitHumanReadable(stats.MemoryLimit, common.SizeHumanReadable),
+		stats.Pids,
+		limitHumanReadable(stats.PidsLimit, func(n int64) string { return strconv.FormatInt(n, 10) }),
+		stats.PidsMaxEvents,
+	)
+	return nil
+}
+
+// 删除父资源组 组内还有运行中的容器时不能删除
+func RemoveGroup(parent string) error {
+	parent, err := cgroups.CleanParent(parent)
+	if err != nil {
+		return err
+	}
+	containers, err := listGroupContainers(parent)
+	if err != nil {
+		return err
+	}
+	for _, info := range containers {
+		if info.Status == Running {
+			return fmt.Errorf("container %s in cgroup group %s is running", info.Name, parent)
+		}
+	}
+	return cgroups.RemoveGroup(parent)
+}
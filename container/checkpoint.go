@@ -0,0 +1,294 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/kehaha-5/go-low-level-container/events"
+	"github.com/kehaha-5/go-low-level-container/network"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultCheckpointPath string = "checkpoints"
+	criuDescriptorsName   string = "descriptors.json"
+	criuPidfileName       string = "restore.pid"
+	criuExtNetnsKey       string = "extNetNs"
+	criuDumpLogName       string = "dump.log"
+	criuRestoreLogName    string = "restore.log"
+	criuExtraFilesBase    int    = 3 // ExtraFiles中的文件从fd 3开始
+)
+
+func getCheckpointPath(containerName string, checkpointId string) string {
+	return path.Join(defaultInfoSavefilepath, containerName, defaultCheckpointPath, checkpointId)
+}
+
+// 使用criu对运行中的容器做checkpoint 镜像保存在容器信息目录下
+// leaveRunning 为false时checkpoint完成后容器会停止
+func CheckpointContainer(name string, checkpointId string, leaveRunning bool) error {
+	info := ContainerInfos{}
+	if err := GetInfoByContainerRef(name, &info); err != nil {
+		return errors.Wrap(err, "fail to get container info")
+	}
+	if !isContainerAlive(&info) {
+		return fmt.Errorf("container %s is not running", name)
+	}
+	// 共享的namespace不属于容器 criu无法单独恢复
+	if info.Namespaces.shared() {
+		return fmt.Errorf("checkpoint is not supported for container %s with shared namespaces", info.Name)
+	}
+	if _, err := exec.LookPath("criu"); err != nil {
+		return errors.Wrap(err, "criu binary not found")
+	}
+
+	imagePath := getCheckpointPath(info.Name, checkpointId)
+	if exist, _ := common.PathExist(imagePath); exist {
+		return fmt.Errorf("checkpoint %s has existed", checkpointId)
+	}
+	if err := os.MkdirAll(imagePath, 0755); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// 记录容器标准输入输出指向的文件 restore时通过 --inherit-fd 重新接上
+	descriptors, err := getStdioDescriptors(info.Pid)
+	if err != nil {
+		os.RemoveAll(imagePath)
+		return errors.WithStack(err)
+	}
+	descriptorsJson, err := json.Marshal(descriptors)
+	if err != nil {
+		os.RemoveAll(imagePath)
+		return errors.WithStack(err)
+	}
+	if err := os.WriteFile(path.Join(imagePath, criuDescriptorsName), descriptorsJson, 0644); err != nil {
+		os.RemoveAll(imagePath)
+		return errors.WithStack(err)
+	}
+
+	netnsInode, err := getNetnsInode(info.Pid)
+	if err != nil {
+		os.RemoveAll(imagePath)
+		return errors.WithStack(err)
+	}
+
+	args := []string{
+		"dump",
+		"-t", info.Pid,
+		"-D", imagePath,
+		"-o", criuDumpLogName,
+		"-v4",
+		"--tcp-established",
+		"--ext-unix-sk",
+		"--file-locks",
+		// cgroup由CgroupManager管理 restore后重新加入
+		"--manage-cgroups=ignore",
+		// 命名的net namespace由宿主机持有 作为外部资源
+		"--external", fmt.Sprintf("net[%d]:%s", netnsInode, criuExtNetnsKey),
+	}
+	for _, item := range volumeUrlExtract(info.Volume) {
+		// 挂载的宿主机目录作为外部挂载 key使用容器内的路径
+		args = append(args, "--ext-mount-map", item[1]+":"+item[1])
+	}
+	if leaveRunning {
+		args = append(args, "--leave-running")
+	}
+
+	slog.Info("checkpoint", "criu", args)
+	if out, err := exec.Command("criu", args...).CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "criu dump fail %s see %s", string(out), path.Join(imagePath, criuDumpLogName))
+	}
+	info.emit(events.ActionCheckpoint, map[string]string{"checkpoint": checkpointId})
+
+	if leaveRunning {
+		return nil
+	}
+	if info.IpInfo.ID != "" {
+		if err := network.DelIptRules(&info.IpInfo); err != nil {
+			slog.Error("checkpoint", "del ipt rules", err)
+		}
+	}
+	if err := info.modifyContainerStatusByName(Stop); err != nil {
+		return err
+	}
+	info.emit(events.ActionStop, nil)
+	return nil
+}
+
+// 从checkpoint恢复容器
+func RestoreContainer(name string, checkpointId string) error {
+	info := ContainerInfos{}
+	if err := GetInfoByContainerRef(name, &info); err != nil {
+		return errors.Wrap(err, "fail to get container info")
+	}
+	if isContainerAlive(&info) {
+		return fmt.Errorf("container %s is running", name)
+	}
+	imagePath := getCheckpointPath(info.Name, checkpointId)
+	if exist, _ := common.PathExist(imagePath); !exist {
+		return fmt.Errorf("checkpoint %s not existed", checkpointId)
+	}
+
+	descriptorsJson, err := os.ReadFile(path.Join(imagePath, criuDescriptorsName))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	descriptors := []string{}
+	if err := json.Unmarshal(descriptorsJson, &descriptors); err != nil {
+		return errors.WithStack(err)
+	}
+
+	netnsFile, err := os.Open(path.Join(defaultNetnsPath, info.Name))
+	if err != nil {
+		return errors.Wrap(err, "fail to open container netns")
+	}
+	defer netnsFile.Close()
+
+	stdoutW, stderrW, err := startLogger(info.Name, &info.LogConfig)
+	if err != nil {
+		return errors.Wrap(err, "fail to start logger")
+	}
+	defer stdoutW.Close()
+	defer stderrW.Close()
+
+	pidfile := path.Join(imagePath, criuPidfileName)
+	os.Remove(pidfile)
+	args := []string{
+		"restore",
+		"-D", imagePath,
+		"-o", criuRestoreLogName,
+		"-v4",
+		"--root", getMountRootPathByContainerName(info.Name),
+		"--restore-detached",
+		"--pidfile", pidfile,
+		"--tcp-established",
+		"--ext-unix-sk",
+		"--file-locks",
+		"--manage-cgroups=ignore",
+		"--inherit-fd", fmt.Sprintf("fd[%d]:%s", criuExtraFilesBase, criuExtNetnsKey),
+	}
+	extraFiles := []*os.File{netnsFile}
+	inherited := map[string]bool{}
+	for i, item := range descriptors {
+		// 接到logger的stdout stderr管道 用新的logger管道代替
+		if i == 0 || inherited[item] || !isExternalDescriptor(item) {
+			continue
+		}
+		inherited[item] = true
+		args = append(args, "--inherit-fd", fmt.Sprintf("fd[%d]:%s", criuExtraFilesBase+len(extraFiles), item))
+		if i == 1 {
+			extraFiles = append(extraFiles, stdoutW)
+		} else {
+			extraFiles = append(extraFiles, stderrW)
+		}
+	}
+	for _, item := range volumeUrlExtract(info.Volume) {
+		args = append(args, "--ext-mount-map", item[1]+":"+item[0])
+	}
+
+	if info.IpInfo.ID != "" {
+		if err := network.ConfigMapping(&info.IpInfo); err != nil {
+			return errors.Wrap(err, "fail to config mapping")
+		}
+	}
+
+	slog.Info("restore", "criu", args)
+	cmd := exec.Command("criu", args...)
+	cmd.ExtraFiles = extraFiles
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "criu restore fail %s see %s", string(out), path.Join(imagePath, criuRestoreLogName))
+	}
+
+	pidStr, err := os.ReadFile(pidfile)
+	if err != nil {
+		return errors.Wrap(err, "fail to read restored pid")
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(pidStr)))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	info.UpdatePid(pid)
+	if info.Cg.Path != "" && info.Cg.Resource != nil {
+		if err := info.Cg.Set(info.Cg.Resource); err != nil {
+			slog.Error("set cg", "err", err)
+		} else if err := info.Cg.Apply(pid); err != nil {
+			slog.Error("set cg", "err", err)
+		}
+	}
+	if err := info.modifyContainerStatusByName(Running); err != nil {
+		return fmt.Errorf("recordContainerInfo %+v", err)
+	}
+	info.emit(events.ActionRestore, map[string]string{"checkpoint": checkpointId})
+	info.emit(events.ActionStart, nil)
+	return nil
+}
+
+// 列出容器的所有checkpoint
+func ListCheckpoints(name string, w io.Writer) error {
+	info := ContainerInfos{}
+	if err := GetInfoByContainerRef(name, &info); err != nil {
+		return errors.Wrap(err, "fail to get container info")
+	}
+	dirs, err := os.ReadDir(path.Join(defaultInfoSavefilepath, info.Name, defaultCheckpointPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.WithStack(err)
+	}
+	for _, dir := range dirs {
+		if dir.IsDir() {
+			fmt.Fprintln(w, dir.Name())
+		}
+	}
+	return nil
+}
+
+func RemoveCheckpoint(name string, checkpointId string) error {
+	info := ContainerInfos{}
+	if err := GetInfoByContainerRef(name, &info); err != nil {
+		return errors.Wrap(err, "fail to get container info")
+	}
+	imagePath := getCheckpointPath(info.Name, checkpointId)
+	if exist, _ := common.PathExist(imagePath); !exist {
+		return fmt.Errorf("checkpoint %s not existed", checkpointId)
+	}
+	return errors.WithStack(os.RemoveAll(imagePath))
+}
+
+// 读取 /proc/<pid>/fd/0-2 的指向
+func getStdioDescriptors(pid string) ([]string, error) {
+	descriptors := make([]string, 3)
+	for i := range descriptors {
+		link, err := os.Readlink(fmt.Sprintf("/proc/%s/fd/%d", pid, i))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, errors.WithStack(err)
+		}
+		descriptors[i] = link
+	}
+	return descriptors, nil
+}
+
+// 容器外部的文件和管道需要在restore时由我们提供
+func isExternalDescriptor(link string) bool {
+	if link == "" || link == "/dev/null" {
+		return false
+	}
+	return strings.HasPrefix(link, "pipe:") || strings.HasPrefix(link, "/")
+}
+
+func getNetnsInode(pid string) (uint64, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(fmt.Sprintf("/proc/%s/ns/net", pid), &stat); err != nil {
+		return 0, err
+	}
+	return stat.Ino, nil
+}
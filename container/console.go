@@ -0,0 +1,285 @@
+package container
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// 伪终端 容器进程使用slave作为控制终端 父进程通过master读写
+type console struct {
+	master *os.File
+	slave  *os.File
+}
+
+func newConsole() (*console, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to open ptmx")
+	}
+	// unlockpt
+	if err := unix.IoctlSetPointerInt(int(master.Fd()), unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		return nil, errors.Wrap(err, "fail to unlock pty")
+	}
+	// ptsname
+	n, err := unix.IoctlGetInt(int(master.Fd()), unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		return nil, errors.Wrap(err, "fail to get pty number")
+	}
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, errors.Wrap(err, "fail to open pty slave")
+	}
+	return &console{master: master, slave: slave}, nil
+}
+
+// 容器进程在新的会话中把slave设置为控制终端
+func (t *console) attach(cmd *exec.Cmd) {
+	cmd.Stdin = t.slave
+	cmd.Stdout = t.slave
+	cmd.Stderr = t.slave
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0
+}
+
+// 把当前终端的输入转发给容器 容器的输出写到out
+// 返回的函数等待容器的输出读完并恢复终端
+func (t *console) proxy(out io.Writer) func() {
+	// 父进程中不再需要slave 容器退出后读master会返回EIO
+	t.slave.Close()
+
+	restore := setRawTerminal(os.Stdin)
+	resizeCh := make(chan os.Signal, 1)
+	signal.Notify(resizeCh, syscall.SIGWINCH)
+	go func() {
+		for range resizeCh {
+			ws := t.resize(os.Stdin)
+			if r, ok := out.(resizer); ok && ws != nil {
+				r.Resize(ws)
+			}
+		}
+	}()
+	t.resize(os.Stdin)
+
+	// 容器退出后关闭stopW 让转发输入的goroutine退出
+	stopR, stopW, err := os.Pipe()
+	if err != nil {
+		slog.Error("create console stop pipe", "err", err)
+	}
+	inputDone := make(chan struct{})
+	go func() {
+		defer close(inputDone)
+		if stopR == nil {
+			io.Copy(t.master, os.Stdin)
+			return
+		}
+		copyInput(t.master, os.Stdin, stopR)
+	}()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		io.Copy(out, t.master)
+	}()
+
+	return func() {
+		<-done
+		if stopW != nil {
+			stopW.Close()
+			<-inputDone
+			stopR.Close()
+		}
+		signal.Stop(resizeCh)
+		close(resizeCh)
+		restore()
+		t.master.Close()
+	}
+}
+
+// 把输入转发给dst 和io.Copy一样直到输入结束 不同的是stop可读或者关闭时也会退出
+// 不会一直阻塞在读取输入上
+func copyInput(dst io.Writer, src *os.File, stop *os.File) {
+	fds := []unix.PollFd{
+		{Fd: int32(src.Fd()), Events: unix.POLLIN},
+		{Fd: int32(stop.Fd()), Events: unix.POLLIN},
+	}
+	buf := make([]byte, 32*1024)
+	for {
+		if _, err := unix.Poll(fds, -1); err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			slog.Error("poll console input", "err", err)
+			return
+		}
+		if fds[1].Revents != 0 || fds[0].Revents&unix.POLLNVAL != 0 {
+			return
+		}
+		if fds[0].Revents == 0 {
+			continue
+		}
+		n, err := unix.Read(int(fds[0].Fd), buf)
+		if n > 0 {
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+		if err == unix.EINTR || err == unix.EAGAIN {
+			continue
+		}
+		if err != nil || n == 0 {
+			return
+		}
+	}
+}
+
+// 需要知道终端窗口大小变化的输出 比如会话记录
+type resizer interface {
+	Resize(ws *unix.Winsize)
+}
+
+// 终端窗口大小变化时同步给容器
+func (t *console) resize(term *os.File) *unix.Winsize {
+	ws, err := unix.IoctlGetWinsize(int(term.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return nil
+	}
+	if err := unix.IoctlSetWinsize(int(t.master.Fd()), unix.TIOCSWINSZ, ws); err != nil {
+		slog.Error("resize console", "err", err)
+	}
+	return ws
+}
+
+func (t *console) Close() {
+	t.master.Close()
+	t.slave.Close()
+}
+
+// 终端设置为raw模式 输入原样转发给容器 不是终端时什么都不做
+func setRawTerminal(term *os.File) func() {
+	fd := int(term.Fd())
+	old, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return func() {}
+	}
+	raw := *old
+	raw.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	raw.Oflag &^= unix.OPOST
+	raw.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag &^= unix.CSIZE | unix.PARENB
+	raw.Cflag |= unix.CS8
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, &raw); err != nil {
+		slog.Error("set raw terminal", "err", err)
+		return func() {}
+	}
+	return func() {
+		unix.IoctlSetTermios(fd, unix.TCSETS, old)
+	}
+}
+
+// 交互式的会话 输出显示在终端 可以同时交给logger记录或者录制
+type ttySession struct {
+	console  *console
+	logW     *os.File
+	recorder *castRecorder
+	out      io.Writer
+	wait     func()
+	once     sync.Once
+}
+
+// 在cmd.Start之前调用 logW为空时不记录日志
+func newTtySession(cmd *exec.Cmd, logW *os.File) (*ttySession, error) {
+	c, err := newConsole()
+	if err != nil {
+		return nil, err
+	}
+	c.attach(cmd)
+	t := &ttySession{console: c, logW: logW, out: os.Stdout}
+	if logW != nil {
+		t.out = &teeWriter{w: os.Stdout, log: logW}
+	}
+	return t, nil
+}
+
+// 在cmd.Start之前调用
+func attachConsole(cmd *exec.Cmd, containerName string, conf *LogConfig) (*ttySession, error) {
+	stdoutW, stderrW, err := startLogger(containerName, conf)
+	if err != nil {
+		return nil, err
+	}
+	// 终端中标准输出和标准错误是同一个流
+	stderrW.Close()
+	t, err := newTtySession(cmd, stdoutW)
+	if err != nil {
+		stdoutW.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// 把会话录制为asciicast文件 在start之前调用
+func (t *ttySession) record(containerName string, kind string, command []string) error {
+	recorder, err := newCastRecorder(containerName, kind, command, t.out)
+	if err != nil {
+		return err
+	}
+	t.recorder = recorder
+	t.out = recorder
+	return nil
+}
+
+// 在cmd.Start之后调用 开始转发输入输出
+func (t *ttySession) start() {
+	t.wait = t.console.proxy(t.out)
+}
+
+// 等待容器的输出全部读取 关闭logger的管道和录制文件
+func (t *ttySession) Close() {
+	t.once.Do(func() {
+		if t.wait != nil {
+			t.wait()
+		} else {
+			t.console.Close()
+		}
+		if t.recorder != nil {
+			if err := t.recorder.Close(); err != nil {
+				slog.Error("close session record", "err", err)
+			}
+		}
+		if t.logW != nil {
+			t.logW.Close()
+		}
+	})
+}
+
+// 写入日志失败不影响终端的显示
+type teeWriter struct {
+	w   io.Writer
+	log io.Writer
+}
+
+func (t *teeWriter) Write(p []byte) (int, error) {
+	if t.log != nil {
+		if _, err := t.log.Write(p); err != nil {
+			slog.Error("write tty log", "err", err)
+			t.log = nil
+		}
+	}
+	return t.w.Write(p)
+}
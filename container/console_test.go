@@ -0,0 +1,49 @@
+package container
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCopyInput(t *testing.T) {
+	srcR, srcW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srcR.Close()
+	stopR, stopW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stopR.Close()
+
+	// 输入结束时退出
+	srcW.Write([]byte("ls\n"))
+	srcW.Close()
+	dst := &bytes.Buffer{}
+	copyInput(dst, srcR, stopR)
+	if dst.String() != "ls\n" {
+		t.Errorf("copyInput got %q", dst.String())
+	}
+
+	// 输入没有结束 关闭stop后退出
+	inR, inW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inR.Close()
+	defer inW.Close()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		copyInput(&bytes.Buffer{}, inR, stopR)
+	}()
+	stopW.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("copyInput did not return after stop closed")
+	}
+}
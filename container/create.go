@@ -0,0 +1,86 @@
+package container
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/kehaha-5/go-low-level-container/events"
+	"github.com/kehaha-5/go-low-level-container/store"
+	"github.com/pkg/errors"
+)
+
+// create时保存的启动参数 第一次start时按这些参数创建容器
+const defaultCreateConfigName string = "create.json"
+
+func getCreateConfigPath(name string) string {
+	return path.Join(defaultInfoSavefilepath, name, defaultCreateConfigName)
+}
+
+// 只占用容器名称并保存配置 工作目录 网络和进程在第一次start时才创建
+// 创建的容器只能在后台运行
+func CreateContainer(args *RunCommandArgs) (info *ContainerInfos, err error) {
+	if args.Tty {
+		return nil, fmt.Errorf("created container can not allocate a tty")
+	}
+	if err := args.Namespaces.Validate(); err != nil {
+		return nil, err
+	}
+	if err := args.Namespaces.checkSysctls(args.Sysctls); err != nil {
+		return nil, err
+	}
+	if err := args.Restart.Validate(); err != nil {
+		return nil, err
+	}
+	if !args.Namespaces.OwnNetns() && args.PortMapping != "" {
+		return nil, fmt.Errorf("port mapping is not allowed with net mode %s", args.Namespaces.Net)
+	}
+
+	info = &ContainerInfos{}
+	if err := info.SetContainerName(args.ContainerName); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			info.DeleteContainerInfo()
+		}
+	}()
+	args.ContainerName = info.Name
+	args.Detach = true
+	if err := store.WriteJSON(getCreateConfigPath(info.Name), args); err != nil {
+		return nil, err
+	}
+
+	info.setBaseInfo(0, args)
+	info.Pid = ""
+	info.Status = Created
+	if err := info.RecordContainerInfo(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	info.emit(events.ActionCreate, nil)
+	return info, nil
+}
+
+// 第一次启动create保存的容器
+func startCreatedContainer(info *ContainerInfos) error {
+	args := &RunCommandArgs{}
+	exist, err := store.ReadJSON(getCreateConfigPath(info.Name), args)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return fmt.Errorf("create config of container %s not exist", info.Name)
+	}
+	args.createdId = info.Id
+	args.ContainerName = info.Name
+	_, err = RunContainer(args)
+	return err
+}
+
+// 还没有启动过的容器只需要删除保存的配置
+func removeCreatedContainer(info *ContainerInfos) error {
+	if err := info.del(); err != nil {
+		return errors.WithStack(err)
+	}
+	info.emit(events.ActionDestroy, nil)
+	return nil
+}
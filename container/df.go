@@ -0,0 +1,108 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/pkg/errors"
+)
+
+// system df 中每一行的统计信息
+type diskUsageItem struct {
+	Type        string
+	Total       int
+	Active      int
+	Size        int64
+	Reclaimable int64
+}
+
+func (t *diskUsageItem) add(size int64, active bool) {
+	t.Total++
+	t.Size += size
+	if active {
+		t.Active++
+	} else {
+		t.Reclaimable += size
+	}
+}
+
+func WirteDiskUsageToTabwriter(w *tabwriter.Writer) error {
+	infos, err := ListContainerInfos()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	images := &diskUsageItem{Type: "Images"}
+	containers := &diskUsageItem{Type: "Containers"}
+	volumes := &diskUsageItem{Type: "Local Volumes"}
+	logs := &diskUsageItem{Type: "Logs"}
+
+	usedImages := map[string]bool{}
+	for _, info := range infos {
+		usedImages[info.Image] = true
+
+		running := info.Status == Running
+		wirteLayerSize, _ := common.DirSize(path.Join(root, defaultRoot, info.Name, defaultWirteLayer))
+		workLayerSize, _ := common.DirSize(path.Join(root, defaultRoot, info.Name, defaultWorkLayer))
+		containers.add(wirteLayerSize+workLayerSize, running)
+
+		// 包括轮转后的日志文件
+		logfiles, _ := filepath.Glob(getLogFilePath(info.Name) + "*")
+		for _, logfile := range logfiles {
+			if fInfo, err := os.Stat(logfile); err == nil {
+				logs.add(fInfo.Size(), running)
+			}
+		}
+	}
+
+	var imageList imageInfos
+	if err := imageList.load(); err != nil {
+		return errors.WithStack(err)
+	}
+	for name := range imageList.Infos {
+		imageName := strings.TrimSuffix(name, ".tar")
+		var size int64
+		if fInfo, err := os.Stat(path.Join(saveImagePaths, name)); err == nil {
+			size += fInfo.Size()
+		}
+		layerSize, _ := common.DirSize(path.Join(root, defaultReadonlyLayer, imageName))
+		images.add(size+layerSize, usedImages[imageName])
+	}
+
+	usedVolumes, err := getUsedVolumes()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	dirs, err := os.ReadDir(path.Join(root, defaultVolumesPath))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+	for _, dir := range dirs {
+		volumePath := path.Join(root, defaultVolumesPath, dir.Name())
+		size, _ := common.DirSize(volumePath)
+		volumes.add(size, usedVolumes[volumePath])
+	}
+
+	// "TYPE\tTOTAL\tACTIVE\tSIZE\tRECLAIMABLE\n"
+	for _, item := range []*diskUsageItem{images, containers, volumes, logs} {
+		reclaimablePercent := 0
+		if item.Size != 0 {
+			reclaimablePercent = int(item.Reclaimable * 100 / item.Size)
+		}
+		fmt.Fprintf(
+			w, "%s\t%d\t%d\t%s\t%s (%d%%)\n",
+			item.Type,
+			item.Total,
+			item.Active,
+			common.SizeHumanReadable(item.Size),
+			common.SizeHumanReadable(item.Reclaimable),
+			reclaimablePercent,
+		)
+	}
+	return nil
+}
@@ -1,7 +1,9 @@
 package container
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/exec"
@@ -12,40 +14,84 @@ import (
 	"github.com/pkg/errors"
 )
 
-func Exce(name string, cmdArr []string, tty bool) error {
-	pid, err := getPidByContainerName(name)
-	if err != nil {
-		return err
+// exec的输入输出 tty为true时使用伪终端接管当前终端 record为true时把会话录制下来 只在tty时有效
+type ExecConfig struct {
+	Tty    bool
+	Record bool
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// 在运行中的容器内执行命令 返回命令的退出码 ctx结束时kill执行命令的进程
+func ExecContainer(ctx context.Context, name string, cmdArr []string, config *ExecConfig) (int, error) {
+	info := ContainerInfos{}
+	if err := GetInfoByContainerRef(name, &info); err != nil {
+		return 0, err
+	}
+	if !isContainerAlive(&info) {
+		return 0, fmt.Errorf("container %s is not running", info.Name)
 	}
 
-	cmd := exec.Command("/proc/self/exe", "exec")
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
-	if tty {
-		cmd.Stdin = os.Stdin
+	cmd, err := execCommand(info.Pid, cmdArr)
+	if err != nil {
+		return 0, err
+	}
+	var session *ttySession
+	if config.Tty {
+		if session, err = newTtySession(cmd, nil); err != nil {
+			return 0, err
+		}
+		defer session.Close()
+		if config.Record {
+			if err := session.record(info.Name, RecordKindExec, cmdArr); err != nil {
+				return 0, err
+			}
+		}
+	} else {
+		cmd.Stdout = config.Stdout
+		cmd.Stderr = config.Stderr
 	}
 
-	if err = os.Setenv(common.CONTAINERIDENV, pid); err != nil {
-		return err
+	if err = cmd.Start(); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if session != nil {
+		session.start()
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		cmd.Process.Kill()
+		<-done
+		return 0, ctx.Err()
 	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return 0, nil
+}
 
+// 通过nsenter进入pid所在容器的namespace执行命令 退出码为命令的退出码
+func execCommand(pid string, cmdArr []string) (*exec.Cmd, error) {
+	cmd := exec.Command("/proc/self/exe", "exec")
 	cmdStr := strings.Join(cmdArr[0:], " ")
-	if err = os.Setenv(common.CONTAINERCMDENV, cmdStr); err != nil {
-		return err
-	}
 	slog.Info("exec", "pid", pid)
 	slog.Info("exec", "cmd", cmdStr)
 
 	containerEnvs, err := getContainerEnvByPid(pid)
 	if err != nil {
-		return errors.WithStack(err)
+		return nil, errors.WithStack(err)
 	}
+	// 只设置在子进程中 不能影响当前进程之后启动的其他子进程
 	cmd.Env = append(os.Environ(), containerEnvs...)
-
-	if err = cmd.Run(); err != nil {
-		return err
-	}
-	return nil
+	cmd.Env = append(cmd.Env, common.CONTAINERIDENV+"="+pid, common.CONTAINERCMDENV+"="+cmdStr)
+	return cmd, nil
 }
 
 func getContainerEnvByPid(pid string) ([]string, error) {
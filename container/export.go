@@ -7,7 +7,7 @@ import (
 
 func ExportCommitContainer(name string, imgetar string) error {
 	info := ContainerInfos{}
-	if err := GetInfoByContainerName(name, &info); err != nil {
+	if err := GetInfoByContainerRef(name, &info); err != nil {
 		return err
 	}
 	workSpace := getWorkSpackInfoByContainerInfos(&info)
@@ -0,0 +1,221 @@
+package container
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	logOptGelfAddress         string = "gelf-address"
+	logOptGelfCompressionType string = "gelf-compression-type"
+
+	gelfVersion string = "1.1"
+	// 以太网MTU减去IP和UDP头 超过后需要分块
+	gelfChunkSize   int = 1420
+	gelfMaxChunks   int = 128
+	gelfChunkHeader int = 12
+
+	gelfCompressGzip string = "gzip"
+	gelfCompressZlib string = "zlib"
+	gelfCompressNone string = "none"
+)
+
+// 分块的魔数
+var gelfChunkMagic = []byte{0x1e, 0x0f}
+
+type gelfOptions struct {
+	network     string
+	address     string
+	compression string // 只对udp生效
+	tag         string
+}
+
+// gelf-address 支持 udp://host:12201 tcp://host:12201
+func newGelfOptions(containerName string, opts map[string]string) (*gelfOptions, error) {
+	res := &gelfOptions{compression: gelfCompressGzip}
+	for k, v := range opts {
+		switch k {
+		case logOptGelfAddress:
+			u, err := url.Parse(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid log opt %s=%s", k, v)
+			}
+			if u.Scheme != "udp" && u.Scheme != "tcp" {
+				return nil, fmt.Errorf("invalid log opt %s=%s (unsupported scheme %s)", k, v, u.Scheme)
+			}
+			if u.Port() == "" {
+				return nil, fmt.Errorf("invalid log opt %s=%s (miss port)", k, v)
+			}
+			res.network, res.address = u.Scheme, u.Host
+		case logOptGelfCompressionType:
+			if v != gelfCompressGzip && v != gelfCompressZlib && v != gelfCompressNone {
+				return nil, fmt.Errorf("invalid log opt %s=%s (expected gzip zlib or none)", k, v)
+			}
+			res.compression = v
+		case logOptTag:
+		default:
+			return nil, fmt.Errorf("unknown log opt %s for log driver %s", k, gelfLogDriver)
+		}
+	}
+	if res.address == "" {
+		return nil, fmt.Errorf("log opt %s is required for log driver %s", logOptGelfAddress, gelfLogDriver)
+	}
+	res.tag = getLogTag(containerName, opts)
+	return res, nil
+}
+
+type gelfMessage struct {
+	Version       string  `json:"version"`
+	Host          string  `json:"host"`
+	ShortMessage  string  `json:"short_message"`
+	Timestamp     float64 `json:"timestamp"`
+	Level         int     `json:"level"`
+	ContainerName string  `json:"_container_name"`
+	Tag           string  `json:"_tag"`
+	Stream        string  `json:"_stream"`
+}
+
+// 按 GELF 格式发送日志 udp超过一个包的大小时分块发送 tcp以\0分隔
+type gelfWriter struct {
+	opts          *gelfOptions
+	containerName string
+	hostname      string
+	conn          net.Conn
+}
+
+func newGelfWriter(containerName string, opts map[string]string) (LogDriver, error) {
+	gelfOpts, err := newGelfOptions(containerName, opts)
+	if err != nil {
+		return nil, err
+	}
+	w := &gelfWriter{opts: gelfOpts, containerName: containerName, hostname: getLogHostname()}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (t *gelfWriter) connect() error {
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+	conn, err := net.DialTimeout(t.opts.network, t.opts.address, 5*time.Second)
+	if err != nil {
+		return errors.Wrapf(err, "fail to connect gelf %s", t.opts.address)
+	}
+	t.conn = conn
+	return nil
+}
+
+func (t *gelfWriter) WriteLog(entry *logEntry) error {
+	msg := strings.TrimRight(entry.Log, "\n")
+	// short_message不能为空
+	if msg == "" {
+		return nil
+	}
+	level := syslogSeverityInfo
+	if entry.Stream == stderrStream {
+		level = syslogSeverityErr
+	}
+	data, err := json.Marshal(&gelfMessage{
+		Version:       gelfVersion,
+		Host:          t.hostname,
+		ShortMessage:  msg,
+		Timestamp:     float64(entry.Time.UnixNano()) / float64(time.Second),
+		Level:         level,
+		ContainerName: t.containerName,
+		Tag:           t.opts.tag,
+		Stream:        entry.Stream,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if t.opts.network == "tcp" {
+		data = append(data, 0)
+		if t.conn != nil {
+			if _, err := t.conn.Write(data); err == nil {
+				return nil
+			}
+		}
+		// 连接断开后重连一次
+		if err := t.connect(); err != nil {
+			return err
+		}
+		_, err := t.conn.Write(data)
+		return errors.WithStack(err)
+	}
+
+	if data, err = t.compress(data); err != nil {
+		return err
+	}
+	return t.writeUdp(data)
+}
+
+func (t *gelfWriter) compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch t.opts.compression {
+	case gelfCompressGzip:
+		w = gzip.NewWriter(&buf)
+	case gelfCompressZlib:
+		w = zlib.NewWriter(&buf)
+	default:
+		return data, nil
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// 分块格式 魔数(2) 消息id(8) 序号(1) 总块数(1) 数据
+func (t *gelfWriter) writeUdp(data []byte) error {
+	if len(data) <= gelfChunkSize {
+		_, err := t.conn.Write(data)
+		return errors.WithStack(err)
+	}
+	payloadSize := gelfChunkSize - gelfChunkHeader
+	count := (len(data) + payloadSize - 1) / payloadSize
+	if count > gelfMaxChunks {
+		return fmt.Errorf("gelf message too large (%d bytes)", len(data))
+	}
+	msgId := make([]byte, 8)
+	if _, err := rand.Read(msgId); err != nil {
+		return errors.WithStack(err)
+	}
+	chunk := make([]byte, 0, gelfChunkSize)
+	for i := 0; i < count; i++ {
+		end := min((i+1)*payloadSize, len(data))
+		chunk = append(chunk[:0], gelfChunkMagic...)
+		chunk = append(chunk, msgId...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, data[i*payloadSize:end]...)
+		if _, err := t.conn.Write(chunk); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+func (t *gelfWriter) Close() error {
+	if t.conn == nil {
+		return nil
+	}
+	return t.conn.Close()
+}
@@ -6,18 +6,23 @@ import (
 	"log/slog"
 	"os"
 	"path"
-	"text/tabwriter"
+	"sort"
+	"strings"
 
 	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/kehaha-5/go-low-level-container/events"
+	"github.com/kehaha-5/go-low-level-container/store"
 	"github.com/pkg/errors"
 )
 
 var (
 	defaultImageInfoPath = common.ROOTPATH + "/images/"
 	defaultImageFileName = "info.json"
+	imagesLockName       = "images"
 )
 
-type imageInfoItem struct {
+// 载入的镜像 Name为镜像文件名
+type ImageInfo struct {
 	ID         string `json:"id"`
 	Name       string `json:"name"`
 	Size       string `json:"size"`
@@ -25,15 +30,17 @@ type imageInfoItem struct {
 }
 
 type imageInfos struct {
-	Infos map[string]imageInfoItem `json:"infos"` //filename => imageInfoItem
+	Infos map[string]ImageInfo `json:"infos"` //filename => ImageInfo
 }
 
-func addImage(image *imageInfoItem) error {
-	var infos imageInfos
-	if err := infos.load(); err != nil {
-		return errors.WithStack(err)
-	}
-	return infos.add(image)
+func addImage(image *ImageInfo) error {
+	return store.WithLock(imagesLockName, func() error {
+		var infos imageInfos
+		if err := infos.load(); err != nil {
+			return errors.WithStack(err)
+		}
+		return infos.add(image)
+	})
 }
 
 func getSaveFilePath() string {
@@ -43,12 +50,12 @@ func getSaveFilePath() string {
 func (t *imageInfos) load() error {
 	isExist, _ := common.PathExist(defaultImageInfoPath)
 	if !isExist {
-		if err := os.MkdirAll(defaultImageInfoPath, 0644); err != nil {
+		if err := os.MkdirAll(defaultImageInfoPath, 0755); err != nil {
 			return errors.Wrap(err, "fail to mkdir defaultImageInfoPath")
 		}
 	}
 	if !common.FileExist(getSaveFilePath()) {
-		t.Infos = make(map[string]imageInfoItem)
+		t.Infos = make(map[string]ImageInfo)
 		return nil
 	}
 
@@ -60,7 +67,7 @@ func (t *imageInfos) load() error {
 		return errors.Wrapf(err, "fail to unmarshal json %s ", string(jsonStr))
 	}
 	if len(t.Infos) == 0 {
-		t.Infos = make(map[string]imageInfoItem)
+		t.Infos = make(map[string]ImageInfo)
 	}
 	return nil
 }
@@ -73,7 +80,7 @@ func (t *imageInfos) dump() error {
 	return recordToJsonfile(jsonStr)
 }
 
-func (t *imageInfos) add(image *imageInfoItem) error {
+func (t *imageInfos) add(image *ImageInfo) error {
 	_, isExist := t.Infos[image.Name]
 	if isExist {
 		return fmt.Errorf("the images name %s has existed ", image.Name)
@@ -88,45 +95,73 @@ func recordToJsonfile(jsonStr []byte) error {
 		return errors.Wrapf(err, "fail to judge the file path %s", defaultImageInfoPath)
 	}
 	if !isExist {
-		if err := os.MkdirAll(defaultImageInfoPath, 0644); err != nil {
+		if err := os.MkdirAll(defaultImageInfoPath, 0755); err != nil {
 			return errors.Wrap(err, "fail to mkdir")
 		}
 	}
 
-	return errors.Wrap(os.WriteFile(getSaveFilePath(), jsonStr, 0644), "fail to write data to file ")
+	return errors.Wrap(store.WriteFileAtomic(getSaveFilePath(), jsonStr, 0644), "fail to write data to file ")
 }
 
-func WirteImagesInfoToTabwriter(w *tabwriter.Writer) error {
+// 按名称排序的所有镜像
+func ListImages() ([]ImageInfo, error) {
 	var infos imageInfos
 	if err := infos.load(); err != nil {
-		return errors.WithStack(err)
+		return nil, errors.WithStack(err)
 	}
+	res := make([]ImageInfo, 0, len(infos.Infos))
 	for _, item := range infos.Infos {
-		// "ID\tNAME\tSize\tCREATED\n"
-		fmt.Fprintf(
-			w, "%s\t%s\t%s\t%s\n",
-			item.ID,
-			item.Name,
-			item.Size,
-			item.CreateTime,
-		)
+		res = append(res, item)
 	}
-	return nil
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+// 通过镜像文件名 去掉.tar后缀的镜像名或者id查找镜像
+func FindImage(ref string) (*ImageInfo, error) {
+	images, err := ListImages()
+	if err != nil {
+		return nil, err
+	}
+	for i := range images {
+		if images[i].Name == ref || strings.TrimSuffix(images[i].Name, ".tar") == ref || images[i].ID == ref {
+			return &images[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no such image: %s", ref)
+}
+
+// 镜像文件的路径
+func GetImageFilePath(name string) string {
+	return path.Join(saveImagePaths, name)
 }
 
 func DelImageByName(names []string) error {
+	lock, err := store.Lock(imagesLockName)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
 	var infos imageInfos
 	if err := infos.load(); err != nil {
 		return errors.WithStack(err)
 	}
+	deleted := []ImageInfo{}
 	for _, name := range names {
-		_, isExist := infos.Infos[name]
+		item, isExist := infos.Infos[name]
 		if !isExist {
 			slog.Info(fmt.Sprintf("delete image name %s not existed", name))
 			continue
 		}
 		delete(infos.Infos, name)
-		fmt.Println(name)
+		deleted = append(deleted, item)
+	}
+	if err := infos.dump(); err != nil {
+		return err
 	}
-	return infos.dump()
+	for _, item := range deleted {
+		events.Emit(events.Image, events.ActionDelete, item.ID, item.Name, nil)
+	}
+	return nil
 }
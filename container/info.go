@@ -1,25 +1,29 @@
 package container
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"math/rand"
 	"os"
 	"path"
 	"strconv"
 	"strings"
-	"text/tabwriter"
 	"time"
 
 	"github.com/kehaha-5/go-low-level-container/cgroups"
 	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/kehaha-5/go-low-level-container/events"
 	"github.com/kehaha-5/go-low-level-container/network"
+	"github.com/kehaha-5/go-low-level-container/store"
+	"github.com/pkg/errors"
 )
 
 type ContainerInfos struct {
 	Id          string                `json:"id"`         //容器id
 	Pid         string                `json:"pid"`        //容器init进程在宿主机上的pid
+	StartTime   uint64                `json:"startTime"`  //init进程的启动时间 和pid一起确定是同一个进程
 	Name        string                `json:"name"`       //容器名称
 	Command     string                `json:"command"`    //容器init进程执行的命令
 	CreateTime  string                `json:"createTime"` //容器创建时间
@@ -28,16 +32,33 @@ type ContainerInfos struct {
 	PortMapping []string              `json:"portMapping"`
 	IpInfo      network.Endpoint      `json:"ipInfo"`
 	Env         []string              `json:"env"`
+	Image       string                `json:"image"`
+	Labels      map[string]string     `json:"labels"`
+	LogConfig   LogConfig             `json:"logConfig"`
 	Cg          cgroups.CgroupManager `json:"cg"`
 	WorkSpace   workSpace             `json:"wrokSpace"`
+	OomScoreAdj int                   `json:"oomScoreAdj"`
+	OOMKilled   bool                  `json:"oomKilled"`   //最后一次退出是否因为内存不足被kill
+	OomKills    int64                 `json:"oomKills"`    //已经处理过的oom kill次数 资源组中的计数不会清零
+	PidsMaxHits int64                 `json:"pidsMaxHits"` //已经处理过的达到进程数限制的次数
+	CgParent    string                `json:"cgParent"`    //容器资源组所在的父资源组
+	Ulimits     []Ulimit              `json:"ulimits"`
+	Sysctls     map[string]string     `json:"sysctls"`
+	Namespaces  NamespaceModes        `json:"namespaces"`
+	Pod         string                `json:"pod"`      //容器所在的pod
+	ExitCode    int                   `json:"exitCode"` //最后一次退出的退出码 -1为未知
+	Restart     RestartPolicy         `json:"restart"`
+	Restarts    int                   `json:"restartCount"` //按重启策略自动重启的次数
 }
 
 const (
+	Created             string = "created" //只保存了配置 还没有启动过
 	Running             string = "running"
 	Stop                string = "stopped"
 	Exit                string = "exited"
 	defaultInfoSavename string = "config.json"
-	defaultIdLen        int    = 10
+	defaultIdLen        int    = 32 // 随机字节数 十六进制后为64位
+	shortIdLen          int    = 12
 )
 
 var (
@@ -48,13 +69,27 @@ func GetConfigSavePath() string {
 	return defaultInfoSavefilepath
 }
 
-func (t *ContainerInfos) SetContainerName(containerName string) {
-	t.randomContainerId(defaultIdLen)
+// 生成容器id并占用容器名称 名称已经被使用时返回错误
+func (t *ContainerInfos) SetContainerName(containerName string) error {
+	if containerName != "" && !validContainerName.MatchString(containerName) {
+		return fmt.Errorf("invalid container name %s, only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", containerName)
+	}
+	if err := t.randomContainerId(defaultIdLen); err != nil {
+		return err
+	}
 	if containerName == "" {
-		t.Name = t.Id
+		t.Name = t.ShortId()
 	} else {
 		t.Name = containerName
 	}
+	return reserveContainerName(t.Name)
+}
+
+func (t *ContainerInfos) ShortId() string {
+	if len(t.Id) > shortIdLen {
+		return t.Id[:shortIdLen]
+	}
+	return t.Id
 }
 
 func (t *ContainerInfos) RecordContainerInfo() error {
@@ -77,12 +112,21 @@ func (t *ContainerInfos) setBaseInfo(pid int, args *RunCommandArgs) {
 	}
 	createTime := time.Now().In(tz).Format(time.RFC3339)
 
-	t.Pid = strconv.Itoa(pid)
+	t.UpdatePid(pid)
 	t.Command = strings.Join(args.CommandArgs, " ")
 	t.CreateTime = createTime
 	t.Status = Running
 	t.Volume = args.VolumeArg
 	t.Env = args.EnvList
+	t.Image = args.ImageName
+	t.Labels = common.ParseLabels(args.Labels)
+	t.OomScoreAdj = args.OomScoreAdj
+	t.Sysctls = args.Sysctls
+	t.Pod = args.Pod
+	t.Restart = args.Restart
+	if args.LogConfig != nil {
+		t.LogConfig = *args.LogConfig
+	}
 
 	protMapping := strings.Split(args.PortMapping, " ")
 	if args.PortMapping != "" && len(protMapping) != 0 {
@@ -104,6 +148,42 @@ func (t *ContainerInfos) SetWorkSpace(ws *workSpace) {
 
 func (t *ContainerInfos) UpdatePid(pid int) {
 	t.Pid = strconv.Itoa(pid)
+	t.StartTime = 0
+	if pid <= 0 {
+		return
+	}
+	startTime, _, err := readProcStat(pid)
+	if err != nil {
+		slog.Error("read process start time", "pid", pid, "err", err)
+		return
+	}
+	t.StartTime = startTime
+}
+
+// 记录容器的生命周期事件
+func (t *ContainerInfos) emit(action string, attrs map[string]string) {
+	if attrs == nil {
+		attrs = map[string]string{}
+	}
+	if t.Image != "" {
+		attrs["image"] = t.Image
+	}
+	events.Emit(events.Container, action, t.Id, t.Name, attrs)
+}
+
+// 容器进程退出 先检查是否因为内存不足被kill 以及是否达到过进程数限制
+// oom的结果和退出码记录在OOMKilled和ExitCode中 由调用者保存容器信息
+func (t *ContainerInfos) emitDie(exitCode int) {
+	if t.Cg.Path != "" {
+		if count, err := t.Cg.OomKillCount(); err == nil && count > t.OomKills {
+			t.OomKills = count
+			t.OOMKilled = true
+			t.emit(events.ActionOom, nil)
+		}
+		t.checkPidsLimit()
+	}
+	t.ExitCode = exitCode
+	t.emit(events.ActionDie, map[string]string{"exitCode": strconv.Itoa(exitCode)})
 }
 
 func (t *ContainerInfos) DeleteContainerInfo() {
@@ -113,27 +193,45 @@ func (t *ContainerInfos) DeleteContainerInfo() {
 	}
 }
 
-func (t *ContainerInfos) WirteInfoToTabwriter(w *tabwriter.Writer) {
-	// "ID\tNAME\tPID\tSTATUS\tCOMMAND\tCREATED\n"
-	fmt.Fprintf(
-		w, "%s\t%s\t%s\t%s\t%s\t%s\n",
-		t.Id,
-		t.Name,
-		t.Pid,
-		t.Status,
-		t.Command,
-		t.CreateTime,
-	)
+func (t *ContainerInfos) statusString() string {
+	status := t.ObservedStatus()
+	if t.OOMKilled && status != Running {
+		return status + " (OOMKilled)"
+	}
+	return status
+}
+
+// 记录为运行中但进程已经不在时按退出显示 只读的命令不修改记录 由修改状态的命令修正
+func (t *ContainerInfos) ObservedStatus() string {
+	if t.Status == Running && !isContainerAlive(t) {
+		return Exit
+	}
+	return t.Status
 }
 
-func (t *ContainerInfos) randomContainerId(n int) {
-	letterSeed := "0123456789abcde"
-	rand.New(rand.NewSource(time.Now().UnixNano()))
+// 使用crypto/rand生成id 并保证短id不和已有的容器冲突
+func (t *ContainerInfos) randomContainerId(n int) error {
+	infos, err := ListContainerInfos()
+	if err != nil {
+		return err
+	}
 	b := make([]byte, n)
-	for i := range b {
-		b[i] = letterSeed[rand.Intn(len(letterSeed))]
+	for {
+		if _, err := rand.Read(b); err != nil {
+			return errors.Wrap(err, "fail to generate container id")
+		}
+		t.Id = hex.EncodeToString(b)
+		conflict := false
+		for _, info := range infos {
+			if strings.HasPrefix(info.Id, t.ShortId()) {
+				conflict = true
+				break
+			}
+		}
+		if !conflict {
+			return nil
+		}
 	}
-	t.Id = string(b)
 }
 
 func (t *ContainerInfos) recordToJsonfile(jsonStr string) error {
@@ -142,16 +240,13 @@ func (t *ContainerInfos) recordToJsonfile(jsonStr string) error {
 		return err
 	}
 
-	file, err := os.Create(path.Join(savefilepath, defaultInfoSavename))
-
-	if err != nil {
-		return err
-	}
+	return store.WithLock(getContainerLockName(t.Name), func() error {
+		return store.WriteFileAtomic(path.Join(savefilepath, defaultInfoSavename), []byte(jsonStr), 0644)
+	})
+}
 
-	if _, err := file.WriteString(jsonStr); err != nil {
-		return err
-	}
-	return nil
+func getContainerLockName(name string) string {
+	return "container/" + name
 }
 
 func (t *ContainerInfos) del() error {
@@ -159,9 +254,41 @@ func (t *ContainerInfos) del() error {
 	return os.RemoveAll(savefilepath)
 }
 
+// 只修改运行状态相关的字段 其他字段以保存的为准 不会覆盖其他命令同时做的修改
 func (t *ContainerInfos) modifyContainerStatusByName(status string) error {
 	t.Status = status
-	return t.RecordContainerInfo()
+	info, err := updateContainerInfo(t.Name, func(info *ContainerInfos) error {
+		info.Status = t.Status
+		info.Pid = t.Pid
+		info.StartTime = t.StartTime
+		info.ExitCode = t.ExitCode
+		info.OOMKilled = t.OOMKilled
+		info.OomKills = t.OomKills
+		info.PidsMaxHits = t.PidsMaxHits
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*t = *info
+	return nil
+}
+
+// 持有容器锁 读取保存的容器信息 交给fn修改后写回 fn返回错误时不会写入
+// fn中不能再调用RecordContainerInfo等需要容器锁的方法
+func updateContainerInfo(name string, fn func(info *ContainerInfos) error) (*ContainerInfos, error) {
+	info := &ContainerInfos{}
+	savefilepath := path.Join(defaultInfoSavefilepath, name, defaultInfoSavename)
+	err := store.UpdateJSON(getContainerLockName(name), savefilepath, info, func() error {
+		if info.Name == "" {
+			return fmt.Errorf("no such container: %s", name)
+		}
+		return fn(info)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
 }
 
 func GetInfoByContainerName(containerName string, data *ContainerInfos) error {
@@ -176,9 +303,34 @@ func GetInfoByContainerName(containerName string, data *ContainerInfos) error {
 	return nil
 }
 
+// 获取所有容器的信息
+func ListContainerInfos() ([]ContainerInfos, error) {
+	isExist, err := common.PathExist(defaultInfoSavefilepath)
+	if err != nil || !isExist {
+		return nil, err
+	}
+	files, err := os.ReadDir(defaultInfoSavefilepath)
+	if err != nil {
+		return nil, fmt.Errorf("read configfile error %v", err)
+	}
+	infos := make([]ContainerInfos, 0, len(files))
+	for _, file := range files {
+		var info ContainerInfos
+		if err := GetInfoByContainerName(file.Name(), &info); err != nil {
+			// 名称已经被占用但还在创建中的容器没有配置文件
+			if !os.IsNotExist(err) {
+				slog.Error("GetInfoByContainerName", "err", err)
+			}
+			continue
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
 func getPidByContainerName(name string) (string, error) {
 	data := ContainerInfos{}
-	if err := GetInfoByContainerName(name, &data); err != nil {
+	if err := GetInfoByContainerRef(name, &data); err != nil {
 		return "", err
 	}
 	return data.Pid, nil
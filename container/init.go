@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"syscall"
 
 	"github.com/pkg/errors"
@@ -16,10 +17,13 @@ import (
 )
 
 type initArgs struct {
-	Args      []string
-	MountRoot string
-	Hostname  string
-	NetnsName string
+	Args        []string
+	MountRoot   string
+	Hostname    string
+	NetnsName   string
+	OomScoreAdj int
+	Ulimits     []Ulimit
+	Sysctls     map[string]string
 }
 
 // 执行容器内应用进程
@@ -35,27 +39,47 @@ func runContainerProgram() error {
 	if err := json.Unmarshal(initArgsJsonStr, args); err != nil {
 		return err
 	}
-	// 给当前进程设置新的net namespaec
-	newNsfd, err := netns.GetFromName(args.NetnsName)
-	if err != nil {
-		return errors.Wrapf(err, "fail to get net fd %s", args.NetnsName)
+	// 给当前进程设置新的net namespaec 使用宿主机或其他容器的net namespace时为空
+	if args.NetnsName != "" {
+		newNsfd, err := netns.GetFromName(args.NetnsName)
+		if err != nil {
+			return errors.Wrapf(err, "fail to get net fd %s", args.NetnsName)
+		}
+		if err := unix.Setns(int(newNsfd), syscall.CLONE_NEWNET); err != nil {
+			return errors.Wrap(err, "fail to set net ")
+		}
+		slog.Debug("set ns", "unique id ", newNsfd.UniqueId())
 	}
-	if err := unix.Setns(int(newNsfd), syscall.CLONE_NEWNET); err != nil {
-		return errors.Wrap(err, "fail to set net ")
+
+	// 子进程会继承oom_score_adj 在exec之前设置
+	if args.OomScoreAdj != 0 {
+		if err := os.WriteFile("/proc/self/oom_score_adj", []byte(strconv.Itoa(args.OomScoreAdj)), 0644); err != nil {
+			return errors.Wrap(err, "fail to set oom score adj")
+		}
 	}
-	slog.Debug("set ns", "unique id ", newNsfd.UniqueId())
 
 	if err := setUpMount(args.MountRoot); err != nil {
 		return err
 	}
 
+	if err := applySysctls(args.Sysctls); err != nil {
+		return err
+	}
+
+	if err := applyUlimits(args.Ulimits); err != nil {
+		return err
+	}
+
 	command := args.Args
 	path, err := exec.LookPath(command[0])
 	if err != nil {
 		return err
 	}
 	slog.Info("LookPath", "path", path)
-	syscall.Sethostname([]byte(os.Getenv(args.Hostname)))
+	// 共享宿主机的uts namespace时不能修改hostname
+	if args.Hostname != "" {
+		syscall.Sethostname([]byte(os.Getenv(args.Hostname)))
+	}
 	if err := syscall.Exec(path, command[0:], os.Environ()); err != nil {
 		return fmt.Errorf("syscall exec %v", err)
 	}
@@ -4,10 +4,10 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"strconv"
 	"time"
 
 	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/kehaha-5/go-low-level-container/events"
 	"github.com/pkg/errors"
 )
 
@@ -21,7 +21,7 @@ func LoadImage(image string) error {
 	}
 	isExist, _ := common.PathExist(saveImagePaths)
 	if !isExist {
-		if err := os.MkdirAll(saveImagePaths, 0644); err != nil {
+		if err := os.MkdirAll(saveImagePaths, 0755); err != nil {
 			return errors.Wrap(err, "fail to mkdir file")
 		}
 	}
@@ -39,16 +39,10 @@ func LoadImage(image string) error {
 	}
 	createTime := time.Now().In(tz).Format(time.RFC3339)
 
-	imageInfo := imageInfoItem{Name: fInfo.Name(), Size: sizeHumanReadable(fInfo.Size()), ID: common.RangeStr(8), CreateTime: createTime}
-	return addImage(&imageInfo)
-}
-
-func sizeHumanReadable(size int64) string {
-	unit := []string{"B", "KB", "MB", "GB", "TB"}
-	unitI := 0
-	for 1024 <= size {
-		unitI++
-		size = size / 1024
+	imageInfo := ImageInfo{Name: fInfo.Name(), Size: common.SizeHumanReadable(fInfo.Size()), ID: common.RangeStr(8), CreateTime: createTime}
+	if err := addImage(&imageInfo); err != nil {
+		return err
 	}
-	return strconv.FormatInt(size, 10) + unit[unitI]
+	events.Emit(events.Image, events.ActionLoad, imageInfo.ID, imageInfo.Name, nil)
+	return nil
 }
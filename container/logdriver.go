@@ -0,0 +1,106 @@
+package container
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	jsonFileLogDriver string = "json-file"
+	syslogLogDriver   string = "syslog"
+	gelfLogDriver     string = "gelf"
+	noneLogDriver     string = "none"
+)
+
+const (
+	logOptTag string = "tag"
+)
+
+// 日志驱动 logger进程把容器的每一行输出交给驱动处理
+type LogDriver interface {
+	WriteLog(entry *logEntry) error
+	Close() error
+}
+
+type logDriverInfo struct {
+	// 检查log-opt 在run的时候提前报错
+	validate func(opts map[string]string) error
+	create   func(containerName string, opts map[string]string) (LogDriver, error)
+	// 能否通过logs命令读取
+	readable bool
+}
+
+var logDrivers = map[string]logDriverInfo{
+	jsonFileLogDriver: {
+		validate: func(opts map[string]string) error {
+			_, err := newJsonFileOptions(opts)
+			return err
+		},
+		create: func(containerName string, opts map[string]string) (LogDriver, error) {
+			jsonOpts, err := newJsonFileOptions(opts)
+			if err != nil {
+				return nil, err
+			}
+			return newJsonFileWriter(containerName, jsonOpts)
+		},
+		readable: true,
+	},
+	syslogLogDriver: {
+		validate: func(opts map[string]string) error {
+			_, err := newSyslogOptions("", opts)
+			return err
+		},
+		create: newSyslogWriter,
+	},
+	gelfLogDriver: {
+		validate: func(opts map[string]string) error {
+			_, err := newGelfOptions("", opts)
+			return err
+		},
+		create: newGelfWriter,
+	},
+	noneLogDriver: {
+		validate: func(opts map[string]string) error {
+			for k := range opts {
+				return fmt.Errorf("unknown log opt %s for log driver %s", k, noneLogDriver)
+			}
+			return nil
+		},
+	},
+}
+
+func getLogDriverInfo(driver string) (*logDriverInfo, error) {
+	// 旧版本的容器没有记录驱动
+	if driver == "" {
+		driver = jsonFileLogDriver
+	}
+	info, ok := logDrivers[driver]
+	if !ok {
+		return nil, fmt.Errorf("unknown log driver %s", driver)
+	}
+	return &info, nil
+}
+
+func newLogDriver(containerName string, conf *LogConfig) (LogDriver, error) {
+	info, err := getLogDriverInfo(conf.Driver)
+	if err != nil {
+		return nil, err
+	}
+	return info.create(containerName, conf.Opts)
+}
+
+// tag默认是容器名
+func getLogTag(containerName string, opts map[string]string) string {
+	if tag, ok := opts[logOptTag]; ok && tag != "" {
+		return tag
+	}
+	return containerName
+}
+
+func getLogHostname() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "-"
+	}
+	return hostname
+}
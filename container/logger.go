@@ -0,0 +1,185 @@
+package container
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// logger进程执行的命令
+	LoggerCommand string = "logger"
+	stdoutStream  string = "stdout"
+	stderrStream  string = "stderr"
+
+	// 日志驱动不可用时重新连接的间隔
+	minLogDriverBackoff = time.Second
+	maxLogDriverBackoff = time.Minute
+)
+
+// 启动logger进程 返回容器标准输出和标准错误应该写入的管道
+// logger进程和容器的生命周期一致 容器退出后管道关闭logger也会退出
+func startLogger(containerName string, conf *LogConfig) (*os.File, *os.File, error) {
+	// 不需要日志时不启动logger 输出直接丢弃
+	if conf.Driver == noneLogDriver {
+		return openDevNull()
+	}
+	// 先连接一次日志驱动 不可用时直接报错 而不是容器运行后输出才丢失
+	w, err := newLogDriver(containerName, conf)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "log driver %s is unavailable", conf.Driver)
+	}
+	w.Close()
+	stdoutR, stdoutW, err := newPipe()
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	stderrR, stderrW, err := newPipe()
+	if err != nil {
+		stdoutR.Close()
+		stdoutW.Close()
+		return nil, nil, errors.WithStack(err)
+	}
+	// 父进程中只保留写端
+	defer stdoutR.Close()
+	defer stderrR.Close()
+
+	confJson, err := json.Marshal(conf)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	logger := exec.Command("/proc/self/exe", LoggerCommand, containerName, string(confJson))
+	logger.ExtraFiles = []*os.File{stdoutR, stderrR}
+	// 脱离当前会话 命令行退出后继续运行
+	logger.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := logger.Start(); err != nil {
+		stdoutW.Close()
+		stderrW.Close()
+		return nil, nil, errors.Wrap(err, "fail to start logger")
+	}
+	logger.Process.Release()
+	return stdoutW, stderrW, nil
+}
+
+// 把容器进程的输出接到logger 返回的函数在容器进程启动后调用 关闭父进程中的写端
+func attachLogger(cmd *exec.Cmd, containerName string, conf *LogConfig) (func(), error) {
+	stdoutW, stderrW, err := startLogger(containerName, conf)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderrW
+	return func() {
+		stdoutW.Close()
+		stderrW.Close()
+	}, nil
+}
+
+// logger进程 从fd 3 4读取容器的标准输出和标准错误 写入日志
+func RunLogger(containerName string, confJson string) error {
+	conf := &LogConfig{}
+	if err := json.Unmarshal([]byte(confJson), conf); err != nil {
+		return errors.WithStack(err)
+	}
+	// 驱动不可用时仍然要读取管道 否则容器写输出时会被阻塞或者收到SIGPIPE
+	// 期间的输出被丢弃 按退避的间隔重新连接
+	w, err := newLogDriver(containerName, conf)
+	backoff := minLogDriverBackoff
+	retryAt := time.Now().Add(backoff)
+	dropped := 0
+	if err != nil {
+		slog.Error("logger", "driver", conf.Driver, "err", err)
+		writeLogMarker(containerName, fmt.Sprintf("log driver %s is unavailable, output is dropped until it reconnects: %v", conf.Driver, err))
+	}
+
+	entries := make(chan *logEntry, 64)
+	var wg sync.WaitGroup
+	for i, stream := range []string{stdoutStream, stderrStream} {
+		wg.Add(1)
+		go func(f *os.File, stream string) {
+			defer wg.Done()
+			defer f.Close()
+			copyLogStream(f, stream, entries)
+		}(os.NewFile(uintptr(3+i), stream), stream)
+	}
+	go func() {
+		wg.Wait()
+		close(entries)
+	}()
+
+	for entry := range entries {
+		if w == nil && time.Now().After(retryAt) {
+			if w, err = newLogDriver(containerName, conf); err != nil {
+				backoff = min(backoff*2, maxLogDriverBackoff)
+				retryAt = time.Now().Add(backoff)
+			} else {
+				writeLogMarker(containerName, fmt.Sprintf("log driver %s reconnected, %d lines were dropped", conf.Driver, dropped))
+				dropped = 0
+			}
+		}
+		if w == nil {
+			dropped++
+			continue
+		}
+		if err := w.WriteLog(entry); err != nil {
+			slog.Error("logger", "driver", conf.Driver, "err", err)
+		}
+	}
+	if w == nil {
+		writeLogMarker(containerName, fmt.Sprintf("log driver %s never reconnected, %d lines were dropped", conf.Driver, dropped))
+		return nil
+	}
+	return w.Close()
+}
+
+// 在本地的json-file中记录日志驱动的状态 logs命令可以看到输出丢失的原因
+func writeLogMarker(containerName string, msg string) {
+	w, err := newJsonFileWriter(containerName, &jsonFileOptions{})
+	if err != nil {
+		slog.Error("logger marker", "err", err)
+		return
+	}
+	defer w.Close()
+	if err := w.WriteLog(&logEntry{Log: "mydocker: " + msg + "\n", Stream: stderrStream, Time: time.Now().UTC()}); err != nil {
+		slog.Error("logger marker", "err", err)
+	}
+}
+
+// 按行读取 超长的行拆成多条
+func copyLogStream(r io.Reader, stream string, entries chan<- *logEntry) {
+	reader := bufio.NewReaderSize(r, maxLogLineSize)
+	for {
+		line, err := reader.ReadSlice('\n')
+		if len(line) != 0 {
+			entries <- &logEntry{Log: string(line), Stream: stream, Time: time.Now().UTC()}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func openDevNull() (*os.File, *os.File, error) {
+	stdoutW, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	stderrW, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		stdoutW.Close()
+		return nil, nil, errors.WithStack(err)
+	}
+	return stdoutW, stderrW, nil
+}
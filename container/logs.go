@@ -1,43 +1,589 @@
 package container
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/pkg/errors"
 )
 
 const (
 	defautlLogSavename string = "container.log"
+	// 超过这个长度的行会被拆分成多条记录
+	maxLogLineSize    int           = 16 * 1024
+	logFollowInterval time.Duration = 200 * time.Millisecond
+)
+
+const (
+	logOptMaxSize  string = "max-size"
+	logOptMaxFile  string = "max-file"
+	logOptCompress string = "compress"
 )
 
 var (
 	defaultLogSavefilepath string = common.ROOTPATH + "/container/"
 )
 
-func createlogfilePointer(containerName string) (*os.File, error) {
+// 容器的日志配置
+type LogConfig struct {
+	Driver string            `json:"driver"`
+	Opts   map[string]string `json:"opts"`
+}
+
+// json-file 格式中的一条日志
+type logEntry struct {
+	Log    string    `json:"log"`
+	Stream string    `json:"stream"`
+	Time   time.Time `json:"time"`
+}
+
+// 解析 --log-driver 和 --log-opt key=value
+func NewLogConfig(driver string, opts []string) (*LogConfig, error) {
+	if driver == "" {
+		driver = jsonFileLogDriver
+	}
+	conf := &LogConfig{Driver: driver, Opts: map[string]string{}}
+	for _, item := range opts {
+		k, v, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid log opt %s (expected key=value)", item)
+		}
+		conf.Opts[k] = v
+	}
+	driverInfo, err := getLogDriverInfo(driver)
+	if err != nil {
+		return nil, err
+	}
+	if err := driverInfo.validate(conf.Opts); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+type jsonFileOptions struct {
+	maxSize  int64 // 0 表示不限制
+	maxFile  int
+	compress bool
+}
+
+func newJsonFileOptions(opts map[string]string) (*jsonFileOptions, error) {
+	res := &jsonFileOptions{maxFile: 1}
+	for k, v := range opts {
+		var err error
+		switch k {
+		case logOptMaxSize:
+			res.maxSize, err = common.ParseSize(v)
+		case logOptMaxFile:
+			res.maxFile, err = strconv.Atoi(v)
+			if err == nil && res.maxFile < 1 {
+				err = fmt.Errorf("max-file must be greater than 0")
+			}
+		case logOptCompress:
+			res.compress, err = strconv.ParseBool(v)
+		default:
+			err = fmt.Errorf("unknown log opt %s for log driver %s", k, jsonFileLogDriver)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid log opt %s=%s", k, v)
+		}
+	}
+	return res, nil
+}
+
+func getLogFilePath(containerName string) string {
+	return path.Join(defaultLogSavefilepath, containerName, defautlLogSavename)
+}
+
+// 第n个轮转的日志文件 压缩后带.gz后缀
+func getRotatedLogFilePath(containerName string, n int, compress bool) string {
+	logfile := fmt.Sprintf("%s.%d", getLogFilePath(containerName), n)
+	if compress {
+		logfile += ".gz"
+	}
+	return logfile
+}
+
+// json-file格式的日志写入 按大小轮转
+type jsonFileWriter struct {
+	containerName string
+	opts          *jsonFileOptions
+	file          *os.File
+	size          int64
+}
+
+func newJsonFileWriter(containerName string, opts *jsonFileOptions) (*jsonFileWriter, error) {
 	logfilepath := path.Join(defaultLogSavefilepath, containerName)
 	if err := os.MkdirAll(logfilepath, 0777); err != nil {
 		return nil, err
 	}
-	logfile := path.Join(logfilepath, defautlLogSavename)
-	file, err := os.Create(logfile)
+	// 追加写入 重启后保留之前的日志
+	file, err := os.OpenFile(getLogFilePath(containerName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	fInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &jsonFileWriter{containerName: containerName, opts: opts, file: file, size: fInfo.Size()}, nil
+}
+
+func (t *jsonFileWriter) WriteLog(entry *logEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	line = append(line, '\n')
+	if t.opts.maxSize > 0 && t.size+int64(len(line)) > t.opts.maxSize && t.size > 0 {
+		if err := t.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := t.file.Write(line)
+	t.size += int64(n)
+	return errors.WithStack(err)
+}
+
+// container.log -> container.log.1 -> container.log.2 ... 超过max-file的删除
+func (t *jsonFileWriter) rotate() error {
+	if err := t.file.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	logfile := getLogFilePath(t.containerName)
+	if t.opts.maxFile > 1 {
+		os.Remove(getRotatedLogFilePath(t.containerName, t.opts.maxFile-1, t.opts.compress))
+		for i := t.opts.maxFile - 2; i > 0; i-- {
+			os.Rename(getRotatedLogFilePath(t.containerName, i, t.opts.compress), getRotatedLogFilePath(t.containerName, i+1, t.opts.compress))
+		}
+		if t.opts.compress {
+			if err := compressFile(logfile, getRotatedLogFilePath(t.containerName, 1, true)); err != nil {
+				return err
+			}
+		} else if err := os.Rename(logfile, getRotatedLogFilePath(t.containerName, 1, false)); err != nil {
+			return errors.WithStack(err)
+		}
+	} else if err := os.Remove(logfile); err != nil {
+		// 不在原来的文件上清空 正在follow的logs可以读完剩下的日志
+		return errors.WithStack(err)
+	}
+	file, err := os.OpenFile(logfile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	t.file = file
+	t.size = 0
+	return nil
+}
+
+func (t *jsonFileWriter) Close() error {
+	return t.file.Close()
+}
+
+func compressFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer out.Close()
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := gz.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Remove(src))
+}
+
+// logs命令的参数
+type LogsOptions struct {
+	Follow     bool
+	Tail       int // 小于0时输出全部
+	Since      time.Time
+	Until      time.Time
+	Timestamps bool
+	Stdout     bool
+	Stderr     bool
+}
+
+// 解析logs命令的参数 tail为all或者数字 stdout和stderr都没有指定时两个都输出
+func NewLogsOptions(follow bool, tail string, since string, until string, timestamps bool, stdout bool, stderr bool) (*LogsOptions, error) {
+	opts := &LogsOptions{Follow: follow, Tail: -1, Timestamps: timestamps, Stdout: stdout, Stderr: stderr}
+	if !stdout && !stderr {
+		opts.Stdout = true
+		opts.Stderr = true
+	}
+	if tail != "" && tail != "all" {
+		n, err := strconv.Atoi(tail)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid tail value %s", tail)
+		}
+		opts.Tail = n
+	}
+	var err error
+	if since != "" {
+		if opts.Since, err = common.ParseTime(since); err != nil {
+			return nil, err
+		}
+	}
+	if until != "" {
+		if opts.Until, err = common.ParseTime(until); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+func (t *LogsOptions) match(entry *logEntry) bool {
+	if entry.Stream == stdoutStream && !t.Stdout {
+		return false
+	}
+	if entry.Stream == stderrStream && !t.Stderr {
+		return false
+	}
+	if !t.Since.IsZero() && !entry.Time.IsZero() && entry.Time.Before(t.Since) {
+		return false
+	}
+	if !t.Until.IsZero() && !entry.Time.IsZero() && entry.Time.After(t.Until) {
+		return false
+	}
+	return true
+}
+
+func (t *LogsOptions) write(entry *logEntry, stdout io.Writer, stderr io.Writer) {
+	w := stdout
+	if entry.Stream == stderrStream {
+		w = stderr
+	}
+	if t.Timestamps {
+		fmt.Fprint(w, entry.Time.Format(time.RFC3339Nano), " ")
+	}
+	fmt.Fprint(w, entry.Log)
+}
+
+// 按条件输出容器日志 日志文件是逐行读取的 不会整个读入内存
+func WriteContainerLogs(containerRef string, opts *LogsOptions, stdout io.Writer, stderr io.Writer) error {
+	info := ContainerInfos{}
+	if err := GetInfoByContainerRef(containerRef, &info); err != nil {
+		return err
+	}
+	driverInfo, err := getLogDriverInfo(info.LogConfig.Driver)
+	if err != nil {
+		return err
+	}
+	if !driverInfo.readable {
+		// 驱动不可用时logger在本地记录了原因
+		if _, err := readLogFileEntries(getLogFilePath(info.Name), 0, func(entry *logEntry) error {
+			opts.write(entry, stdout, stderr)
+			return nil
+		}); err != nil {
+			return err
+		}
+		return fmt.Errorf("configured log driver %s does not support reading", info.LogConfig.Driver)
+	}
+
+	// 记录读到的位置 follow时从这里继续读
+	var offset int64
+	if opts.Tail < 0 {
+		files := getLogFiles(info.Name)
+		for i, logfile := range files {
+			end, err := readLogFileEntries(logfile, 0, func(entry *logEntry) error {
+				if opts.match(entry) {
+					opts.write(entry, stdout, stderr)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if i == len(files)-1 {
+				offset = end
+			}
+		}
+	} else {
+		entries, end, err := tailLogEntries(info.Name, opts)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			opts.write(entry, stdout, stderr)
+		}
+		offset = end
+	}
+
+	if !opts.Follow {
+		return nil
+	}
+	return followLog(&info, offset, opts, stdout, stderr)
+}
+
+// 从旧到新的所有日志文件 最后一个是正在写入的日志文件
+func getLogFiles(containerName string) []string {
+	files := []string{}
+	for i := 1; ; i++ {
+		if common.FileExist(getRotatedLogFilePath(containerName, i, false)) {
+			files = append(files, getRotatedLogFilePath(containerName, i, false))
+		} else if common.FileExist(getRotatedLogFilePath(containerName, i, true)) {
+			files = append(files, getRotatedLogFilePath(containerName, i, true))
+		} else {
+			break
+		}
+	}
+	// 轮转的序号越大越旧
+	slices.Reverse(files)
+	return append(files, getLogFilePath(containerName))
+}
+
+// 从offset开始按行读取日志文件 返回读完的位置 没有换行的最后一行留到下次读取
+func readLogFileEntries(logfile string, offset int64, fn func(entry *logEntry) error) (int64, error) {
+	f, err := os.Open(logfile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, errors.WithStack(err)
+	}
+	defer f.Close()
+	return readLogEntries(f, strings.HasSuffix(logfile, ".gz"), offset, fn)
+}
+
+// 压缩文件只能从头读取 忽略offset
+func readLogEntries(f *os.File, compressed bool, offset int64, fn func(entry *logEntry) error) (int64, error) {
+	var reader io.Reader = f
+	if compressed {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		defer gz.Close()
+		reader = gz
+	} else if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	bufReader := bufio.NewReader(reader)
+	for {
+		line, err := bufReader.ReadBytes('\n')
+		if err == io.EOF {
+			return offset, nil
+		}
+		if err != nil {
+			return offset, errors.WithStack(err)
+		}
+		offset += int64(len(line))
+		if err := fn(parseLogLine(line)); err != nil {
+			return offset, err
+		}
+	}
+}
+
+// 从新到旧读取 直到拿到最后tail条匹配的日志
+// 没有压缩的文件从后往前按块读取 压缩文件只能从前往后读 用环形缓冲只保留最后tail条
+// 返回正在写入的日志文件读到的位置 follow时从这里继续读
+func tailLogEntries(containerName string, opts *LogsOptions) ([]*logEntry, int64, error) {
+	files := getLogFiles(containerName)
+	var offset int64
+	res := []*logEntry{} // 从新到旧
+	for i := len(files) - 1; i >= 0; i-- {
+		// 正在写入的文件即使不需要日志也要读取 拿到follow的位置
+		if i != len(files)-1 && len(res) >= opts.Tail {
+			break
+		}
+		need := opts.Tail - len(res)
+		var entries []*logEntry
+		var err error
+		if strings.HasSuffix(files[i], ".gz") {
+			entries, err = tailCompressedLogFile(files[i], need, opts)
+		} else {
+			var end int64
+			entries, end, err = tailPlainLogFile(files[i], need, opts)
+			if i == len(files)-1 {
+				offset = end
+			}
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		res = append(res, entries...)
+	}
+	slices.Reverse(res)
+	return res, offset, nil
+}
+
+// 返回的日志从新到旧 以及最后一个完整行的结尾 后面还没写完的行留给follow读取
+func tailPlainLogFile(logfile string, need int, opts *LogsOptions) ([]*logEntry, int64, error) {
+	f, err := os.Open(logfile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, errors.WithStack(err)
+	}
+	defer f.Close()
+	fInfo, err := f.Stat()
+	if err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+
+	const chunkSize = 64 * 1024
+	res := []*logEntry{}
+	pos := fInfo.Size()
+	end := int64(-1)
+	var rest []byte // 还没有遇到行首的部分
+	for pos > 0 && (end < 0 || len(res) < need) {
+		n := int64(chunkSize)
+		if pos < n {
+			n = pos
+		}
+		pos -= n
+		chunk := make([]byte, n, n+int64(len(rest)))
+		if _, err := f.ReadAt(chunk, pos); err != nil {
+			return nil, 0, errors.WithStack(err)
+		}
+		chunk = append(chunk, rest...)
+		lines := chunk
+		// 最后一个换行后面是还没写完的行
+		if end < 0 {
+			idx := bytes.LastIndexByte(chunk, '\n')
+			if idx < 0 {
+				rest = chunk
+				continue
+			}
+			end = pos + int64(idx) + 1
+			lines = chunk[:idx+1]
+		}
+		for len(lines) != 0 && len(res) < need {
+			idx := bytes.LastIndexByte(lines[:len(lines)-1], '\n')
+			if idx < 0 && pos != 0 {
+				break
+			}
+			entry := parseLogLine(lines[idx+1:])
+			lines = lines[:idx+1]
+			if opts.match(entry) {
+				res = append(res, entry)
+			}
+		}
+		rest = lines
+	}
+	if end < 0 {
+		end = 0
+	}
+	return res, end, nil
+}
+
+// 返回的日志从新到旧
+func tailCompressedLogFile(logfile string, need int, opts *LogsOptions) ([]*logEntry, error) {
+	ring := make([]*logEntry, need)
+	count := 0
+	_, err := readLogFileEntries(logfile, 0, func(entry *logEntry) error {
+		if opts.match(entry) {
+			ring[count%need] = entry
+			count++
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	return file, err
+	res := []*logEntry{}
+	for i := count - 1; i >= 0 && i >= count-need; i-- {
+		res = append(res, ring[i%need])
+	}
+	return res, nil
+}
+
+// 持续读取新写入的日志 直到容器退出
+// 一直打开着正在读的文件 轮转后原来的文件被改名或者压缩删除 仍然可以从它读完剩下的日志
+func followLog(info *ContainerInfos, offset int64, opts *LogsOptions, stdout io.Writer, stderr io.Writer) error {
+	logfile := getLogFilePath(info.Name)
+	writeEntry := func(entry *logEntry) error {
+		if opts.match(entry) {
+			opts.write(entry, stdout, stderr)
+		}
+		return nil
+	}
+	var f *os.File
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+	for {
+		// 先判断记录的进程是否还在 保证退出前写入的日志都能读到
+		// 不看记录的状态 状态可能已经被修改但进程还没有退出
+		alive := isContainerAlive(info)
+
+		// 日志被轮转了 先读完原来的文件 再从新文件的开头读
+		if f != nil && isLogRotated(f, logfile) {
+			if _, err := readLogEntries(f, false, offset, writeEntry); err != nil {
+				return err
+			}
+			f.Close()
+			f = nil
+			offset = 0
+		}
+		if f == nil {
+			file, err := os.Open(logfile)
+			if err != nil && !os.IsNotExist(err) {
+				return errors.WithStack(err)
+			}
+			f = file
+		}
+		if f != nil {
+			// 日志文件被清空了
+			if fInfo, err := f.Stat(); err == nil && fInfo.Size() < offset {
+				offset = 0
+			}
+			end, err := readLogEntries(f, false, offset, writeEntry)
+			if err != nil {
+				return err
+			}
+			offset = end
+		}
+
+		if !alive || (!opts.Until.IsZero() && time.Now().After(opts.Until)) {
+			return nil
+		}
+		time.Sleep(logFollowInterval)
+	}
 }
 
-func GetLogByContainerName(containerName string) (string, error) {
-	logfile := path.Join(defaultLogSavefilepath, containerName, defautlLogSavename)
-	log, err := os.ReadFile(logfile)
+// 文件已经被删除 或者路径指向了新的文件
+func isLogRotated(f *os.File, logfile string) bool {
+	fInfo, err := f.Stat()
 	if err != nil {
-		return "", err
+		return true
+	}
+	if stat, ok := fInfo.Sys().(*syscall.Stat_t); ok && stat.Nlink == 0 {
+		return true
 	}
-	return string(log), nil
+	pathInfo, err := os.Stat(logfile)
+	return err != nil || !os.SameFile(fInfo, pathInfo)
 }
 
-func delLogByContainerName(containerName string) error {
-	logfile := path.Join(defaultLogSavefilepath, containerName, defautlLogSavename)
-	return os.Remove(logfile)
+// 旧版本的日志是原始输出 当作stdout处理
+func parseLogLine(line []byte) *logEntry {
+	entry := &logEntry{}
+	if err := json.Unmarshal(line, entry); err != nil || entry.Stream == "" {
+		return &logEntry{Log: string(line), Stream: stdoutStream}
+	}
+	return entry
 }
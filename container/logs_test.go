@@ -0,0 +1,42 @@
+package container
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestTailPlainLogFile(t *testing.T) {
+	logfile := path.Join(t.TempDir(), "container.log")
+	complete := `{"log":"a\n","stream":"stdout"}` + "\n" +
+		`{"log":"b\n","stream":"stderr"}` + "\n" +
+		`{"log":"c\n","stream":"stdout"}` + "\n"
+	// 最后一行还没有写完
+	if err := os.WriteFile(logfile, []byte(complete+`{"log":"d`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts, err := NewLogsOptions(false, "2", "", "", false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, end, err := tailPlainLogFile(logfile, 2, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Log != "c\n" || entries[1].Log != "b\n" {
+		t.Errorf("tailPlainLogFile got %+v", entries)
+	}
+	if end != int64(len(complete)) {
+		t.Errorf("tailPlainLogFile end %d, want %d", end, len(complete))
+	}
+
+	// 不需要日志时也要返回follow的位置
+	entries, end, err = tailPlainLogFile(logfile, 0, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 || end != int64(len(complete)) {
+		t.Errorf("tailPlainLogFile(0) got %d entries end %d", len(entries), end)
+	}
+}
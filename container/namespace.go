@@ -0,0 +1,232 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	NamespaceModeHost      string = "host"
+	NamespaceModeNone      string = "none"
+	NamespaceModePrivate   string = "private"
+	NamespaceModeShareable string = "shareable"
+	namespaceModeContainer string = "container:"
+	namespaceModePod       string = "pod:"
+)
+
+// 容器各个namespace的来源 空为容器独立的namespace
+// host使用宿主机的 container:<name>加入另一个运行中容器的 pod:<name>加入pod的infra进程的
+type NamespaceModes struct {
+	Net string `json:"net"` // host none container:<name> pod:<name>
+	Pid string `json:"pid"` // host container:<name>
+	Ipc string `json:"ipc"` // host private shareable container:<name> pod:<name>
+	Uts string `json:"uts"` // host pod:<name>
+}
+
+// 需要加入的namespace 按这个顺序setns
+var joinableNamespaces = []struct {
+	name  string
+	flag  int
+	clone uintptr
+}{
+	{"ipc", unix.CLONE_NEWIPC, syscall.CLONE_NEWIPC},
+	{"uts", unix.CLONE_NEWUTS, syscall.CLONE_NEWUTS},
+	{"net", unix.CLONE_NEWNET, syscall.CLONE_NEWNET},
+	{"pid", unix.CLONE_NEWPID, syscall.CLONE_NEWPID},
+}
+
+// --net的值可以是网络名 也可以是namespace模式
+func ParseNetMode(value string) (mode string, networkName string) {
+	if value == NamespaceModeHost || value == NamespaceModeNone || strings.HasPrefix(value, namespaceModeContainer) {
+		return value, ""
+	}
+	return "", value
+}
+
+func (t *NamespaceModes) Validate() error {
+	for _, item := range []struct {
+		kind    string
+		mode    string
+		allowed []string
+	}{
+		{"net", t.Net, []string{"", NamespaceModeHost, NamespaceModeNone}},
+		{"pid", t.Pid, []string{"", NamespaceModeHost}},
+		{"ipc", t.Ipc, []string{"", NamespaceModeHost, NamespaceModePrivate, NamespaceModeShareable}},
+		{"uts", t.Uts, []string{"", NamespaceModeHost}},
+	} {
+		if item.kind != "uts" && strings.HasPrefix(item.mode, namespaceModeContainer) {
+			if strings.TrimPrefix(item.mode, namespaceModeContainer) == "" {
+				return fmt.Errorf("invalid %s mode %s (missing container name)", item.kind, item.mode)
+			}
+			continue
+		}
+		// pod只共享net ipc uts
+		if item.kind != "pid" && strings.HasPrefix(item.mode, namespaceModePod) {
+			if strings.TrimPrefix(item.mode, namespaceModePod) == "" {
+				return fmt.Errorf("invalid %s mode %s (missing pod name)", item.kind, item.mode)
+			}
+			continue
+		}
+		valid := false
+		for _, allowed := range item.allowed {
+			valid = valid || item.mode == allowed
+		}
+		if !valid {
+			return fmt.Errorf("invalid %s mode %s", item.kind, item.mode)
+		}
+	}
+	return nil
+}
+
+func (t *NamespaceModes) modeOf(name string) string {
+	switch name {
+	case "net":
+		return t.Net
+	case "pid":
+		return t.Pid
+	case "ipc":
+		return t.Ipc
+	case "uts":
+		return t.Uts
+	}
+	return ""
+}
+
+// 容器有自己的net namespace 需要通过ip netns管理
+func (t *NamespaceModes) OwnNetns() bool {
+	return t.Net == "" || t.Net == NamespaceModeNone
+}
+
+// 容器的hostname只在独立的uts namespace中设置
+func (t *NamespaceModes) OwnUts() bool {
+	return t.Uts == ""
+}
+
+// 是否有namespace来自宿主机或其他容器
+func (t *NamespaceModes) shared() bool {
+	return t.cloneflags() != uintptr(syscall.CLONE_NEWNS|syscall.CLONE_NEWIPC|syscall.CLONE_NEWUTS|syscall.CLONE_NEWNET|syscall.CLONE_NEWPID)
+}
+
+// 使用宿主机或其他容器的namespace时不能修改对应的sysctl
+func (t *NamespaceModes) checkSysctls(sysctls map[string]string) error {
+	for key := range sysctls {
+		if strings.HasPrefix(key, "net.") && !t.OwnNetns() {
+			return fmt.Errorf("sysctl %s is not allowed with net mode %s", key, t.Net)
+		}
+		if !strings.HasPrefix(key, "net.") && (t.Ipc == NamespaceModeHost || isJoinMode(t.Ipc)) {
+			return fmt.Errorf("sysctl %s is not allowed with ipc mode %s", key, t.Ipc)
+		}
+	}
+	return nil
+}
+
+// mount namespace始终是独立的 其他的只有独立模式才新建
+func (t *NamespaceModes) cloneflags() uintptr {
+	flags := uintptr(syscall.CLONE_NEWNS)
+	for _, ns := range joinableNamespaces {
+		mode := t.modeOf(ns.name)
+		if mode == NamespaceModeHost || isJoinMode(mode) {
+			continue
+		}
+		flags |= ns.clone
+	}
+	return flags
+}
+
+// 加入其他容器或pod的namespace
+func isJoinMode(mode string) bool {
+	return strings.HasPrefix(mode, namespaceModeContainer) || strings.HasPrefix(mode, namespaceModePod)
+}
+
+// 找到要加入的容器或pod的namespace文件 目标必须在运行
+func (t *NamespaceModes) joinPaths() (map[string]string, error) {
+	res := map[string]string{}
+	for _, ns := range joinableNamespaces {
+		if podName, ok := strings.CutPrefix(t.modeOf(ns.name), namespaceModePod); ok {
+			pod, err := GetPod(podName)
+			if err != nil {
+				return nil, err
+			}
+			if !pod.isAlive() {
+				return nil, fmt.Errorf("pod %s is not running", pod.Name)
+			}
+			res[ns.name] = fmt.Sprintf("/proc/%s/ns/%s", pod.InfraPid, ns.name)
+			continue
+		}
+		target, ok := strings.CutPrefix(t.modeOf(ns.name), namespaceModeContainer)
+		if !ok {
+			continue
+		}
+		info := ContainerInfos{}
+		if err := GetInfoByContainerRef(target, &info); err != nil {
+			return nil, errors.Wrapf(err, "fail to find %s namespace container %s", ns.name, target)
+		}
+		if info.Status != Running {
+			return nil, fmt.Errorf("can not join %s namespace of container %s which is not running", ns.name, info.Name)
+		}
+		// 独立的ipc namespace默认是私有的 只有shareable才能被其他容器加入
+		if ns.name == "ipc" && info.Namespaces.Ipc != NamespaceModeShareable && info.Namespaces.Ipc != NamespaceModeHost {
+			return nil, fmt.Errorf("ipc namespace of container %s is not shareable", info.Name)
+		}
+		res[ns.name] = fmt.Sprintf("/proc/%s/ns/%s", info.Pid, ns.name)
+	}
+	return res, nil
+}
+
+// 设置cmd要新建的namespace
+func (t *NamespaceModes) setCloneflags(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Cloneflags = t.cloneflags()
+}
+
+// 和nsenter中exec的做法一样 通过/proc/<pid>/ns/*加入其他容器的namespace
+// setns只修改当前线程 在锁定的线程中setns后启动进程 子进程会继承这些namespace
+// 线程不解锁 goroutine结束时线程随之退出 不会影响其他goroutine
+func startInNamespaces(cmd *exec.Cmd, modes *NamespaceModes) error {
+	joins, err := modes.joinPaths()
+	if err != nil {
+		return err
+	}
+	return startWithJoins(cmd, joins)
+}
+
+// joins为namespace类型到namespace文件的映射
+func startWithJoins(cmd *exec.Cmd, joins map[string]string) error {
+	if len(joins) == 0 {
+		return cmd.Start()
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		for _, ns := range joinableNamespaces {
+			nspath, ok := joins[ns.name]
+			if !ok {
+				continue
+			}
+			if err := setns(nspath, ns.flag); err != nil {
+				errCh <- err
+				return
+			}
+		}
+		errCh <- cmd.Start()
+	}()
+	return <-errCh
+}
+
+func setns(nspath string, flag int) error {
+	f, err := os.Open(nspath)
+	if err != nil {
+		return errors.Wrapf(err, "fail to open namespace %s", nspath)
+	}
+	defer f.Close()
+	return errors.Wrapf(unix.Setns(int(f.Fd()), flag), "fail to setns %s", nspath)
+}
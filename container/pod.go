@@ -0,0 +1,410 @@
+package container
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kehaha-5/go-low-level-container/cgroups"
+	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/kehaha-5/go-low-level-container/events"
+	"github.com/kehaha-5/go-low-level-container/network"
+	"github.com/kehaha-5/go-low-level-container/store"
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netns"
+)
+
+const (
+	defaultPodSavename string = "config.json"
+	// pod的net namespace名称 容器名不能以_开头 不会和容器的冲突
+	podNetnsPrefix string = "_pod-"
+	// infra进程执行的命令
+	PodInfraCommand string = "pause"
+)
+
+var (
+	defaultPodSavefilepath string = common.ROOTPATH + "/pods/"
+)
+
+// 一组共享net ipc uts namespace的容器 namespace由infra进程持有
+// 网络和端口映射属于pod 容器通过 run --pod 加入
+type Pod struct {
+	Id          string            `json:"id"`
+	Name        string            `json:"name"`
+	CreateTime  string            `json:"createTime"`
+	Status      string            `json:"status"`
+	InfraPid    string            `json:"infraPid"` //infra进程在宿主机上的pid
+	Net         string            `json:"net"`      //pod连接的网络
+	PortMapping []string          `json:"portMapping"`
+	IpInfo      network.Endpoint  `json:"ipInfo"`
+	Labels      map[string]string `json:"labels"`
+}
+
+func getPodSavePath(name string) string {
+	return path.Join(defaultPodSavefilepath, name, defaultPodSavename)
+}
+
+func getPodLockName(name string) string {
+	return "pod-" + name
+}
+
+// pod成员的默认父资源组 整个pod的资源可以一起限制
+func PodCgroupParent(name string) string {
+	return path.Join(cgroups.DefaultCgroupParent+"-pods", name)
+}
+
+func (t *Pod) netnsName() string {
+	return podNetnsPrefix + t.Name
+}
+
+func (t *Pod) ShortId() string {
+	if len(t.Id) > shortIdLen {
+		return t.Id[:shortIdLen]
+	}
+	return t.Id
+}
+
+func (t *Pod) record() error {
+	return store.WriteJSON(getPodSavePath(t.Name), t)
+}
+
+func (t *Pod) emit(action string, attrs map[string]string) {
+	events.Emit(events.Pod, action, t.Id, t.Name, attrs)
+}
+
+// infra进程存在并且命令行是这个pod的pause 防止pid被其他进程复用
+func (t *Pod) isAlive() bool {
+	if t.Status != Running || t.InfraPid == "" {
+		return false
+	}
+	cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%s/cmdline", t.InfraPid))
+	if err != nil {
+		return false
+	}
+	args := strings.Split(strings.TrimSuffix(string(cmdline), "\x00"), "\x00")
+	return len(args) == 3 && args[1] == PodInfraCommand && args[2] == t.Name
+}
+
+func GetPod(name string) (*Pod, error) {
+	pod := &Pod{}
+	exist, err := store.ReadJSON(getPodSavePath(name), pod)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, fmt.Errorf("pod %s not exist", name)
+	}
+	return pod, nil
+}
+
+func ListPods() ([]*Pod, error) {
+	entries, err := os.ReadDir(defaultPodSavefilepath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	res := []*Pod{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		pod, err := GetPod(entry.Name())
+		if err != nil {
+			slog.Error("list pods", "name", entry.Name(), "err", err)
+			continue
+		}
+		res = append(res, pod)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].CreateTime < res[j].CreateTime })
+	return res, nil
+}
+
+// pod中的容器
+func (t *Pod) members() ([]ContainerInfos, error) {
+	infos, err := ListContainerInfos()
+	if err != nil {
+		return nil, err
+	}
+	res := []ContainerInfos{}
+	for _, info := range infos {
+		if info.Pod == t.Name {
+			res = append(res, info)
+		}
+	}
+	return res, nil
+}
+
+// 创建pod并启动infra进程
+func CreatePod(name string, net string, portMapping []string, labels []string) error {
+	if !validContainerName.MatchString(name) {
+		return fmt.Errorf("invalid pod name %s, only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", name)
+	}
+	if net == "" && len(portMapping) != 0 {
+		return fmt.Errorf("port mapping requires a network")
+	}
+	b := make([]byte, defaultIdLen)
+	if _, err := rand.Read(b); err != nil {
+		return errors.Wrap(err, "fail to generate pod id")
+	}
+	pod := &Pod{
+		Id:          hex.EncodeToString(b),
+		Name:        name,
+		CreateTime:  time.Now().Format(time.RFC3339),
+		Status:      Stop,
+		Net:         net,
+		PortMapping: portMapping,
+		Labels:      common.ParseLabels(labels),
+	}
+
+	return store.WithLock(getPodLockName(name), func() error {
+		if common.FileExist(getPodSavePath(name)) {
+			return fmt.Errorf("pod %s has existed", name)
+		}
+		if err := exec.Command("ip", "netns", "add", pod.netnsName()).Run(); err != nil {
+			return errors.Wrapf(err, "fail to add ip netns %s", pod.netnsName())
+		}
+		if err := pod.connect(); err != nil {
+			netns.DeleteNamed(pod.netnsName())
+			return err
+		}
+		if err := pod.startInfra(); err != nil {
+			pod.disconnect()
+			return err
+		}
+		if err := pod.record(); err != nil {
+			return err
+		}
+		pod.emit(events.ActionCreate, nil)
+		pod.emit(events.ActionStart, nil)
+		return nil
+	})
+}
+
+// pod的网络在创建时配置一次 net namespace一直保留到pod删除
+func (t *Pod) connect() error {
+	if t.Net == "" {
+		return network.SetUpLoopback(t.netnsName())
+	}
+	if err := network.Init(); err != nil {
+		return errors.WithStack(err)
+	}
+	ep, err := network.Connect(t.Net, t.Id, t.netnsName(), t.PortMapping)
+	if err != nil {
+		return errors.Wrap(err, "fail to connect net")
+	}
+	t.IpInfo = *ep
+	return nil
+}
+
+// 宿主机重启后net namespace会丢失 重新创建并配置网络
+func (t *Pod) ensureNetns() error {
+	if common.FileExist(path.Join(defaultNetnsPath, t.netnsName())) {
+		return nil
+	}
+	if err := exec.Command("ip", "netns", "add", t.netnsName()).Run(); err != nil {
+		return errors.Wrapf(err, "fail to add ip netns %s", t.netnsName())
+	}
+	if t.IpInfo.ID == "" {
+		return network.SetUpLoopback(t.netnsName())
+	}
+	if err := network.Init(); err != nil {
+		return errors.WithStack(err)
+	}
+	_, err := network.ReconcileEndpoint(&t.IpInfo, t.netnsName())
+	return err
+}
+
+func (t *Pod) disconnect() {
+	if t.IpInfo.ID != "" {
+		if err := network.DisConnect(&t.IpInfo); err != nil {
+			slog.Error("disconnect pod", "name", t.Name, "err", err)
+		}
+	}
+	if err := netns.DeleteNamed(t.netnsName()); err != nil {
+		slog.Error("delete pod netns", "name", t.Name, "err", err)
+	}
+}
+
+// infra进程新建ipc和uts namespace 并加入pod的net namespace
+func (t *Pod) startInfra() error {
+	cmd := exec.Command("/proc/self/exe", PodInfraCommand, t.Name)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid:     true,
+		Cloneflags: syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+	}
+	joins := map[string]string{"net": path.Join(defaultNetnsPath, t.netnsName())}
+	if err := startWithJoins(cmd, joins); err != nil {
+		return errors.Wrap(err, "fail to start pod infra")
+	}
+	t.InfraPid = strconv.Itoa(cmd.Process.Pid)
+	t.Status = Running
+	return cmd.Process.Release()
+}
+
+// 启动pod的infra进程和所有成员容器
+func StartPod(name string) error {
+	return store.WithLock(getPodLockName(name), func() error {
+		pod, err := GetPod(name)
+		if err != nil {
+			return err
+		}
+		if !pod.isAlive() {
+			if err := pod.ensureNetns(); err != nil {
+				return err
+			}
+			if pod.IpInfo.ID != "" {
+				if err := network.ConfigMapping(&pod.IpInfo); err != nil {
+					return errors.Wrap(err, "fail to config mapping")
+				}
+			}
+			if err := pod.startInfra(); err != nil {
+				return err
+			}
+			if err := pod.record(); err != nil {
+				return err
+			}
+			pod.emit(events.ActionStart, nil)
+		}
+		members, err := pod.members()
+		if err != nil {
+			return err
+		}
+		for _, info := range members {
+			// 按进程是否存在判断 不依赖记录的状态
+			if isContainerAlive(&info) {
+				continue
+			}
+			if err := StartContainerByName(info.Name); err != nil {
+				return errors.Wrapf(err, "fail to start container %s of pod %s", info.Name, pod.Name)
+			}
+		}
+		return nil
+	})
+}
+
+// 停止所有成员容器和infra进程
+func StopPod(name string) error {
+	return store.WithLock(getPodLockName(name), func() error {
+		pod, err := GetPod(name)
+		if err != nil {
+			return err
+		}
+		return pod.stop()
+	})
+}
+
+func (t *Pod) stop() error {
+	members, err := t.members()
+	if err != nil {
+		return err
+	}
+	for _, info := range members {
+		// 进程还在的成员必须先停止 否则会留在pod的namespace中
+		if info.Status != Running && !isContainerAlive(&info) {
+			continue
+		}
+		if err := StopContainerByName(info.Name); err != nil {
+			return errors.Wrapf(err, "fail to stop container %s of pod %s", info.Name, t.Name)
+		}
+	}
+	if t.isAlive() {
+		pid, _ := strconv.Atoi(t.InfraPid)
+		if err := syscall.Kill(pid, syscall.SIGTERM); err != nil && err != syscall.ESRCH {
+			return errors.Wrap(err, "fail to stop pod infra")
+		}
+	}
+	if t.IpInfo.ID != "" {
+		if err := network.DelIptRules(&t.IpInfo); err != nil {
+			slog.Error("stop pod", "del ipt rules", err)
+		}
+	}
+	t.Status = Stop
+	if err := t.record(); err != nil {
+		return err
+	}
+	t.emit(events.ActionStop, nil)
+	return nil
+}
+
+// 删除pod 还有成员容器或者在运行时需要force
+func RmPod(name string, force bool) error {
+	return store.WithLock(getPodLockName(name), func() error {
+		pod, err := GetPod(name)
+		if err != nil {
+			return err
+		}
+		members, err := pod.members()
+		if err != nil {
+			return err
+		}
+		if !force && (pod.isAlive() || len(members) != 0) {
+			return fmt.Errorf("pod %s is running or has containers, stop and remove them first or use -f", pod.Name)
+		}
+		if err := pod.stop(); err != nil {
+			return err
+		}
+		for _, info := range members {
+			if err := Rm(info.Name, true); err != nil {
+				return errors.Wrapf(err, "fail to remove container %s of pod %s", info.Name, pod.Name)
+			}
+		}
+		pod.disconnect()
+		if err := cgroups.RemoveGroup(PodCgroupParent(pod.Name)); err != nil {
+			slog.Error("remove pod cgroup", "name", pod.Name, "err", err)
+		}
+		if err := os.RemoveAll(path.Dir(getPodSavePath(pod.Name))); err != nil {
+			return errors.WithStack(err)
+		}
+		pod.emit(events.ActionDestroy, nil)
+		return nil
+	})
+}
+
+func WirtePodsToTabwriter(w *tabwriter.Writer) error {
+	pods, err := ListPods()
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(w, "ID\tNAME\tSTATUS\tCONTAINERS\tNETWORK\tIP\tCREATED\n")
+	for _, pod := range pods {
+		members, err := pod.members()
+		if err != nil {
+			return err
+		}
+		status := pod.Status
+		if status == Running && !pod.isAlive() {
+			status = Exit
+		}
+		ip := ""
+		if pod.IpInfo.ID != "" {
+			ip = pod.IpInfo.IPAddress.String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n", pod.ShortId(), pod.Name, status, len(members), pod.Net, ip, pod.CreateTime)
+	}
+	return nil
+}
+
+// infra进程 只负责持有namespace 收到退出信号前一直等待
+func RunPodInfra(name string) error {
+	if err := syscall.Sethostname([]byte(name)); err != nil {
+		return errors.Wrap(err, "fail to set pod hostname")
+	}
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	signal.Ignore(syscall.SIGHUP)
+	<-sigCh
+	return nil
+}
@@ -9,25 +9,16 @@ import (
 	"github.com/pkg/errors"
 )
 
+// 容器init进程执行的命令
+const InitCommand string = "init"
+
 // 初始化容器进程
-func initContainerParentWithNewWorkSpace(tty bool, volumeArg []string, containerName string, imageName string, envList []string) (*exec.Cmd, *os.File, *workSpace, error) {
+func initContainerParentWithNewWorkSpace(volumeArg []string, containerName string, imageName string, envList []string) (*exec.Cmd, *os.File, *workSpace, error) {
 	readPipe, writePipe, cmd, err := initContainerParent()
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	if tty {
-		cmd.Stdout = os.Stdout
-		cmd.Stdin = os.Stdin
-		cmd.Stderr = os.Stderr
-	} else {
-		lopfile, err := createlogfilePointer(containerName)
-		if err != nil {
-			return nil, nil, nil, errors.WithStack(err)
-		}
-		cmd.Stdout = lopfile
-		cmd.Stderr = lopfile
-	}
-
+	// 容器的输入输出在RunContainer中设置 交互式的使用伪终端 后台运行的由logger记录
 	workSpaceInfo, err := NewWorkSpace(imageName, containerName, volumeArg)
 	if err != nil {
 		return nil, nil, nil, errors.WithStack(err)
@@ -48,11 +39,11 @@ func newPipe() (r *os.File, w *os.File, err error) {
 func initContainerParent() (*os.File, *os.File, *exec.Cmd, error) {
 	readPipe, writePipe, err := newPipe()
 	if err != nil {
-		slog.Error("new pipe", err)
+		slog.Error("new pipe", "err", err)
 		return nil, nil, nil, err
 	}
 
-	cmd := exec.Command("/proc/self/exe", "init")
+	cmd := exec.Command("/proc/self/exe", InitCommand)
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		Cloneflags: syscall.CLONE_NEWUTS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNS |
 			syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC,
@@ -0,0 +1,723 @@
+package container
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kehaha-5/go-low-level-container/cgroups"
+	"github.com/kehaha-5/go-low-level-container/cgroups/limit"
+	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/kehaha-5/go-low-level-container/network"
+	"github.com/kehaha-5/go-low-level-container/store"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// 项目创建的容器和网络都带有这个标签 值为项目名称
+	ProjectLabel        string = "project"
+	projectServiceLabel string = "project.service"
+
+	dependsOnStarted string = "service_started"
+	dependsOnHealthy string = "service_healthy"
+
+	defaultHealthInterval time.Duration = 5 * time.Second
+	defaultHealthTimeout  time.Duration = 30 * time.Second
+	defaultHealthRetries  int           = 3
+
+	// 最后一次健康检查的结果 保存在容器的信息目录中
+	defaultHealthSavename string = "health.json"
+	healthStarting        string = "starting"
+	healthHealthy         string = "healthy"
+	healthUnhealthy       string = "unhealthy"
+)
+
+// 项目文件 yaml格式 json是yaml的子集也可以直接使用
+type Project struct {
+	Name     string                     `yaml:"name"`
+	Services map[string]*ProjectService `yaml:"services"`
+	Networks map[string]*ProjectNetwork `yaml:"networks"`
+}
+
+type ProjectService struct {
+	Image       string            `yaml:"image"`
+	Command     stringOrList      `yaml:"command"`
+	Environment mappingOrList     `yaml:"environment"`
+	Volumes     []string          `yaml:"volumes"`
+	Ports       []string          `yaml:"ports"`
+	Networks    []string          `yaml:"networks"` //容器只能连接一个网络
+	DependsOn   dependsOn         `yaml:"depends_on"`
+	Restart     string            `yaml:"restart"` //由daemon按重启策略重新启动
+	Labels      mappingOrList     `yaml:"labels"`
+	Healthcheck *Healthcheck      `yaml:"healthcheck"`
+	name        string            `yaml:"-"`
+	healthcheck *healthcheckRules `yaml:"-"`
+	restart     RestartPolicy     `yaml:"-"`
+}
+
+type ProjectNetwork struct {
+	Driver string `yaml:"driver"`
+	Subnet string `yaml:"subnet"`
+}
+
+// test可以是 ["CMD", "curl", "-f", "http://localhost"] ["CMD-SHELL", "curl -f http://localhost"] 或者字符串
+// 命令通过exec在容器中执行 退出码为0表示健康
+type Healthcheck struct {
+	Test     stringOrList `yaml:"test"`
+	Interval string       `yaml:"interval"`
+	Timeout  string       `yaml:"timeout"`
+	Retries  int          `yaml:"retries"`
+	Disable  bool         `yaml:"disable"`
+}
+
+type healthcheckRules struct {
+	cmd      string
+	interval time.Duration
+	timeout  time.Duration
+	retries  int
+}
+
+// 字符串或者字符串列表 字符串按空白分割
+type stringOrList []string
+
+func (t *stringOrList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*t = strings.Fields(value.Value)
+		return nil
+	}
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+// key=value列表或者map map转成按key排序的key=value列表
+type mappingOrList []string
+
+func (t *mappingOrList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		var list []string
+		if err := value.Decode(&list); err != nil {
+			return err
+		}
+		*t = list
+		return nil
+	}
+	m := map[string]string{}
+	if err := value.Decode(&m); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		*t = append(*t, k+"="+m[k])
+	}
+	return nil
+}
+
+// 依赖的服务到启动条件 列表形式的条件为service_started
+type dependsOn map[string]string
+
+func (t *dependsOn) UnmarshalYAML(value *yaml.Node) error {
+	*t = dependsOn{}
+	if value.Kind != yaml.MappingNode {
+		var list []string
+		if err := value.Decode(&list); err != nil {
+			return err
+		}
+		for _, item := range list {
+			(*t)[item] = dependsOnStarted
+		}
+		return nil
+	}
+	m := map[string]struct {
+		Condition string `yaml:"condition"`
+	}{}
+	if err := value.Decode(&m); err != nil {
+		return err
+	}
+	for name, item := range m {
+		if item.Condition == "" {
+			item.Condition = dependsOnStarted
+		}
+		(*t)[name] = item.Condition
+	}
+	return nil
+}
+
+// 读取并检查项目文件 name不为空时覆盖文件中的项目名称 都为空时使用文件所在目录名
+func LoadProject(file string, name string) (*Project, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to read project file %s", file)
+	}
+	project := &Project{}
+	if err := yaml.Unmarshal(data, project); err != nil {
+		return nil, errors.Wrapf(err, "fail to parse project file %s", file)
+	}
+	if name != "" {
+		project.Name = name
+	}
+	if project.Name == "" {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		project.Name = filepath.Base(filepath.Dir(abs))
+	}
+	if err := project.validate(); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+func (t *Project) validate() error {
+	if !validContainerName.MatchString(t.Name) {
+		return fmt.Errorf("invalid project name %s, only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", t.Name)
+	}
+	if len(t.Services) == 0 {
+		return fmt.Errorf("project %s has no services", t.Name)
+	}
+	for name, n := range t.Networks {
+		if n == nil {
+			return fmt.Errorf("network %s: missing subnet", name)
+		}
+		if n.Driver == "" {
+			n.Driver = "bridge"
+		}
+		if n.Subnet == "" {
+			return fmt.Errorf("network %s: missing subnet", name)
+		}
+	}
+	for name, svc := range t.Services {
+		if svc == nil {
+			return fmt.Errorf("service %s: missing image", name)
+		}
+		svc.name = name
+		if svc.Healthcheck != nil && !svc.Healthcheck.Disable {
+			rules, err := svc.Healthcheck.rules()
+			if err != nil {
+				return errors.Wrapf(err, "service %s", name)
+			}
+			svc.healthcheck = rules
+		}
+	}
+	for name, svc := range t.Services {
+		if err := t.validateService(svc); err != nil {
+			return errors.Wrapf(err, "service %s", name)
+		}
+	}
+	_, err := t.startOrder()
+	return err
+}
+
+func (t *Project) validateService(svc *ProjectService) error {
+	if !validContainerName.MatchString(svc.name) {
+		return fmt.Errorf("invalid service name, only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed")
+	}
+	if svc.Image == "" {
+		return fmt.Errorf("missing image")
+	}
+	if len(svc.Command) == 0 {
+		return fmt.Errorf("missing command")
+	}
+	if len(svc.Networks) > 1 {
+		return fmt.Errorf("a container can only connect to one network")
+	}
+	if len(svc.Ports) != 0 && len(svc.Networks) == 0 {
+		return fmt.Errorf("port mapping requires a network")
+	}
+	restart, err := ParseRestartPolicy(svc.Restart)
+	if err != nil {
+		return err
+	}
+	svc.restart = restart
+	for dep, condition := range svc.DependsOn {
+		depSvc, ok := t.Services[dep]
+		if !ok {
+			return fmt.Errorf("depends on undefined service %s", dep)
+		}
+		switch condition {
+		case dependsOnStarted:
+		case dependsOnHealthy:
+			if depSvc.healthcheck == nil {
+				return fmt.Errorf("depends on service %s being healthy but it has no healthcheck", dep)
+			}
+		default:
+			return fmt.Errorf("invalid depends_on condition %s", condition)
+		}
+	}
+	return nil
+}
+
+func (t *Healthcheck) rules() (*healthcheckRules, error) {
+	res := &healthcheckRules{
+		interval: defaultHealthInterval,
+		timeout:  defaultHealthTimeout,
+		retries:  defaultHealthRetries,
+	}
+	test := []string(t.Test)
+	if len(test) == 0 {
+		return nil, fmt.Errorf("missing healthcheck test")
+	}
+	switch test[0] {
+	case "CMD", "CMD-SHELL":
+		test = test[1:]
+	case "NONE":
+		return nil, nil
+	}
+	if len(test) == 0 {
+		return nil, fmt.Errorf("missing healthcheck command")
+	}
+	// nsenter通过system执行命令 CMD和CMD-SHELL都由容器中的sh解析
+	res.cmd = strings.Join(test, " ")
+	for _, item := range []struct {
+		value string
+		dst   *time.Duration
+	}{
+		{t.Interval, &res.interval},
+		{t.Timeout, &res.timeout},
+	} {
+		if item.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(item.value)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid healthcheck duration %s", item.value)
+		}
+		*item.dst = d
+	}
+	if t.Retries < 0 {
+		return nil, fmt.Errorf("invalid healthcheck retries %d", t.Retries)
+	}
+	if t.Retries > 0 {
+		res.retries = t.Retries
+	}
+	return res, nil
+}
+
+// 按依赖关系排序的服务 被依赖的在前 同一层按名称排序保证顺序稳定
+func (t *Project) startOrder() ([]*ProjectService, error) {
+	names := make([]string, 0, len(t.Services))
+	for name := range t.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	res := make([]*ProjectService, 0, len(names))
+	var visit func(name string, chain []string) error
+	visit = func(name string, chain []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("circular dependency between services %s", strings.Join(append(chain, name), " -> "))
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		deps := make([]string, 0, len(t.Services[name].DependsOn))
+		for dep := range t.Services[name].DependsOn {
+			deps = append(deps, dep)
+		}
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if err := visit(dep, append(chain, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		res = append(res, t.Services[name])
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (t *Project) containerName(service string) string {
+	return t.Name + "_" + service
+}
+
+// 项目中的容器 按服务名索引
+func (t *Project) containers() (map[string]ContainerInfos, error) {
+	infos, err := ListContainerInfos()
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]ContainerInfos{}
+	for _, info := range infos {
+		if info.Labels[ProjectLabel] == t.Name {
+			res[info.Labels[projectServiceLabel]] = info
+		}
+	}
+	return res, nil
+}
+
+// 按依赖顺序创建网络和容器 已经存在的容器没有运行时重新启动
+func ProjectUp(project *Project) error {
+	order, err := project.startOrder()
+	if err != nil {
+		return err
+	}
+	if err := network.Init(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := project.createNetworks(); err != nil {
+		return err
+	}
+	existing, err := project.containers()
+	if err != nil {
+		return err
+	}
+	checked := map[string]bool{}
+	for _, svc := range order {
+		if err := project.waitDependencies(svc, checked); err != nil {
+			return err
+		}
+		if info, ok := existing[svc.name]; ok {
+			if isContainerAlive(&info) {
+				continue
+			}
+			slog.Info("project up", "project", project.Name, "start", info.Name)
+			if err := StartContainerByName(info.Name); err != nil {
+				return errors.Wrapf(err, "fail to start service %s", svc.name)
+			}
+			continue
+		}
+		slog.Info("project up", "project", project.Name, "create", project.containerName(svc.name))
+		if err := project.runService(svc); err != nil {
+			return errors.Wrapf(err, "fail to create service %s", svc.name)
+		}
+	}
+	// 没有被依赖的服务也检查一次 project ps只显示记录的结果
+	for _, svc := range order {
+		if svc.healthcheck == nil || checked[svc.name] {
+			continue
+		}
+		info := ContainerInfos{}
+		if err := GetInfoByContainerName(project.containerName(svc.name), &info); err != nil {
+			return errors.Wrapf(err, "fail to find service %s", svc.name)
+		}
+		svc.healthcheck.checkAndRecord(&info)
+	}
+	return nil
+}
+
+func (t *Project) createNetworks() error {
+	names := make([]string, 0, len(t.Networks))
+	for name := range t.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if network.Exist(name) {
+			continue
+		}
+		n := t.Networks[name]
+		if err := network.CreateNetwork(n.Driver, n.Subnet, name, []string{ProjectLabel + "=" + t.Name}); err != nil {
+			return errors.Wrapf(err, "fail to create network %s", name)
+		}
+		// 重新加载网络 后面的容器才能连接到新建的网络
+		if err := network.Init(); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	for _, svc := range t.Services {
+		for _, name := range svc.Networks {
+			if !network.Exist(name) {
+				return fmt.Errorf("service %s: network %s not exist", svc.name, name)
+			}
+		}
+	}
+	return nil
+}
+
+func (t *Project) runService(svc *ProjectService) error {
+	logConfig, err := NewLogConfig("json-file", nil)
+	if err != nil {
+		return err
+	}
+	labels := append([]string{}, svc.Labels...)
+	labels = append(labels, ProjectLabel+"="+t.Name, projectServiceLabel+"="+svc.name)
+	args := &RunCommandArgs{
+		VolumeArg:     svc.Volumes,
+		LimitResConf:  &limit.ResourceConfig{},
+		CommandArgs:   svc.Command,
+		Detach:        true,
+		ContainerName: t.containerName(svc.name),
+		ImageName:     svc.Image,
+		EnvList:       svc.Environment,
+		PortMapping:   strings.Join(svc.Ports, " "),
+		Labels:        labels,
+		LogConfig:     logConfig,
+		CgroupParent:  cgroups.DefaultCgroupParent,
+		Restart:       svc.restart,
+	}
+	if len(svc.Networks) != 0 {
+		args.Net = svc.Networks[0]
+	}
+	_, err = RunContainer(args)
+	return err
+}
+
+// 依赖的服务需要健康时等待它的健康检查通过 检查过的服务记录在checked中
+func (t *Project) waitDependencies(svc *ProjectService, checked map[string]bool) error {
+	deps := make([]string, 0, len(svc.DependsOn))
+	for dep, condition := range svc.DependsOn {
+		if condition == dependsOnHealthy {
+			deps = append(deps, dep)
+		}
+	}
+	sort.Strings(deps)
+	for _, dep := range deps {
+		info := ContainerInfos{}
+		if err := GetInfoByContainerName(t.containerName(dep), &info); err != nil {
+			return errors.Wrapf(err, "fail to find service %s", dep)
+		}
+		rules := t.Services[dep].healthcheck
+		slog.Info("project up", "project", t.Name, "wait healthy", dep)
+		checked[dep] = true
+		for i := 0; ; i++ {
+			if err := rules.checkAndRecord(&info); err == nil {
+				break
+			} else if i+1 >= rules.retries {
+				return fmt.Errorf("service %s is unhealthy: %v", dep, err)
+			}
+			time.Sleep(rules.interval)
+		}
+	}
+	return nil
+}
+
+// 在容器中执行一次健康检查
+func (t *healthcheckRules) check(info *ContainerInfos) error {
+	if !isContainerAlive(info) {
+		return fmt.Errorf("container %s is not running", info.Name)
+	}
+	cmd, err := execCommand(info.Pid, []string{t.cmd})
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return errors.WithStack(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(t.timeout):
+		cmd.Process.Kill()
+		<-done
+		return fmt.Errorf("healthcheck timed out after %s", t.timeout)
+	}
+}
+
+type healthResult struct {
+	Status string `json:"status"`
+	// 检查时容器的pid 容器重启后之前的结果不再有效
+	Pid   string `json:"pid"`
+	Time  string `json:"time"`
+	Error string `json:"error,omitempty"`
+}
+
+func getHealthSavePath(containerName string) string {
+	return path.Join(defaultInfoSavefilepath, containerName, defaultHealthSavename)
+}
+
+// 执行一次健康检查并保存结果 保存失败只记录日志
+func (t *healthcheckRules) checkAndRecord(info *ContainerInfos) error {
+	err := t.check(info)
+	res := &healthResult{
+		Status: healthHealthy,
+		Pid:    info.Pid,
+		Time:   time.Now().Format(time.RFC3339),
+	}
+	if err != nil {
+		res.Status = healthUnhealthy
+		res.Error = err.Error()
+	}
+	if err := store.WriteJSON(getHealthSavePath(info.Name), res); err != nil {
+		slog.Error("record health", "name", info.Name, "err", err)
+	}
+	return err
+}
+
+// 最后一次记录的健康状态 还没有检查过或者容器重启过时为starting
+func lastHealthStatus(info *ContainerInfos) string {
+	res := &healthResult{}
+	exist, err := store.ReadJSON(getHealthSavePath(info.Name), res)
+	if err != nil || !exist || res.Pid != info.Pid {
+		return healthStarting
+	}
+	return res.Status
+}
+
+// 按依赖的逆序删除项目的容器 再删除项目创建且没有被使用的网络
+func ProjectDown(project *Project) error {
+	order, err := project.startOrder()
+	if err != nil {
+		return err
+	}
+	existing, err := project.containers()
+	if err != nil {
+		return err
+	}
+	// 项目文件中已经删除的服务的容器最先删除
+	names := []string{}
+	for service, info := range existing {
+		if _, ok := project.Services[service]; !ok {
+			names = append(names, info.Name)
+		}
+	}
+	sort.Strings(names)
+	for i := len(order) - 1; i >= 0; i-- {
+		if info, ok := existing[order[i].name]; ok {
+			names = append(names, info.Name)
+		}
+	}
+	for _, name := range names {
+		slog.Info("project down", "project", project.Name, "remove", name)
+		if err := Rm(name, true); err != nil {
+			return errors.Wrapf(err, "fail to remove container %s", name)
+		}
+	}
+
+	if err := network.Init(); err != nil {
+		return errors.WithStack(err)
+	}
+	used, err := GetUsedNetworks()
+	if err != nil {
+		return err
+	}
+	filter, err := common.ParseFilters([]string{"label=" + ProjectLabel + "=" + project.Name})
+	if err != nil {
+		return err
+	}
+	if _, err := network.PruneNetworks(filter, used); err != nil {
+		return errors.Wrap(err, "fail to remove project networks")
+	}
+	return nil
+}
+
+func WirteProjectToTabwriter(project *Project, w *tabwriter.Writer) error {
+	existing, err := project.containers()
+	if err != nil {
+		return err
+	}
+	services := make([]string, 0, len(existing))
+	for service := range existing {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	fmt.Fprint(w, "NAME\tSERVICE\tSTATUS\tHEALTH\tPORTS\n")
+	for _, service := range services {
+		info := existing[service]
+		health := ""
+		if svc, ok := project.Services[service]; ok && svc.healthcheck != nil && info.Status == Running {
+			health = lastHealthStatus(&info)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			info.Name,
+			service,
+			info.statusString(),
+			health,
+			strings.Join(info.PortMapping, ","),
+		)
+	}
+	return nil
+}
+
+// 输出项目中服务的日志 每行以服务名作为前缀 services为空时输出所有服务
+// follow时同时跟踪所有容器 直到它们都退出
+func WriteProjectLogs(project *Project, services []string, opts *LogsOptions, stdout io.Writer, stderr io.Writer) error {
+	existing, err := project.containers()
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		for service := range existing {
+			services = append(services, service)
+		}
+		sort.Strings(services)
+	}
+	width := 0
+	for _, service := range services {
+		if _, ok := existing[service]; !ok {
+			return fmt.Errorf("no container for service %s", service)
+		}
+		width = max(width, len(service))
+	}
+
+	mu := &sync.Mutex{}
+	wg := sync.WaitGroup{}
+	errs := make([]error, len(services))
+	for i, service := range services {
+		prefix := fmt.Sprintf("%-*s | ", width, service)
+		out := &prefixWriter{mu: mu, w: stdout, prefix: prefix}
+		errOut := &prefixWriter{mu: mu, w: stderr, prefix: prefix}
+		name := existing[service].Name
+		if !opts.Follow {
+			errs[i] = WriteContainerLogs(name, opts, out, errOut)
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = WriteContainerLogs(name, opts, out, errOut)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return errors.Wrapf(err, "fail to write logs of service %s", services[i])
+		}
+	}
+	return nil
+}
+
+// 给每一行加上前缀 多个容器的日志共用一个锁 避免行交错
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (t *prefixWriter) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	for {
+		i := strings.IndexByte(string(t.buf), '\n')
+		if i < 0 {
+			break
+		}
+		if _, err := fmt.Fprintf(t.w, "%s%s", t.prefix, t.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		t.buf = t.buf[i+1:]
+	}
+	return len(p), nil
+}
@@ -0,0 +1,192 @@
+package container
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 解析内联的项目文件并设置服务名称 不做完整的validate
+func parseTestProject(t *testing.T, content string) *Project {
+	t.Helper()
+	project := &Project{}
+	if err := yaml.Unmarshal([]byte(content), project); err != nil {
+		t.Fatalf("unmarshal project: %v", err)
+	}
+	for name, svc := range project.Services {
+		svc.name = name
+	}
+	return project
+}
+
+func TestStartOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "no dependencies sorted by name",
+			content: `
+services:
+  web: {image: app}
+  db: {image: app}
+  cache: {image: app}
+`,
+			want: []string{"cache", "db", "web"},
+		},
+		{
+			name: "dependencies first",
+			content: `
+services:
+  web:
+    depends_on: [api]
+  api:
+    depends_on:
+      db: {condition: service_healthy}
+      cache: {}
+  db: {}
+  cache: {}
+`,
+			want: []string{"cache", "db", "api", "web"},
+		},
+		{
+			name: "shared dependency only once",
+			content: `
+services:
+  a: {depends_on: [c]}
+  b: {depends_on: [c]}
+  c: {}
+`,
+			want: []string{"c", "a", "b"},
+		},
+		{
+			name: "cycle",
+			content: `
+services:
+  a: {depends_on: [b]}
+  b: {depends_on: [c]}
+  c: {depends_on: [a]}
+`,
+			wantErr: "a -> b -> c -> a",
+		},
+		{
+			name: "self dependency",
+			content: `
+services:
+  a: {depends_on: [a]}
+`,
+			wantErr: "a -> a",
+		},
+	}
+	for _, tt := range tests {
+		project := parseTestProject(t, tt.content)
+		// 多次计算结果要一致 不受map遍历顺序影响
+		for i := 0; i < 5; i++ {
+			order, err := project.startOrder()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("%s: error = %v, want containing %q", tt.name, err, tt.wantErr)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.name, err)
+				continue
+			}
+			got := []string{}
+			for _, svc := range order {
+				got = append(got, svc.name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: order = %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestDependsOnUnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		content string
+		want    dependsOn
+		wantErr bool
+	}{
+		{content: `[db, cache]`, want: dependsOn{"db": dependsOnStarted, "cache": dependsOnStarted}},
+		{content: `[]`, want: dependsOn{}},
+		{content: `{db: {condition: service_healthy}, cache: {}}`, want: dependsOn{"db": dependsOnHealthy, "cache": dependsOnStarted}},
+		{content: `{db: {condition: service_started}}`, want: dependsOn{"db": dependsOnStarted}},
+		{content: `db`, wantErr: true},
+		{content: `{db: [a]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var got dependsOn
+		err := yaml.Unmarshal([]byte(tt.content), &got)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error, got %v", tt.content, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, %v, want %v", tt.content, got, err, tt.want)
+		}
+	}
+}
+
+func TestStringOrListUnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		content string
+		want    stringOrList
+		wantErr bool
+	}{
+		{content: `/bin/app 60s`, want: stringOrList{"/bin/app", "60s"}},
+		{content: `"  sh   -c  "`, want: stringOrList{"sh", "-c"}},
+		{content: `["sh", "-c", "echo a b"]`, want: stringOrList{"sh", "-c", "echo a b"}},
+		{content: `[]`, want: stringOrList{}},
+		{content: `{a: b}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var got stringOrList
+		err := yaml.Unmarshal([]byte(tt.content), &got)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error, got %v", tt.content, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, %v, want %q", tt.content, got, err, tt.want)
+		}
+	}
+}
+
+func TestMappingOrListUnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		content string
+		want    mappingOrList
+		wantErr bool
+	}{
+		{content: `[A=1, B=2]`, want: mappingOrList{"A=1", "B=2"}},
+		// map按key排序
+		{content: `{b: 2, a: 1, c: ""}`, want: mappingOrList{"a=1", "b=2", "c="}},
+		{content: `{PORT: 8080, DEBUG: true}`, want: mappingOrList{"DEBUG=true", "PORT=8080"}},
+		{content: `A=1`, wantErr: true},
+		{content: `{a: [1]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var got mappingOrList
+		err := yaml.Unmarshal([]byte(tt.content), &got)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error, got %v", tt.content, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, %v, want %q", tt.content, got, err, tt.want)
+		}
+	}
+}
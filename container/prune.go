@@ -0,0 +1,253 @@
+package container
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/kehaha-5/go-low-level-container/events"
+	"github.com/kehaha-5/go-low-level-container/store"
+	"github.com/pkg/errors"
+)
+
+// 残留目录在这段时间内有变动时不删除 防止删掉并发的run刚创建 还没写入容器信息的目录
+const pruneGracePeriod = 10 * time.Minute
+
+// prune命令的执行结果
+type PruneReport struct {
+	Deleted        []string
+	SpaceReclaimed int64
+}
+
+func (t *PruneReport) WirteReport(w io.Writer, title string) {
+	t.WirteDeleted(w, title)
+	fmt.Fprintf(w, "Total reclaimed space: %s\n", common.SizeHumanReadable(t.SpaceReclaimed))
+}
+
+func (t *PruneReport) WirteDeleted(w io.Writer, title string) {
+	if len(t.Deleted) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Deleted %s:\n", title)
+	for _, item := range t.Deleted {
+		fmt.Fprintln(w, item)
+	}
+	fmt.Fprintln(w)
+}
+
+// 删除所有已停止的容器 以及没有容器信息的残留容器目录(写层 日志)
+func PruneContainers(filter *common.Filter) (*PruneReport, error) {
+	infos, err := ListContainerInfos()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	report := &PruneReport{}
+	existed := map[string]bool{}
+	for _, info := range infos {
+		existed[info.Name] = true
+		if info.Status == Running {
+			continue
+		}
+		if !filter.MatchTime(info.CreateTime) || !filter.MatchLabels(info.Labels) {
+			continue
+		}
+		size, _ := common.DirSize(path.Join(root, defaultRoot, info.Name))
+		if err := Rm(info.Name, false); err != nil {
+			slog.Error("prune container", "name", info.Name, "err", err)
+			continue
+		}
+		report.Deleted = append(report.Deleted, info.Id)
+		report.SpaceReclaimed += size
+	}
+
+	dirs, err := os.ReadDir(path.Join(root, defaultRoot))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.WithStack(err)
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() || existed[dir.Name()] {
+			continue
+		}
+		// 正在创建中的容器已经占用了名称
+		if reserved, _ := common.PathExist(path.Join(defaultInfoSavefilepath, dir.Name())); reserved {
+			continue
+		}
+		if !matchOrphanedDir(filter, path.Join(root, defaultRoot, dir.Name())) {
+			continue
+		}
+		size, err := removeOrphanedDir(path.Join(root, defaultRoot, dir.Name()))
+		if err != nil {
+			slog.Error("prune orphaned container dir", "name", dir.Name(), "err", err)
+			continue
+		}
+		report.Deleted = append(report.Deleted, dir.Name())
+		report.SpaceReclaimed += size
+	}
+	return report, nil
+}
+
+// 删除没有被任何容器使用的镜像解压层 all为true时连同镜像文件一起删除
+func PruneImages(filter *common.Filter, all bool) (*PruneReport, error) {
+	infos, err := ListContainerInfos()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	used := map[string]bool{}
+	for _, info := range infos {
+		if info.Image == "" {
+			// 旧版本创建的容器没有记录镜像 无法判断解压层是否在使用 不能静默地什么都不删
+			return nil, fmt.Errorf("image of container %s is unknown, remove the container before pruning images", info.Name)
+		}
+		used[info.Image] = true
+	}
+
+	report := &PruneReport{}
+	lock, err := store.Lock(imagesLockName)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+	var images imageInfos
+	if err := images.load(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if all {
+		deleted := []ImageInfo{}
+		for name, item := range images.Infos {
+			imageName := strings.TrimSuffix(name, ".tar")
+			if used[imageName] || !filter.MatchTime(item.CreateTime) || !filter.MatchLabels(nil) {
+				continue
+			}
+			imageFile := path.Join(saveImagePaths, name)
+			if fInfo, err := os.Stat(imageFile); err == nil {
+				report.SpaceReclaimed += fInfo.Size()
+			}
+			if err := os.Remove(imageFile); err != nil && !os.IsNotExist(err) {
+				slog.Error("prune image", "name", name, "err", err)
+				continue
+			}
+			delete(images.Infos, name)
+			report.Deleted = append(report.Deleted, name)
+			deleted = append(deleted, item)
+		}
+		if err := images.dump(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, item := range deleted {
+			events.Emit(events.Image, events.ActionDelete, item.ID, item.Name, nil)
+		}
+	}
+
+	dirs, err := os.ReadDir(path.Join(root, defaultReadonlyLayer))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.WithStack(err)
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() || used[dir.Name()] {
+			continue
+		}
+		if !matchOrphanedDir(filter, path.Join(root, defaultReadonlyLayer, dir.Name())) {
+			continue
+		}
+		size, err := removeOrphanedDir(path.Join(root, defaultReadonlyLayer, dir.Name()))
+		if err != nil {
+			slog.Error("prune image layer", "name", dir.Name(), "err", err)
+			continue
+		}
+		report.Deleted = append(report.Deleted, path.Join(defaultReadonlyLayer, dir.Name()))
+		report.SpaceReclaimed += size
+	}
+	return report, nil
+}
+
+// 删除没有被任何容器使用的命名卷
+func PruneVolumes(filter *common.Filter) (*PruneReport, error) {
+	used, err := getUsedVolumes()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	report := &PruneReport{}
+	dirs, err := os.ReadDir(path.Join(root, defaultVolumesPath))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.WithStack(err)
+	}
+	for _, dir := range dirs {
+		volumePath := path.Join(root, defaultVolumesPath, dir.Name())
+		if !dir.IsDir() || used[volumePath] || !matchOrphanedDir(filter, volumePath) {
+			continue
+		}
+		size, err := removeOrphanedDir(volumePath)
+		if err != nil {
+			slog.Error("prune volume", "name", dir.Name(), "err", err)
+			continue
+		}
+		report.Deleted = append(report.Deleted, dir.Name())
+		report.SpaceReclaimed += size
+	}
+	return report, nil
+}
+
+// 获取正在被容器使用的网络名称
+func GetUsedNetworks() (map[string]bool, error) {
+	infos, err := ListContainerInfos()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	used := map[string]bool{}
+	for _, info := range infos {
+		if info.IpInfo.Network != nil {
+			used[info.IpInfo.Network.Name] = true
+		}
+	}
+	return used, nil
+}
+
+func getUsedVolumes() (map[string]bool, error) {
+	infos, err := ListContainerInfos()
+	if err != nil {
+		return nil, err
+	}
+	used := map[string]bool{}
+	for _, info := range infos {
+		for _, item := range volumeUrlExtract(info.Volume) {
+			used[item[0]] = true
+		}
+	}
+	return used, nil
+}
+
+// 残留目录没有记录创建时间和标签 用目录的ctime代替创建时间 标签按空标签匹配
+// 在宽限期内有变动的目录不匹配
+func matchOrphanedDir(filter *common.Filter, dir string) bool {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(dir, &stat); err != nil {
+		return false
+	}
+	changed := time.Unix(stat.Ctim.Unix())
+	if time.Since(changed) < pruneGracePeriod {
+		return false
+	}
+	return filter.MatchTime(changed.Format(time.RFC3339)) && filter.MatchLabels(nil)
+}
+
+// 删除残留的目录 删除前先卸载目录下的挂载点 防止删除到挂载进来的宿主机文件
+func removeOrphanedDir(dir string) (int64, error) {
+	mountPoints, err := common.MountPointsUnder(dir)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	for _, item := range mountPoints {
+		if err := exec.Command("umount", item).Run(); err != nil {
+			return 0, errors.Wrapf(err, "fail to umount %s", item)
+		}
+	}
+	size, _ := common.DirSize(dir)
+	return size, errors.WithStack(os.RemoveAll(dir))
+}
@@ -0,0 +1,23 @@
+package container
+
+import (
+	"path"
+	"testing"
+
+	"github.com/kehaha-5/go-low-level-container/common"
+)
+
+func TestMatchOrphanedDir(t *testing.T) {
+	dir := t.TempDir()
+
+	// 刚创建的目录还在宽限期内 不管过滤条件都不删除
+	if matchOrphanedDir(&common.Filter{}, dir) {
+		t.Errorf("dir in grace period matched")
+	}
+	if matchOrphanedDir(&common.Filter{NotLabels: []common.LabelFilter{{Key: "keep"}}}, dir) {
+		t.Errorf("dir in grace period matched with label!= filter")
+	}
+	if matchOrphanedDir(&common.Filter{}, path.Join(dir, "missing")) {
+		t.Errorf("missing dir matched")
+	}
+}
@@ -0,0 +1,313 @@
+package container
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/kehaha-5/go-low-level-container/network"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+const defaultNetnsPath = "/var/run/netns"
+
+// 修复过程中执行的操作
+type ReconcileReport struct {
+	Actions []string
+}
+
+func (t *ReconcileReport) add(format string, a ...any) {
+	action := fmt.Sprintf(format, a...)
+	slog.Info("reconcile", "action", action)
+	t.Actions = append(t.Actions, action)
+}
+
+// 把记录为运行中但是进程已经不存在的容器改为退出状态 在第一个修改状态的操作前调用
+func ReconcileStatus() (*ReconcileReport, error) {
+	report := &ReconcileReport{}
+	infos, err := ListContainerInfos()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for i := range infos {
+		info := &infos[i]
+		if info.Status != Running || isContainerAlive(info) {
+			continue
+		}
+		// 加锁后重新检查 其他进程可能已经重新启动了这个容器
+		marked := false
+		if _, err := updateContainerInfo(info.Name, func(latest *ContainerInfos) error {
+			if latest.Status != Running || isContainerAlive(latest) {
+				return nil
+			}
+			// 进程不是当前程序回收的 拿不到退出码
+			latest.emitDie(-1)
+			latest.Status = Exit
+			marked = true
+			return nil
+		}); err != nil {
+			return report, errors.WithStack(err)
+		}
+		if !marked {
+			continue
+		}
+		report.add("container %s: process %s is dead, mark as %s", info.Name, info.Pid, Exit)
+	}
+	if err := CheckPidsLimits(); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// 宿主机重启或者程序崩溃后 检查并修复容器的状态 net namespace veth overlay挂载 cgroup
+// 并清理不属于任何容器的内核对象
+func Reconcile() (*ReconcileReport, error) {
+	report, err := ReconcileStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := network.Init(); err != nil {
+		return report, errors.WithStack(err)
+	}
+	actions, err := network.ReconcileNetworks()
+	report.Actions = append(report.Actions, actions...)
+	if err != nil {
+		return report, errors.WithStack(err)
+	}
+
+	infos, err := ListContainerInfos()
+	if err != nil {
+		return report, errors.WithStack(err)
+	}
+	runningEps := []*network.Endpoint{}
+	eps := []*network.Endpoint{}
+	netnsNames := map[string]bool{}
+	for i := range infos {
+		info := &infos[i]
+		// 还没有启动过的容器没有需要修复的内核对象
+		if info.Status == Created {
+			continue
+		}
+		running := info.Status == Running
+		if info.Namespaces.OwnNetns() {
+			netnsNames[info.Name] = true
+		}
+
+		if err := reconcileMount(info, report); err != nil {
+			slog.Error("reconcile mount", "name", info.Name, "err", err)
+		}
+		if err := reconcileNetns(info, report); err != nil {
+			slog.Error("reconcile netns", "name", info.Name, "err", err)
+			continue
+		}
+
+		if info.IpInfo.ID != "" {
+			ep := &info.IpInfo
+			eps = append(eps, ep)
+			actions, err := network.ReconcileEndpoint(ep, info.Name)
+			report.Actions = append(report.Actions, actions...)
+			if err != nil {
+				slog.Error("reconcile endpoint", "name", info.Name, "err", err)
+			} else if len(actions) != 0 {
+				// veth重新创建后设备信息有变化
+				if _, err := updateContainerInfo(info.Name, func(latest *ContainerInfos) error {
+					latest.IpInfo = info.IpInfo
+					return nil
+				}); err != nil {
+					return report, errors.WithStack(err)
+				}
+			}
+			if running {
+				runningEps = append(runningEps, ep)
+				actions, err := network.EnsureIptRules(ep)
+				report.Actions = append(report.Actions, actions...)
+				if err != nil {
+					slog.Error("reconcile ipt rules", "name", info.Name, "err", err)
+				}
+			}
+		}
+
+		if running {
+			if err := reconcileCgroup(info, report); err != nil {
+				slog.Error("reconcile cgroup", "name", info.Name, "err", err)
+			}
+		}
+	}
+
+	// pod的net namespace和网络由pod持有
+	pods, err := ListPods()
+	if err != nil {
+		return report, errors.WithStack(err)
+	}
+	for _, pod := range pods {
+		netnsNames[pod.netnsName()] = true
+		if pod.Status == Running && !pod.isAlive() {
+			pod.Status = Exit
+			if err := pod.record(); err != nil {
+				return report, errors.WithStack(err)
+			}
+			report.add("pod %s: infra is not running, mark as %s", pod.Name, Exit)
+		}
+		if pod.IpInfo.ID == "" {
+			continue
+		}
+		eps = append(eps, &pod.IpInfo)
+		if pod.isAlive() {
+			runningEps = append(runningEps, &pod.IpInfo)
+			actions, err := network.EnsureIptRules(&pod.IpInfo)
+			report.Actions = append(report.Actions, actions...)
+			if err != nil {
+				slog.Error("reconcile ipt rules", "pod", pod.Name, "err", err)
+			}
+		}
+	}
+
+	actions, err = network.CleanOrphans(runningEps, eps, netnsNames)
+	report.Actions = append(report.Actions, actions...)
+	return report, errors.WithStack(err)
+}
+
+// 进程存在并且启动时间和记录的一致才认为容器还在运行 防止pid被其他进程复用
+// 容器在自己的mount namespace中pivot_root 从宿主机上看不到容器的根目录 不能用来判断
+func isContainerAlive(info *ContainerInfos) bool {
+	pid, err := strconv.Atoi(info.Pid)
+	if err != nil || pid <= 0 {
+		return false
+	}
+	startTime, state, err := readProcStat(pid)
+	if err != nil || state == 'Z' || state == 'X' {
+		return false
+	}
+	if info.StartTime != 0 {
+		return startTime == info.StartTime
+	}
+	// 旧版本没有记录启动时间 只能要求进程不在宿主机的mount namespace中
+	hostNs, err := os.Readlink("/proc/self/ns/mnt")
+	if err != nil {
+		return false
+	}
+	ns, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/mnt", pid))
+	return err == nil && ns != hostNs
+}
+
+// 读取/proc/<pid>/stat中进程的状态和启动时间(第3和第22个字段)
+// 进程名可能包含空格和括号 从最后一个右括号之后开始解析
+func readProcStat(pid int) (startTime uint64, state byte, err error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+	idx := strings.LastIndexByte(string(data), ')')
+	if idx < 0 {
+		return 0, 0, fmt.Errorf("invalid stat of pid %d", pid)
+	}
+	fields := strings.Fields(string(data[idx+1:]))
+	if len(fields) < 20 {
+		return 0, 0, fmt.Errorf("invalid stat of pid %d", pid)
+	}
+	startTime, err = strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return 0, 0, errors.WithStack(err)
+	}
+	return startTime, fields[0][0], nil
+}
+
+// 宿主机重启后overlay挂载会丢失 需要重新挂载才能start
+func reconcileMount(info *ContainerInfos, report *ReconcileReport) error {
+	mountRoot := getMountRootPathByContainerName(info.Name)
+	mountPoints, err := common.MountPointsUnder(mountRoot)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if slices.Contains(mountPoints, mountRoot) {
+		return nil
+	}
+	if info.Image == "" {
+		return fmt.Errorf("overlay is not mounted and image of container is unknown")
+	}
+	workSpaceInfo := getWorkSpackInfoByContainerInfos(info)
+	if exist, _ := common.PathExist(workSpaceInfo.readonlyLayer); !exist {
+		if err := workSpaceInfo.createReadOnlyLayer(root, info.Image); err != nil {
+			return err
+		}
+	}
+	if err := createLayer(workSpaceInfo.wirteLayer); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := createLayer(workSpaceInfo.workLayer); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := workSpaceInfo.createOverlay(); err != nil {
+		return err
+	}
+	if len(workSpaceInfo.volumeRoot) != 0 {
+		if err := workSpaceInfo.mountVolume(); err != nil {
+			return err
+		}
+	}
+	report.add("container %s: remount overlay %s", info.Name, mountRoot)
+	return nil
+}
+
+// 运行中的容器从进程重新绑定net namespace 其他容器重新创建
+func reconcileNetns(info *ContainerInfos, report *ReconcileReport) error {
+	netnsFile := path.Join(defaultNetnsPath, info.Name)
+	if !info.Namespaces.OwnNetns() || common.FileExist(netnsFile) {
+		return nil
+	}
+	if info.Status != Running {
+		if err := exec.Command("ip", "netns", "add", info.Name).Run(); err != nil {
+			return errors.Wrapf(err, "fail to add ip netns %s", info.Name)
+		}
+		report.add("container %s: recreate netns", info.Name)
+		return nil
+	}
+
+	if err := os.MkdirAll(defaultNetnsPath, 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	f, err := os.Create(netnsFile)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	f.Close()
+	if err := unix.Mount(fmt.Sprintf("/proc/%s/ns/net", info.Pid), netnsFile, "bind", unix.MS_BIND, ""); err != nil {
+		return errors.Wrap(err, "fail to bind netns")
+	}
+	report.add("container %s: rebind netns from pid %s", info.Name, info.Pid)
+	return nil
+}
+
+// 运行中的容器进程不在自己的cgroup中时 重新写入限制并加入
+func reconcileCgroup(info *ContainerInfos, report *ReconcileReport) error {
+	if info.Cg.Path == "" || info.Cg.Resource == nil {
+		return nil
+	}
+	content, err := os.ReadFile(fmt.Sprintf("/proc/%s/cgroup", info.Pid))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if strings.Contains(string(content), "/"+info.Cg.Path) {
+		return nil
+	}
+	pid, err := strconv.Atoi(info.Pid)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := info.Cg.Set(info.Cg.Resource); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := info.Cg.Apply(pid); err != nil {
+		return errors.WithStack(err)
+	}
+	report.add("container %s: move pid %s back to cgroup %s", info.Name, info.Pid, info.Cg.Path)
+	return nil
+}
@@ -0,0 +1,251 @@
+package container
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	defaultSessionDir string = "sessions"
+	castSuffix        string = ".cast"
+	castVersion       int    = 2
+
+	castEventOutput string = "o"
+	castEventResize string = "r"
+
+	defaultTermWidth  uint16 = 80
+	defaultTermHeight uint16 = 24
+
+	RecordKindRun  string = "run"
+	RecordKindExec string = "exec"
+)
+
+// asciicast v2 的文件头
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     uint16            `json:"width"`
+	Height    uint16            `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Command   string            `json:"command,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+func getSessionDirPath(containerName string) string {
+	return path.Join(defaultLogSavefilepath, containerName, defaultSessionDir)
+}
+
+func getSessionFilePath(containerName string, session string) string {
+	return path.Join(getSessionDirPath(containerName), session+castSuffix)
+}
+
+// 把终端的输出按时间记录为asciicast v2格式 每一行是一个事件 [时间 类型 数据]
+type castRecorder struct {
+	next    io.Writer
+	file    *os.File
+	w       *bufio.Writer
+	start   time.Time
+	pending []byte // 被截断的utf8字符 等下次写入时拼接
+	mu      sync.Mutex
+}
+
+// 会话名为 时间-run 或 时间-exec
+func newCastRecorder(containerName string, kind string, command []string, next io.Writer) (*castRecorder, error) {
+	if err := os.MkdirAll(getSessionDirPath(containerName), 0700); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	now := time.Now()
+	session := fmt.Sprintf("%s-%s", now.Format("20060102-150405.000"), kind)
+	file, err := os.OpenFile(getSessionFilePath(containerName, session), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to create session record")
+	}
+
+	width, height := defaultTermWidth, defaultTermHeight
+	if ws, err := unix.IoctlGetWinsize(int(os.Stdin.Fd()), unix.TIOCGWINSZ); err == nil && ws.Col != 0 {
+		width, height = ws.Col, ws.Row
+	}
+	header := &castHeader{
+		Version:   castVersion,
+		Width:     width,
+		Height:    height,
+		Timestamp: now.Unix(),
+		Command:   strings.Join(command, " "),
+		Title:     fmt.Sprintf("%s %s", kind, containerName),
+		Env:       map[string]string{"TERM": os.Getenv("TERM"), "SHELL": os.Getenv("SHELL")},
+	}
+	r := &castRecorder{next: next, file: file, w: bufio.NewWriter(file), start: now}
+	if err := r.writeLine(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (t *castRecorder) writeLine(v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	line = append(line, '\n')
+	_, err = t.w.Write(line)
+	return errors.WithStack(err)
+}
+
+func (t *castRecorder) event(kind string, data string) error {
+	return t.writeLine([]any{time.Since(t.start).Seconds(), kind, data})
+}
+
+// 记录失败不影响终端的显示
+func (t *castRecorder) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	data := append(t.pending, p...)
+	cut := incompleteUtf8Suffix(data)
+	t.pending = append([]byte{}, data[len(data)-cut:]...)
+	if len(data) > cut {
+		t.event(castEventOutput, string(data[:len(data)-cut]))
+	}
+	t.mu.Unlock()
+	return t.next.Write(p)
+}
+
+// 终端窗口大小变化
+func (t *castRecorder) Resize(ws *unix.Winsize) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.event(castEventResize, fmt.Sprintf("%dx%d", ws.Col, ws.Row))
+}
+
+func (t *castRecorder) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pending) != 0 {
+		t.event(castEventOutput, string(t.pending))
+		t.pending = nil
+	}
+	if err := t.w.Flush(); err != nil {
+		t.file.Close()
+		return errors.WithStack(err)
+	}
+	return t.file.Close()
+}
+
+// 末尾不完整的utf8字符的长度
+func incompleteUtf8Suffix(data []byte) int {
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		c := data[len(data)-i]
+		if utf8.RuneStart(c) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				return i
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// 容器的会话记录 按时间从旧到新
+func ListSessions(containerRef string) ([]string, error) {
+	info := ContainerInfos{}
+	if err := GetInfoByContainerRef(containerRef, &info); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(getSessionDirPath(info.Name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	res := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), castSuffix) {
+			res = append(res, strings.TrimSuffix(entry.Name(), castSuffix))
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// 按记录的时间回放会话 speed为倍速 idleLimit大于0时限制最长的停顿
+// session为空时回放最新的一个
+func Replay(containerRef string, session string, speed float64, idleLimit time.Duration, w io.Writer) error {
+	if speed <= 0 {
+		return fmt.Errorf("invalid speed %v", speed)
+	}
+	info := ContainerInfos{}
+	if err := GetInfoByContainerRef(containerRef, &info); err != nil {
+		return err
+	}
+	if session == "" {
+		sessions, err := ListSessions(info.Name)
+		if err != nil {
+			return err
+		}
+		if len(sessions) == 0 {
+			return fmt.Errorf("container %s has no recorded session", info.Name)
+		}
+		session = sessions[len(sessions)-1]
+	}
+	castfile := getSessionFilePath(info.Name, session)
+	if !common.FileExist(castfile) {
+		return fmt.Errorf("session %s not found in container %s", session, info.Name)
+	}
+	f, err := os.Open(castfile)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	headerLine, err := reader.ReadBytes('\n')
+	if err != nil {
+		return errors.Wrap(err, "fail to read cast header")
+	}
+	header := &castHeader{}
+	if err := json.Unmarshal(headerLine, header); err != nil || header.Version != castVersion {
+		return fmt.Errorf("invalid asciicast v2 file %s", castfile)
+	}
+
+	var last float64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) != 0 {
+			var event []any
+			if jerr := json.Unmarshal(line, &event); jerr != nil || len(event) != 3 {
+				return fmt.Errorf("invalid cast event %s", strings.TrimSpace(string(line)))
+			}
+			at, _ := event[0].(float64)
+			kind, _ := event[1].(string)
+			data, _ := event[2].(string)
+			delay := time.Duration((at - last) / speed * float64(time.Second))
+			if idleLimit > 0 && delay > idleLimit {
+				delay = idleLimit
+			}
+			time.Sleep(delay)
+			last = at
+			if kind == castEventOutput {
+				fmt.Fprint(w, data)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var validContainerName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// 容器名称就是容器信息的目录名 用Mkdir占用名称 同时创建时只有一个能成功
+func reserveContainerName(name string) error {
+	if err := os.MkdirAll(defaultInfoSavefilepath, 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.Mkdir(path.Join(defaultInfoSavefilepath, name), 0755); err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("the container name %s is already in use", name)
+		}
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// 根据完整id 名称 或者唯一的id前缀找到容器的名称
+// 匹配的优先级和docker一致 完整id > 名称 > id前缀
+func ResolveContainerName(ref string) (string, error) {
+	if ref == "" {
+		return "", fmt.Errorf("specify container name or id")
+	}
+	infos, err := ListContainerInfos()
+	if err != nil {
+		return "", err
+	}
+	for _, info := range infos {
+		if info.Id == ref {
+			return info.Name, nil
+		}
+	}
+	for _, info := range infos {
+		if info.Name == ref {
+			return info.Name, nil
+		}
+	}
+	matched := []string{}
+	for _, info := range infos {
+		if strings.HasPrefix(info.Id, ref) {
+			matched = append(matched, info.Name)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return "", fmt.Errorf("no such container: %s", ref)
+	case 1:
+		return matched[0], nil
+	default:
+		return "", fmt.Errorf("multiple containers found with provided prefix: %s", ref)
+	}
+}
+
+// 通过容器名称 id 或id前缀获取容器信息
+func GetInfoByContainerRef(ref string, data *ContainerInfos) error {
+	name, err := ResolveContainerName(ref)
+	if err != nil {
+		return err
+	}
+	return GetInfoByContainerName(name, data)
+}
@@ -1,12 +1,13 @@
 package container
 
 import (
+	"github.com/kehaha-5/go-low-level-container/events"
 	"github.com/pkg/errors"
 )
 
 func RestartContainer(name string) error {
 	info := ContainerInfos{}
-	if err := GetInfoByContainerName(name, &info); err != nil {
+	if err := GetInfoByContainerRef(name, &info); err != nil {
 		return errors.Wrap(err, "fail to find the container info")
 	}
 
@@ -14,6 +15,9 @@ func RestartContainer(name string) error {
 		return errors.Wrap(err, "fail to stop container")
 	}
 
-
-	return StartContainerByName(name)
+	if err := StartContainerByName(name); err != nil {
+		return err
+	}
+	info.emit(events.ActionRestart, nil)
+	return nil
 }
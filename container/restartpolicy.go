@@ -0,0 +1,148 @@
+package container
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/kehaha-5/go-low-level-container/events"
+	"github.com/pkg/errors"
+)
+
+const (
+	RestartNo            string = "no"
+	RestartAlways        string = "always"
+	RestartOnFailure     string = "on-failure"
+	RestartUnlessStopped string = "unless-stopped"
+)
+
+// 容器退出后的重启策略 由daemon按策略重新启动容器
+// stop命令停止的容器不会被重启
+type RestartPolicy struct {
+	Name              string `json:"name"`
+	MaximumRetryCount int    `json:"maximumRetryCount"` //on-failure最多重启的次数 0为不限制
+}
+
+// 解析 no always unless-stopped on-failure[:max-retries]
+func ParseRestartPolicy(value string) (RestartPolicy, error) {
+	name, count, hasCount := strings.Cut(value, ":")
+	policy := RestartPolicy{Name: name}
+	if hasCount {
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return policy, fmt.Errorf("invalid restart policy %s: maximum retry count must be an integer", value)
+		}
+		policy.MaximumRetryCount = n
+	}
+	if err := policy.Validate(); err != nil {
+		return policy, err
+	}
+	return policy, nil
+}
+
+func (t RestartPolicy) Validate() error {
+	switch t.Name {
+	case "", RestartNo, RestartAlways, RestartUnlessStopped:
+		if t.MaximumRetryCount != 0 {
+			return fmt.Errorf("maximum retry count can only be used with %s", RestartOnFailure)
+		}
+	case RestartOnFailure:
+		if t.MaximumRetryCount < 0 {
+			return fmt.Errorf("maximum retry count can not be negative")
+		}
+	default:
+		return fmt.Errorf("invalid restart policy %s", t.Name)
+	}
+	return nil
+}
+
+func (t RestartPolicy) String() string {
+	if t.Name == RestartOnFailure && t.MaximumRetryCount > 0 {
+		return fmt.Sprintf("%s:%d", t.Name, t.MaximumRetryCount)
+	}
+	if t.Name == "" {
+		return RestartNo
+	}
+	return t.Name
+}
+
+// 自己退出的容器才按策略重启 退出码未知时按失败处理
+func (t *ContainerInfos) shouldRestart() bool {
+	// 记录的进程还在时再启动会出现两个init进程
+	if t.Status != Exit || isContainerAlive(t) {
+		return false
+	}
+	switch t.Restart.Name {
+	case RestartAlways, RestartUnlessStopped:
+		return true
+	case RestartOnFailure:
+		if t.ExitCode == 0 {
+			return false
+		}
+		return t.Restart.MaximumRetryCount == 0 || t.Restarts < t.Restart.MaximumRetryCount
+	}
+	return false
+}
+
+// 容器进程存在并且状态为运行中
+func (t *ContainerInfos) IsAlive() bool {
+	return t.Status == Running && isContainerAlive(t)
+}
+
+// 记录的init进程还是这个容器的进程 不管记录的状态
+func (t *ContainerInfos) ProcessAlive() bool {
+	return isContainerAlive(t)
+}
+
+// 记录容器进程的退出 只处理记录的pid还是这个进程并且状态为运行中的容器
+// stop等命令已经修改过状态时不再处理
+func MarkContainerExited(name string, pid string, exitCode int) error {
+	_, err := updateContainerInfo(name, func(info *ContainerInfos) error {
+		if info.Status != Running || info.Pid != pid {
+			return nil
+		}
+		info.emitDie(exitCode)
+		info.Status = Exit
+		return nil
+	})
+	return errors.WithStack(err)
+}
+
+// 按重启策略重新启动已经退出的容器 start用于启动容器 返回重启的容器名称
+func ApplyRestartPolicies(start func(name string) error) ([]string, error) {
+	infos, err := ListContainerInfos()
+	if err != nil {
+		return nil, err
+	}
+	restarted := []string{}
+	for i := range infos {
+		info := &infos[i]
+		if !info.shouldRestart() {
+			continue
+		}
+		// 加锁后重新检查 其他进程可能已经重启过这个容器
+		restart := false
+		updated, err := updateContainerInfo(info.Name, func(latest *ContainerInfos) error {
+			if restart = latest.shouldRestart(); restart {
+				latest.Restarts++
+			}
+			return nil
+		})
+		if err != nil {
+			return restarted, errors.WithStack(err)
+		}
+		if !restart {
+			continue
+		}
+		info = updated
+		slog.Info("restart policy", "name", info.Name, "policy", info.Restart.String(), "count", info.Restarts)
+		if err := start(info.Name); err != nil {
+			slog.Error("restart policy", "name", info.Name, "err", err)
+			continue
+		}
+		info.emit(events.ActionRestart, map[string]string{"policy": info.Restart.String()})
+		restarted = append(restarted, info.Name)
+	}
+	return restarted, nil
+}
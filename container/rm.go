@@ -2,7 +2,9 @@ package container
 
 import (
 	"fmt"
+	"log/slog"
 
+	"github.com/kehaha-5/go-low-level-container/events"
 	"github.com/kehaha-5/go-low-level-container/network"
 	"github.com/pkg/errors"
 	"github.com/vishvananda/netns"
@@ -10,19 +12,21 @@ import (
 
 func Rm(name string, force bool) error {
 	data := ContainerInfos{}
-	err := GetInfoByContainerName(name, &data)
+	err := GetInfoByContainerRef(name, &data)
 	if err != nil {
 		return errors.Wrap(err, "fail to get info by conatiner name")
 	}
 
+	if data.Status == Created {
+		return removeCreatedContainer(&data)
+	}
+
 	workSpaceInfo := getWorkSpackInfoByContainerInfos(&data)
-	if data.Status != Stop {
+	// 状态被错误修改过时进程也可能还在 不能删除正在使用的工作目录
+	if data.Status == Running || isContainerAlive(&data) {
 		if !force {
 			return fmt.Errorf("container is running")
 		}
-	}
-
-	if data.Status != Stop {
 		if err := StopContainerByName(name); err != nil {
 			return err
 		}
@@ -33,13 +37,25 @@ func Rm(name string, force bool) error {
 		}
 	}
 
-	if err := netns.DeleteNamed(data.Name); err != nil {
-		return fmt.Errorf("fail to delete netns %v", err)
+	if data.Namespaces.OwnNetns() {
+		if err := netns.DeleteNamed(data.Name); err != nil {
+			return fmt.Errorf("fail to delete netns %v", err)
+		}
+	}
+
+	if data.Cg.Path != "" {
+		if err := data.Cg.Clean(); err != nil {
+			slog.Error("clean cgroup", "name", data.Name, "err", err)
+		}
 	}
 
 	if err := data.del(); err != nil {
 		return err
 	}
 
-	return workSpaceInfo.delWorkSpace()
+	if err := workSpaceInfo.delWorkSpace(); err != nil {
+		return err
+	}
+	data.emit(events.ActionDestroy, nil)
+	return nil
 }
@@ -6,9 +6,12 @@ import (
 	"log/slog"
 	"os"
 	"os/exec"
+	"path"
 
 	"github.com/kehaha-5/go-low-level-container/cgroups"
 	"github.com/kehaha-5/go-low-level-container/cgroups/limit"
+	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/kehaha-5/go-low-level-container/events"
 	"github.com/kehaha-5/go-low-level-container/network"
 
 	"github.com/pkg/errors"
@@ -25,36 +28,134 @@ type RunCommandArgs struct {
 	EnvList       []string
 	Net           string
 	PortMapping   string
+	Labels        []string
+	LogConfig     *LogConfig
+	AutoRemove    bool
+	Record        bool
+	OomScoreAdj   int
+	CgroupParent  string
+	Ulimits       []Ulimit
+	Sysctls       map[string]string
+	Namespaces    NamespaceModes
+	Pod           string
+	Restart       RestartPolicy
+	// create保存的容器启动时使用create时生成的id和名称
+	createdId string
 }
 
-func RunContainer(args *RunCommandArgs) error {
+// 创建并启动容器 后台运行的容器启动后立即返回 交互式的容器退出后才返回
+func RunContainer(args *RunCommandArgs) (_ *ContainerInfos, err error) {
+	if err := args.Namespaces.Validate(); err != nil {
+		return nil, err
+	}
+	if err := args.Restart.Validate(); err != nil {
+		return nil, err
+	}
+	if err := args.Namespaces.checkSysctls(args.Sysctls); err != nil {
+		return nil, err
+	}
+	if !args.Namespaces.OwnNetns() && args.PortMapping != "" {
+		return nil, fmt.Errorf("port mapping is not allowed with net mode %s", args.Namespaces.Net)
+	}
+	// 要加入的容器或pod需要在运行
+	if _, err := args.Namespaces.joinPaths(); err != nil {
+		return nil, err
+	}
+
 	containerInfo := &ContainerInfos{}
-	containerInfo.SetContainerName(args.ContainerName)
-	cmd, writePipe, workSpace, err := initContainerParentWithNewWorkSpace(args.Tty, args.VolumeArg, containerInfo.Name, args.ImageName, args.EnvList)
+	if args.createdId != "" {
+		containerInfo.Id = args.createdId
+		containerInfo.Name = args.ContainerName
+	} else if err := containerInfo.SetContainerName(args.ContainerName); err != nil {
+		return nil, err
+	}
+	// 启动失败时释放占用的容器名称
+	defer func() {
+		if err != nil && !common.FileExist(path.Join(defaultInfoSavefilepath, containerInfo.Name, defaultInfoSavename)) {
+			containerInfo.DeleteContainerInfo()
+		}
+	}()
+	cmd, writePipe, workSpace, err := initContainerParentWithNewWorkSpace(args.VolumeArg, containerInfo.Name, args.ImageName, args.EnvList)
 	if err != nil {
-		return errors.WithStack(err)
+		return nil, errors.WithStack(err)
 	}
 
-	// 添加新的net namespace
-	if err := exec.Command("ip", "netns", "add", containerInfo.Name).Run(); err != nil {
-		return errors.Wrapf(err, "fail to add ip netns %s", containerInfo.Name)
+	args.Namespaces.setCloneflags(cmd)
+	containerInfo.Namespaces = args.Namespaces
+
+	// 添加新的net namespace 使用宿主机或其他容器的net namespace时不需要
+	netnsName := ""
+	if args.Namespaces.OwnNetns() {
+		netnsName = containerInfo.Name
+		if err := exec.Command("ip", "netns", "add", netnsName).Run(); err != nil {
+			return nil, errors.Wrapf(err, "fail to add ip netns %s", netnsName)
+		}
+		if args.Net == "" {
+			if err := network.SetUpLoopback(netnsName); err != nil {
+				return nil, err
+			}
+		}
+	}
+	hostname := ""
+	if args.Namespaces.OwnUts() {
+		hostname = containerInfo.Name
 	}
 
+	ulimits, err := resolveUlimits(args.Ulimits)
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to resolve ulimits")
+	}
+	containerInfo.Ulimits = ulimits
+
 	initArgs := &initArgs{
-		Hostname:  containerInfo.Name,
-		MountRoot: workSpace.mountRoot,
-		Args:      args.CommandArgs,
-		NetnsName: containerInfo.Name,
+		Hostname:    hostname,
+		MountRoot:   workSpace.mountRoot,
+		Args:        args.CommandArgs,
+		NetnsName:   netnsName,
+		OomScoreAdj: args.OomScoreAdj,
+		Ulimits:     ulimits,
+		Sysctls:     args.Sysctls,
+	}
+
+	var tty *ttySession
+	if args.Tty {
+		// 交互式的容器输出同时写入日志 退出后也可以通过log查看
+		if tty, err = attachConsole(cmd, containerInfo.Name, args.LogConfig); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		defer tty.Close()
+		if args.Record {
+			if err := tty.record(containerInfo.Name, RecordKindRun, args.CommandArgs); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		closeLogPipe, err := attachLogger(cmd, containerInfo.Name, args.LogConfig)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		defer closeLogPipe()
 	}
 
 	slog.Info("create container process and running ")
-	if err := cmd.Start(); err != nil {
-		return err
+	if err := startInNamespaces(cmd, &args.Namespaces); err != nil {
+		return nil, err
+	}
+	if tty != nil {
+		tty.start()
 	}
 
 	containerInfo.setBaseInfo(cmd.Process.Pid, args)
-	slog.Info("limit rescoure", "mem", args.LimitResConf.Memory, "cpu", args.LimitResConf.Cpu, "cpuset", args.LimitResConf.Cpuset)
-	cg := cgroups.NewCgroupManager(containerInfo.Name)
+	slog.Info("limit rescoure", "mem", args.LimitResConf.Memory, "cpu", args.LimitResConf.Cpu, "cpus", args.LimitResConf.Cpus, "cpuset", args.LimitResConf.Cpuset, "pids", args.LimitResConf.PidsLimit)
+	cgPath, err := cgroups.ContainerPath(args.CgroupParent, containerInfo.Name)
+	if err != nil {
+		return nil, err
+	}
+	containerInfo.CgParent = path.Dir(cgPath)
+	if err := cgroups.RestoreGroup(containerInfo.CgParent); err != nil {
+		slog.Error("restore cgroup group", "err", err)
+	}
+	cg := cgroups.NewCgroupManager(cgPath)
 	if err := cg.Set(args.LimitResConf); err == nil {
 		if err := cg.Apply(cmd.Process.Pid); err != nil {
 			slog.Error("set cg", "err", err)
@@ -67,36 +168,50 @@ func RunContainer(args *RunCommandArgs) error {
 	slog.Info("save contianer info")
 
 	if err := sendMsgToPipe(writePipe, initArgs); err != nil {
-		return errors.WithStack(err)
+		return nil, errors.WithStack(err)
 	}
 
 	if args.Net != "" {
 		if err := network.Init(); err != nil {
-			return errors.WithStack(err)
+			return nil, errors.WithStack(err)
 		}
 		ep, err := network.Connect(args.Net, containerInfo.Id, containerInfo.Name, containerInfo.PortMapping)
 		if err != nil {
-			return errors.Wrap(err, "fail to connect net")
+			return nil, errors.Wrap(err, "fail to connect net")
 		}
 		containerInfo.SetNetInfo(ep)
 	}
 
 	// 记录container信息
 	if err := containerInfo.RecordContainerInfo(); err != nil {
-		return fmt.Errorf("recordContainerInfo %+v", err)
+		return nil, fmt.Errorf("recordContainerInfo %+v", err)
+	}
+	if args.createdId != "" {
+		if err := os.Remove(getCreateConfigPath(containerInfo.Name)); err != nil {
+			slog.Error("remove create config", "name", containerInfo.Name, "err", err)
+		}
+	} else {
+		containerInfo.emit(events.ActionCreate, nil)
 	}
+	containerInfo.emit(events.ActionStart, nil)
 
 	if args.Tty {
 		cmd.Wait()
-		if err := workSpace.delWorkSpace(); err != nil {
-			slog.Error(fmt.Errorf("delWorkSpace err %+v", err).Error())
+		tty.Close()
+		containerInfo.emitDie(cmd.ProcessState.ExitCode())
+		if err := cg.Destroy(); err != nil {
+			slog.Error("destroy cgroup", "err", err)
+		}
+		// 和后台运行的容器一样保留到rm 方便事后查看日志
+		if err := containerInfo.modifyContainerStatusByName(Exit); err != nil {
+			return nil, errors.WithStack(err)
 		}
-		containerInfo.DeleteContainerInfo()
-		defer cg.Destroy()
-		return nil
+		if args.AutoRemove {
+			return containerInfo, Rm(containerInfo.Name, false)
+		}
+		return containerInfo, nil
 	}
-	fmt.Fprintln(os.Stdout, containerInfo.Name)
-	return nil
+	return containerInfo, nil
 }
 
 func RunContainerProgram() error {
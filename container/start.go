@@ -3,17 +3,30 @@ package container
 import (
 	"fmt"
 	"log/slog"
+	"path"
 	"strings"
 
+	"github.com/kehaha-5/go-low-level-container/cgroups"
+	"github.com/kehaha-5/go-low-level-container/events"
 	"github.com/kehaha-5/go-low-level-container/network"
 	"github.com/pkg/errors"
 )
 
+// 容器的进程还在时再启动会有两个init进程 由调用方决定是忽略还是报错
+var ErrContainerRunning = errors.New("container is already running")
+
 func StartContainerByName(name string) error {
 	info := ContainerInfos{}
-	if err := GetInfoByContainerName(name, &info); err != nil {
+	if err := GetInfoByContainerRef(name, &info); err != nil {
 		return errors.Wrap(err, "fail to get container info")
 	}
+	// 不看记录的状态 状态可能已经被改写但进程还在
+	if info.ProcessAlive() {
+		return errors.Wrapf(ErrContainerRunning, "container %s", info.Name)
+	}
+	if info.Status == Created {
+		return startCreatedContainer(&info)
+	}
 
 	if info.IpInfo.ID != "" {
 		if err := network.ConfigMapping(&info.IpInfo); err != nil {
@@ -25,34 +38,48 @@ func StartContainerByName(name string) error {
 		return errors.Wrap(err, "fail to init container parent")
 	}
 
-	delLogByContainerName(info.Name)
-
-	lopfile, err := createlogfilePointer(info.Name)
+	closeLogPipe, err := attachLogger(cmd, info.Name, &info.LogConfig)
 	if err != nil {
-		return errors.Wrap(err, "fail to get log file ptr")
+		return errors.Wrap(err, "fail to attach logger")
 	}
-	cmd.Stdout = lopfile
-	cmd.Stderr = lopfile
+	defer closeLogPipe()
 
 	setProcessEnv(cmd, readPipe, info.Env)
+	info.Namespaces.setCloneflags(cmd)
 
 	initArgs := &initArgs{
-		Hostname:  info.Name,
-		MountRoot: getMountRootPathByContainerName(info.Name),
-		Args:      strings.Split(info.Command, " "),
-		NetnsName: info.Name,
+		MountRoot:   getMountRootPathByContainerName(info.Name),
+		Args:        strings.Split(info.Command, " "),
+		OomScoreAdj: info.OomScoreAdj,
+		Ulimits:     info.Ulimits,
+		Sysctls:     info.Sysctls,
+	}
+
+	if info.Namespaces.OwnNetns() {
+		initArgs.NetnsName = info.Name
+	}
+	if info.Namespaces.OwnUts() {
+		initArgs.Hostname = info.Name
 	}
 
-	if err := cmd.Start(); err != nil {
+	if err := startInNamespaces(cmd, &info.Namespaces); err != nil {
 		return err
 	}
 
 	info.UpdatePid(cmd.Process.Pid)
-	if info.Cg.Path != "" {
+	if info.Cg.Path != "" && info.Cg.Resource != nil {
 		slog.Debug("set cg")
-		if err := info.Cg.Apply(cmd.Process.Pid); err != nil {
+		// 宿主机重启后父资源组的组限制也需要恢复
+		if parent := path.Dir(info.Cg.Path); parent != "." {
+			if err := cgroups.RestoreGroup(parent); err != nil {
+				slog.Error("restore cgroup group", "err", err)
+			}
+		}
+		// 重新写入记录的资源限制 update命令修改过的限制也会在这里生效
+		if err := info.Cg.Set(info.Cg.Resource); err != nil {
+			slog.Error("set cg", "err", err)
+		} else if err := info.Cg.Apply(cmd.Process.Pid); err != nil {
 			slog.Error("set cg", "err", err)
-
 		}
 	}
 
@@ -60,9 +87,11 @@ func StartContainerByName(name string) error {
 		return errors.WithStack(err)
 	}
 	// 记录container信息
+	info.OOMKilled = false
 	if err := info.modifyContainerStatusByName(Running); err != nil {
 		return fmt.Errorf("recordContainerInfo %+v", err)
 	}
+	info.emit(events.ActionStart, nil)
 
 	return nil
 }
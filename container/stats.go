@@ -0,0 +1,104 @@
+package container
+
+import (
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/kehaha-5/go-low-level-container/events"
+	"github.com/pkg/errors"
+)
+
+// 输出容器资源的使用情况 refs为空时输出所有运行中的容器
+func WirteStatsToTabwriter(w *tabwriter.Writer, refs []string) error {
+	infos := []ContainerInfos{}
+	if len(refs) == 0 {
+		all, err := ListContainerInfos()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		for _, info := range all {
+			if info.Status == Running {
+				infos = append(infos, info)
+			}
+		}
+	} else {
+		for _, ref := range refs {
+			info := ContainerInfos{}
+			if err := GetInfoByContainerRef(ref, &info); err != nil {
+				return err
+			}
+			infos = append(infos, info)
+		}
+	}
+
+	fmt.Fprint(w, "ID\tNAME\tMEM USAGE / LIMIT\tPIDS / LIMIT\tPIDS LIMIT HIT\n")
+	for _, info := range infos {
+		if info.Cg.Path == "" || info.Status != Running {
+			fmt.Fprintf(w, "%s\t%s\t--\t--\t--\n", info.ShortId(), info.Name)
+			continue
+		}
+		stats, err := info.Cg.Stats()
+		if err != nil {
+			return errors.Wrapf(err, "fail to get stats of container %s", info.Name)
+		}
+		fmt.Fprintf(
+			w, "%s\t%s\t%s / %s\t%d / %s\t%d\n",
+			info.ShortId(),
+			info.Name,
+			common.SizeHumanReadable(stats.MemoryUsage),
+			limitHumanReadable(stats.MemoryLimit, common.SizeHumanReadable),
+			stats.Pids,
+			limitHumanReadable(stats.PidsLimit, func(n int64) string { return strconv.FormatInt(n, 10) }),
+			stats.PidsMaxEvents,
+		)
+	}
+	return nil
+}
+
+// 资源组中达到进程数限制的次数增加时记录事件 由调用者保存容器信息
+func (t *ContainerInfos) checkPidsLimit() {
+	stats, err := t.Cg.Stats()
+	if err != nil || stats.PidsMaxEvents <= t.PidsMaxHits {
+		return
+	}
+	t.PidsMaxHits = stats.PidsMaxEvents
+	t.emit(events.ActionPidsLimit, map[string]string{
+		"count": strconv.FormatInt(stats.PidsMaxEvents, 10),
+		"limit": strconv.FormatInt(stats.PidsLimit, 10),
+	})
+}
+
+// 检查运行中的容器是否达到过进程数限制 容器退出时emitDie也会检查
+func CheckPidsLimits() error {
+	infos, err := ListContainerInfos()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for i := range infos {
+		info := &infos[i]
+		if info.Cg.Path == "" || !info.IsAlive() {
+			continue
+		}
+		// 次数没有增加时不需要加锁写入
+		stats, err := info.Cg.Stats()
+		if err != nil || stats.PidsMaxEvents <= info.PidsMaxHits {
+			continue
+		}
+		if _, err := updateContainerInfo(info.Name, func(latest *ContainerInfos) error {
+			latest.checkPidsLimit()
+			return nil
+		}); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+func limitHumanReadable(limit int64, format func(int64) string) string {
+	if limit == 0 {
+		return "unlimited"
+	}
+	return format(limit)
+}
@@ -6,21 +6,33 @@ import (
 	"strings"
 	"syscall"
 
+	"github.com/kehaha-5/go-low-level-container/events"
 	"github.com/kehaha-5/go-low-level-container/network"
 )
 
 func StopContainerByName(name string) error {
 	info := ContainerInfos{}
-	err := GetInfoByContainerName(name, &info)
+	err := GetInfoByContainerRef(name, &info)
 	if err != nil {
 		return err
 	}
+	// 还没有启动过的容器没有进程
+	if info.Status == Created {
+		return nil
+	}
 	intPid, err := strconv.Atoi(info.Pid)
 	if err != nil {
 		return err
 	}
-	if err := syscall.Kill(intPid, syscall.SIGKILL); err != nil && !strings.Contains(err.Error(), "no such process") {
-		slog.Error("stop", "kill pid", err)
+	// 只要记录的pid还是容器的进程就kill 状态可能已经被错误地修改过
+	// 进程已经不存在时pid可能被其他进程复用了 不能再kill
+	if isContainerAlive(&info) {
+		if err := syscall.Kill(intPid, syscall.SIGKILL); err != nil && !strings.Contains(err.Error(), "no such process") {
+			slog.Error("stop", "kill pid", err)
+		} else {
+			info.emit(events.ActionKill, map[string]string{"signal": strconv.Itoa(int(syscall.SIGKILL))})
+			info.emitDie(128 + int(syscall.SIGKILL))
+		}
 	}
 
 	if info.IpInfo.ID != "" {
@@ -29,5 +41,9 @@ func StopContainerByName(name string) error {
 		}
 	}
 
-	return info.modifyContainerStatusByName(Stop)
+	if err := info.modifyContainerStatusByName(Stop); err != nil {
+		return err
+	}
+	info.emit(events.ActionStop, nil)
+	return nil
 }
@@ -0,0 +1,66 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const procSysPath = "/proc/sys"
+
+// 只有这些sysctl是按namespace隔离的 修改不会影响宿主机
+// 容器都有自己的net和ipc namespace
+var namespacedSysctlPrefixes = []string{"net.", "kernel.shm", "kernel.msg", "fs.mqueue."}
+
+// 解析 --sysctl key=value 并检查是否允许在容器中修改
+func ParseSysctls(values []string) (map[string]string, error) {
+	res := map[string]string{}
+	for _, value := range values {
+		key, val, ok := strings.Cut(value, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid sysctl %s (expected key=value)", value)
+		}
+		if err := validateSysctl(key); err != nil {
+			return nil, err
+		}
+		res[key] = val
+	}
+	return res, nil
+}
+
+func validateSysctl(key string) error {
+	if strings.Contains(key, "/") || strings.Contains(key, "..") {
+		return fmt.Errorf("invalid sysctl %s", key)
+	}
+	for _, prefix := range namespacedSysctlPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("sysctl %s is not namespaced and not allowed in container", key)
+}
+
+// 在容器的init进程中 挂载好容器自己的/proc之后调用
+// net的sysctl按打开文件的进程所在的net namespace生效
+func applySysctls(sysctls map[string]string) error {
+	keys := make([]string, 0, len(sysctls))
+	for key := range sysctls {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := validateSysctl(key); err != nil {
+			return err
+		}
+		sysctlFile := path.Join(procSysPath, strings.ReplaceAll(key, ".", "/"))
+		if err := os.WriteFile(sysctlFile, []byte(sysctls[key]), 0644); err != nil {
+			return errors.Wrapf(err, "fail to set sysctl %s", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package container
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	logOptSyslogAddress  string = "syslog-address"
+	logOptSyslogFacility string = "syslog-facility"
+
+	defaultSyslogSocket   string = "/dev/log"
+	defaultSyslogFacility string = "daemon"
+	// RFC 5424 中APP-NAME最长48个字符
+	syslogAppNameMaxLen int = 48
+
+	syslogSeverityErr  int = 3
+	syslogSeverityInfo int = 6
+)
+
+var syslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+type syslogOptions struct {
+	network  string // 为空时依次尝试本地的unixgram和unix
+	address  string
+	facility int
+	tag      string
+}
+
+// syslog-address 支持 unix:///dev/log unixgram:///dev/log udp://host:514 tcp://host:514
+func newSyslogOptions(containerName string, opts map[string]string) (*syslogOptions, error) {
+	res := &syslogOptions{address: defaultSyslogSocket, facility: syslogFacilities[defaultSyslogFacility]}
+	for k, v := range opts {
+		switch k {
+		case logOptSyslogAddress:
+			u, err := url.Parse(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid log opt %s=%s", k, v)
+			}
+			switch u.Scheme {
+			case "unix", "unixgram":
+				res.network, res.address = u.Scheme, u.Path
+			case "udp", "tcp":
+				res.network, res.address = u.Scheme, u.Host
+				if u.Port() == "" {
+					res.address = net.JoinHostPort(u.Hostname(), "514")
+				}
+			default:
+				return nil, fmt.Errorf("invalid log opt %s=%s (unsupported scheme %s)", k, v, u.Scheme)
+			}
+		case logOptSyslogFacility:
+			facility, ok := syslogFacilities[v]
+			if !ok {
+				return nil, fmt.Errorf("invalid log opt %s=%s (unknown facility)", k, v)
+			}
+			res.facility = facility
+		case logOptTag:
+		default:
+			return nil, fmt.Errorf("unknown log opt %s for log driver %s", k, syslogLogDriver)
+		}
+	}
+	res.tag = getLogTag(containerName, opts)
+	if len(res.tag) > syslogAppNameMaxLen {
+		res.tag = res.tag[:syslogAppNameMaxLen]
+	}
+	return res, nil
+}
+
+// 按 RFC 5424 格式发送日志
+type syslogWriter struct {
+	opts     *syslogOptions
+	hostname string
+	pid      int
+	conn     net.Conn
+	network  string // 实际连接使用的协议
+}
+
+func newSyslogWriter(containerName string, opts map[string]string) (LogDriver, error) {
+	syslogOpts, err := newSyslogOptions(containerName, opts)
+	if err != nil {
+		return nil, err
+	}
+	w := &syslogWriter{opts: syslogOpts, hostname: getLogHostname(), pid: os.Getpid()}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (t *syslogWriter) connect() error {
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+	networks := []string{t.opts.network}
+	if t.opts.network == "" {
+		networks = []string{"unixgram", "unix"}
+	}
+	var err error
+	for _, network := range networks {
+		var conn net.Conn
+		if conn, err = net.DialTimeout(network, t.opts.address, 5*time.Second); err == nil {
+			t.conn, t.network = conn, network
+			return nil
+		}
+	}
+	return errors.Wrapf(err, "fail to connect syslog %s", t.opts.address)
+}
+
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+func (t *syslogWriter) format(entry *logEntry) string {
+	severity := syslogSeverityInfo
+	if entry.Stream == stderrStream {
+		severity = syslogSeverityErr
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s - %s",
+		t.opts.facility*8+severity,
+		entry.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		t.hostname,
+		t.opts.tag,
+		t.pid,
+		entry.Stream,
+		strings.TrimRight(entry.Log, "\n"),
+	)
+}
+
+func (t *syslogWriter) WriteLog(entry *logEntry) error {
+	msg := t.format(entry)
+	if t.conn != nil {
+		if err := t.send(msg); err == nil {
+			return nil
+		}
+	}
+	// syslog服务重启后重连一次
+	if err := t.connect(); err != nil {
+		return err
+	}
+	return t.send(msg)
+}
+
+func (t *syslogWriter) send(msg string) error {
+	// 面向流的连接使用 RFC 6587 的长度前缀分帧
+	if t.network == "tcp" || t.network == "unix" {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+	_, err := t.conn.Write([]byte(msg))
+	return errors.WithStack(err)
+}
+
+func (t *syslogWriter) Close() error {
+	if t.conn == nil {
+		return nil
+	}
+	return t.conn.Close()
+}
@@ -0,0 +1,162 @@
+package container
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/kehaha-5/go-low-level-container/store"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+const defaultDefaultsSavename string = "defaults.json"
+
+// 容器进程的资源限制 如 nofile=1024:2048
+type Ulimit struct {
+	Name string `json:"name"`
+	Soft uint64 `json:"soft"`
+	Hard uint64 `json:"hard"`
+}
+
+// 所有新建容器默认使用的配置 容器的参数会覆盖同名的默认值
+type Defaults struct {
+	Ulimits []Ulimit `json:"ulimits"`
+}
+
+var ulimitResources = map[string]int{
+	"as":         unix.RLIMIT_AS,
+	"core":       unix.RLIMIT_CORE,
+	"cpu":        unix.RLIMIT_CPU,
+	"data":       unix.RLIMIT_DATA,
+	"fsize":      unix.RLIMIT_FSIZE,
+	"locks":      unix.RLIMIT_LOCKS,
+	"memlock":    unix.RLIMIT_MEMLOCK,
+	"msgqueue":   unix.RLIMIT_MSGQUEUE,
+	"nice":       unix.RLIMIT_NICE,
+	"nofile":     unix.RLIMIT_NOFILE,
+	"nproc":      unix.RLIMIT_NPROC,
+	"rss":        unix.RLIMIT_RSS,
+	"rtprio":     unix.RLIMIT_RTPRIO,
+	"rttime":     unix.RLIMIT_RTTIME,
+	"sigpending": unix.RLIMIT_SIGPENDING,
+	"stack":      unix.RLIMIT_STACK,
+}
+
+func (t Ulimit) String() string {
+	return fmt.Sprintf("%s=%s:%s", t.Name, ulimitValueString(t.Soft), ulimitValueString(t.Hard))
+}
+
+func ulimitValueString(value uint64) string {
+	if value == unix.RLIM_INFINITY {
+		return "unlimited"
+	}
+	return strconv.FormatUint(value, 10)
+}
+
+// 解析 name=soft[:hard] 没有hard时和soft相同 -1或unlimited表示不限制
+func ParseUlimits(values []string) ([]Ulimit, error) {
+	res := []Ulimit{}
+	for _, value := range values {
+		name, limits, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid ulimit %s (expected name=soft[:hard])", value)
+		}
+		if _, ok := ulimitResources[name]; !ok {
+			return nil, fmt.Errorf("invalid ulimit type %s", name)
+		}
+		softStr, hardStr, hasHard := strings.Cut(limits, ":")
+		if !hasHard {
+			hardStr = softStr
+		}
+		soft, err := parseUlimitValue(softStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid soft limit of ulimit %s", value)
+		}
+		hard, err := parseUlimitValue(hardStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hard limit of ulimit %s", value)
+		}
+		if soft > hard {
+			return nil, fmt.Errorf("soft limit is greater than hard limit in ulimit %s", value)
+		}
+		res = mergeUlimits(res, []Ulimit{{Name: name, Soft: soft, Hard: hard}})
+	}
+	return res, nil
+}
+
+func parseUlimitValue(value string) (uint64, error) {
+	if value == "unlimited" || value == "-1" {
+		return unix.RLIM_INFINITY, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// ulimits中同名的限制覆盖old中的
+func mergeUlimits(old []Ulimit, ulimits []Ulimit) []Ulimit {
+	merged := append([]Ulimit{}, old...)
+	for _, item := range ulimits {
+		idx := slices.IndexFunc(merged, func(u Ulimit) bool { return u.Name == item.Name })
+		if idx < 0 {
+			merged = append(merged, item)
+		} else {
+			merged[idx] = item
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name < merged[j].Name })
+	return merged
+}
+
+// 在容器的init进程中exec之前调用 exec之后的进程会继承这些限制
+func applyUlimits(ulimits []Ulimit) error {
+	for _, item := range ulimits {
+		resource, ok := ulimitResources[item.Name]
+		if !ok {
+			return fmt.Errorf("invalid ulimit type %s", item.Name)
+		}
+		rlimit := &unix.Rlimit{Cur: item.Soft, Max: item.Hard}
+		if err := unix.Prlimit(0, resource, rlimit, nil); err != nil {
+			return errors.Wrapf(err, "fail to set ulimit %s", item)
+		}
+	}
+	return nil
+}
+
+func getDefaultsSavePath() string {
+	return path.Join(common.ROOTPATH, defaultDefaultsSavename)
+}
+
+// 读取全局默认配置 没有配置时返回空的
+func GetDefaults() (*Defaults, error) {
+	defaults := &Defaults{}
+	if _, err := store.ReadJSON(getDefaultsSavePath(), defaults); err != nil {
+		return nil, err
+	}
+	return defaults, nil
+}
+
+// 修改全局的默认ulimit 已有的同名限制会被覆盖
+// reset为true时先清空原来的默认值
+func SetDefaultUlimits(ulimits []Ulimit, reset bool) error {
+	defaults := &Defaults{}
+	return store.UpdateJSON(defaultDefaultsSavename, getDefaultsSavePath(), defaults, func() error {
+		if reset {
+			defaults.Ulimits = nil
+		}
+		defaults.Ulimits = mergeUlimits(defaults.Ulimits, ulimits)
+		return nil
+	})
+}
+
+// 容器最终使用的ulimit 全局默认值加上容器自己的参数
+func resolveUlimits(ulimits []Ulimit) ([]Ulimit, error) {
+	defaults, err := GetDefaults()
+	if err != nil {
+		return nil, err
+	}
+	return mergeUlimits(defaults.Ulimits, ulimits), nil
+}
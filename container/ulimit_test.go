@@ -0,0 +1,49 @@
+package container
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestParseUlimits(t *testing.T) {
+	tests := []struct {
+		values  []string
+		want    []Ulimit
+		wantErr bool
+	}{
+		{values: nil, want: []Ulimit{}},
+		{values: []string{"nofile=1024:2048"}, want: []Ulimit{{Name: "nofile", Soft: 1024, Hard: 2048}}},
+		{values: []string{"nproc=64"}, want: []Ulimit{{Name: "nproc", Soft: 64, Hard: 64}}},
+		{values: []string{"core=-1"}, want: []Ulimit{{Name: "core", Soft: unix.RLIM_INFINITY, Hard: unix.RLIM_INFINITY}}},
+		{values: []string{"stack=8192:unlimited"}, want: []Ulimit{{Name: "stack", Soft: 8192, Hard: unix.RLIM_INFINITY}}},
+		// 同名的后面覆盖前面 结果按名称排序
+		{values: []string{"nofile=10", "core=0", "nofile=20:30"}, want: []Ulimit{
+			{Name: "core", Soft: 0, Hard: 0},
+			{Name: "nofile", Soft: 20, Hard: 30},
+		}},
+		{values: []string{"nofile"}, wantErr: true},
+		{values: []string{"files=10"}, wantErr: true},
+		{values: []string{"nofile=2048:1024"}, wantErr: true},
+		{values: []string{"nofile=unlimited:1024"}, wantErr: true},
+		{values: []string{"nofile=a:b"}, wantErr: true},
+		{values: []string{"nofile=-2"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseUlimits(tt.values)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseUlimits(%q) expected error, got %v", tt.values, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseUlimits(%q) unexpected error %v", tt.values, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseUlimits(%q) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
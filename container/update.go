@@ -0,0 +1,171 @@
+package container
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"github.com/kehaha-5/go-low-level-container/cgroups"
+	"github.com/kehaha-5/go-low-level-container/cgroups/limit"
+	"github.com/pkg/errors"
+)
+
+// 修改容器的资源限制 res中为零值的字段保持原来的限制 值为-1表示取消该限制
+func UpdateContainerResource(name string, res *limit.ResourceConfig) error {
+	ref := ContainerInfos{}
+	if err := GetInfoByContainerRef(name, &ref); err != nil {
+		return errors.Wrap(err, "fail to get container info")
+	}
+
+	// 持有容器锁完成修改 避免覆盖同时发生的状态变化
+	_, err := updateContainerInfo(ref.Name, func(info *ContainerInfos) error {
+		if info.Cg.Path == "" {
+			cgPath, err := cgroups.ContainerPath(info.CgParent, info.Name)
+			if err != nil {
+				return err
+			}
+			info.Cg = *cgroups.NewCgroupManager(cgPath)
+		}
+		newRes, err := mergeResourceConfig(info.Cg.Resource, res)
+		if err != nil {
+			return err
+		}
+
+		if err := info.Cg.Check(newRes); err != nil {
+			return errors.Wrap(err, "fail to check resource")
+		}
+		if err := info.Cg.Set(newRes); err != nil {
+			return errors.Wrap(err, "fail to set resource")
+		}
+
+		// 之前没有限制过的资源需要把进程加入到对应的资源组中
+		if info.Status == Running {
+			pid, err := strconv.Atoi(info.Pid)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if err := info.Cg.Apply(pid); err != nil {
+				return errors.Wrap(err, "fail to apply cg")
+			}
+		}
+		slog.Info("update", "name", info.Name, "resource", fmt.Sprintf("%+v", *newRes))
+		return nil
+	})
+	return err
+}
+
+// 解析出来的设备和大页限制是空的切片 和nil一样当作没有设置
+func isEmptyResourceConfig(res *limit.ResourceConfig) bool {
+	empty := *res
+	if len(empty.DeviceReadBps) == 0 {
+		empty.DeviceReadBps = nil
+	}
+	if len(empty.DeviceWriteBps) == 0 {
+		empty.DeviceWriteBps = nil
+	}
+	if len(empty.DeviceReadIops) == 0 {
+		empty.DeviceReadIops = nil
+	}
+	if len(empty.DeviceWriteIops) == 0 {
+		empty.DeviceWriteIops = nil
+	}
+	if len(empty.HugepageLimits) == 0 {
+		empty.HugepageLimits = nil
+	}
+	return reflect.ValueOf(empty).IsZero()
+}
+
+// 把需要修改的限制合并到原来的限制中 没有任何需要修改的限制时返回错误
+func mergeResourceConfig(old *limit.ResourceConfig, res *limit.ResourceConfig) (*limit.ResourceConfig, error) {
+	if isEmptyResourceConfig(res) {
+		return nil, errors.New("no resource limit to update")
+	}
+	merged := &limit.ResourceConfig{}
+	if old != nil {
+		*merged = *old
+	}
+	if res.Cpu != 0 {
+		merged.Cpu = res.Cpu
+	}
+	// cpus和cpu-quota不能同时设置 新设置的一个覆盖旧的另一个
+	if res.Cpus == "-1" {
+		merged.Cpus = ""
+		merged.CpuQuota = -1
+	} else if res.Cpus != "" {
+		merged.Cpus = res.Cpus
+		merged.CpuQuota = 0
+	}
+	if res.CpuQuota != 0 {
+		merged.CpuQuota = res.CpuQuota
+		merged.Cpus = ""
+	}
+	if res.CpuPeriod != 0 {
+		merged.CpuPeriod = res.CpuPeriod
+	}
+	if res.Cpuset != "" {
+		merged.Cpuset = res.Cpuset
+	}
+	if res.CpusetMems != "" {
+		merged.CpusetMems = res.CpusetMems
+	}
+	if res.Memory != "" {
+		merged.Memory = res.Memory
+		// 取消内存限制时swap的限制也没有意义了
+		if res.Memory == "-1" && res.MemorySwap == "" && merged.MemorySwap != "" {
+			merged.MemorySwap = "-1"
+		}
+	}
+	if res.MemoryHigh != "" {
+		merged.MemoryHigh = res.MemoryHigh
+	}
+	if res.MemorySwap != "" {
+		merged.MemorySwap = res.MemorySwap
+	}
+	if res.MemoryReservation != "" {
+		merged.MemoryReservation = res.MemoryReservation
+	}
+	if res.KernelMemory != "" {
+		merged.KernelMemory = res.KernelMemory
+	}
+	if res.PidsLimit != 0 {
+		merged.PidsLimit = res.PidsLimit
+	}
+	if res.BlkioWeight != 0 {
+		merged.BlkioWeight = res.BlkioWeight
+	}
+	// 同一个设备新的限制覆盖旧的
+	merged.DeviceReadBps = mergeThrottleDevices(merged.DeviceReadBps, res.DeviceReadBps)
+	merged.DeviceWriteBps = mergeThrottleDevices(merged.DeviceWriteBps, res.DeviceWriteBps)
+	merged.DeviceReadIops = mergeThrottleDevices(merged.DeviceReadIops, res.DeviceReadIops)
+	merged.DeviceWriteIops = mergeThrottleDevices(merged.DeviceWriteIops, res.DeviceWriteIops)
+	// 同一种大小的大页新的限制覆盖旧的
+	merged.HugepageLimits = append([]limit.HugepageLimit{}, merged.HugepageLimits...)
+	for _, item := range res.HugepageLimits {
+		idx := slices.IndexFunc(merged.HugepageLimits, func(old limit.HugepageLimit) bool {
+			return old.PageSize == item.PageSize
+		})
+		if idx < 0 {
+			merged.HugepageLimits = append(merged.HugepageLimits, item)
+		} else {
+			merged.HugepageLimits[idx] = item
+		}
+	}
+	return merged, nil
+}
+
+func mergeThrottleDevices(old []limit.ThrottleDevice, devices []limit.ThrottleDevice) []limit.ThrottleDevice {
+	merged := append([]limit.ThrottleDevice{}, old...)
+	for _, device := range devices {
+		idx := slices.IndexFunc(merged, func(item limit.ThrottleDevice) bool {
+			return item.Major == device.Major && item.Minor == device.Minor
+		})
+		if idx < 0 {
+			merged = append(merged, device)
+		} else {
+			merged[idx] = device
+		}
+	}
+	return merged
+}
@@ -0,0 +1,52 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/kehaha-5/go-low-level-container/cgroups/limit"
+)
+
+func TestMergeResourceConfig(t *testing.T) {
+	old := &limit.ResourceConfig{Cpu: 512, Cpus: "1.5", Memory: "536870912", MemorySwap: "1073741824", PidsLimit: 100}
+
+	if _, err := mergeResourceConfig(old, &limit.ResourceConfig{}); err == nil {
+		t.Errorf("empty update expected error")
+	}
+	// 命令行解析出来的设备限制是空切片
+	if _, err := mergeResourceConfig(old, &limit.ResourceConfig{DeviceReadBps: []limit.ThrottleDevice{}, HugepageLimits: []limit.HugepageLimit{}}); err == nil {
+		t.Errorf("update with empty device limits expected error")
+	}
+
+	// 零值的字段保持原来的限制
+	merged, err := mergeResourceConfig(old, &limit.ResourceConfig{PidsLimit: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.PidsLimit != 200 || merged.Cpu != 512 || merged.Cpus != "1.5" || merged.Memory != "536870912" {
+		t.Errorf("unexpected merged config %+v", *merged)
+	}
+	if old.PidsLimit != 100 {
+		t.Errorf("old config modified %+v", *old)
+	}
+
+	// -1取消限制 取消内存限制时swap的限制一起取消
+	merged, err = mergeResourceConfig(old, &limit.ResourceConfig{Cpus: "-1", Memory: "-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Cpus != "" || merged.CpuQuota != -1 {
+		t.Errorf("cpus not cleared %+v", *merged)
+	}
+	if merged.Memory != "-1" || merged.MemorySwap != "-1" {
+		t.Errorf("memory not cleared %+v", *merged)
+	}
+
+	// cpu-quota覆盖原来的cpus
+	merged, err = mergeResourceConfig(old, &limit.ResourceConfig{CpuQuota: 50000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Cpus != "" || merged.CpuQuota != 50000 {
+		t.Errorf("cpu quota not merged %+v", *merged)
+	}
+}
@@ -24,6 +24,8 @@ const (
 	defaultWorkLayer     string = "work"
 	defaultImagesPath    string = "images"
 	defaultMntRoot       string = "mnt"
+	defaultVolumesPath   string = "volumes"
+	namedVolumePrefix    string = "volume"
 )
 
 type workSpace struct {
@@ -167,7 +169,7 @@ func (workSpaceInfo *workSpace) delWorkSpace() error {
 
 func getWorkSpackInfoByContainerInfos(info *ContainerInfos) workSpace {
 	workSpaceInfo := workSpace{}
-	workSpaceInfo.readonlyLayer = path.Join(root, defaultReadonlyLayer)
+	workSpaceInfo.readonlyLayer = path.Join(root, defaultReadonlyLayer, info.Image)
 	workSpaceInfo.wirteLayer = path.Join(root, defaultRoot, info.Name, defaultWirteLayer)
 	workSpaceInfo.workLayer = path.Join(root, defaultRoot, info.Name, defaultWorkLayer)
 	workSpaceInfo.mountRoot = getMountRootPathByContainerName(info.Name)
@@ -183,6 +185,10 @@ func volumeUrlExtract(volumeUrl []string) [][]string {
 	res := [][]string{}
 	for _, item := range volumeUrl {
 		tmp := strings.Split(item, ":")
+		// volume:name:/data 为命名卷 保存在root/volumes下 其他的来源路径按原样挂载
+		if len(tmp) == 3 && tmp[0] == namedVolumePrefix && tmp[1] != "" {
+			tmp = []string{getVolumePathByName(tmp[1]), tmp[2]}
+		}
 		if len(tmp) != 2 {
 			slog.Error("error volume format")
 			continue
@@ -205,3 +211,7 @@ func createLayer(root string) error {
 func getMountRootPathByContainerName(name string) string {
 	return path.Join(root, defaultRoot, name, defaultMntRoot)
 }
+
+func getVolumePathByName(name string) string {
+	return path.Join(root, defaultVolumesPath, name)
+}
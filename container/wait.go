@@ -0,0 +1,49 @@
+package container
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	waitInterval time.Duration = 200 * time.Millisecond
+	// 进程已经退出但状态还没有更新时 留给回收进程的程序记录退出码的时间
+	waitReapGrace time.Duration = time.Second
+)
+
+// 等待容器退出 返回退出码 还没有启动过的容器会一直等到它启动后退出
+func WaitContainer(ctx context.Context, ref string) (int, error) {
+	name, err := ResolveContainerName(ref)
+	if err != nil {
+		return 0, err
+	}
+	var deadSince time.Time
+	for {
+		info := ContainerInfos{}
+		if err := GetInfoByContainerName(name, &info); err != nil {
+			return 0, errors.Wrapf(err, "container %s was removed", name)
+		}
+		switch {
+		case info.Status != Running && info.Status != Created:
+			return info.ExitCode, nil
+		case info.Status == Running && !isContainerAlive(&info):
+			if deadSince.IsZero() {
+				deadSince = time.Now()
+			} else if time.Since(deadSince) > waitReapGrace {
+				// 进程不是当前程序回收的 拿不到退出码
+				if err := MarkContainerExited(info.Name, info.Pid, -1); err != nil {
+					return 0, err
+				}
+			}
+		default:
+			deadSince = time.Time{}
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(waitInterval):
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// 通过unix socket访问daemon的客户端
+type Client struct {
+	http *http.Client
+}
+
+// host为 unix:///run/mydocker.sock 或者socket文件路径
+func NewClient(host string) (*Client, error) {
+	socket := strings.TrimPrefix(host, "unix://")
+	if socket == "" || strings.Contains(socket, "://") {
+		return nil, fmt.Errorf("unsupported daemon host %s (expected unix://<socket>)", host)
+	}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}
+	return &Client{http: &http.Client{Transport: transport}}, nil
+}
+
+// 发送请求 状态码不是2xx或304时把错误响应转换成error 调用者负责关闭body
+func (t *Client) do(method string, path string, query url.Values, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	u := url.URL{Scheme: "http", Host: "mydocker", Path: "/v" + ApiVersion + path, RawQuery: query.Encode()}
+	req, err := http.NewRequest(method, u.String(), reader)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := t.http.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to connect to daemon")
+	}
+	if resp.StatusCode/100 == 2 || resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	errResp := &ErrorResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(errResp); err != nil || errResp.Message == "" {
+		return nil, fmt.Errorf("daemon returned %s", resp.Status)
+	}
+	return nil, fmt.Errorf("%s", errResp.Message)
+}
+
+// 发送请求并把响应解析到v中 v为nil时丢弃响应
+func (t *Client) call(method string, path string, query url.Values, body any, v any) error {
+	resp, err := t.do(method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if v == nil || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	return errors.WithStack(json.NewDecoder(resp.Body).Decode(v))
+}
+
+func (t *Client) Ping() error {
+	return t.call(http.MethodGet, "/_ping", nil, nil, nil)
+}
+
+func (t *Client) ContainerList(all bool) ([]ContainerSummary, error) {
+	query := url.Values{}
+	if all {
+		query.Set("all", "1")
+	}
+	res := []ContainerSummary{}
+	if err := t.call(http.MethodGet, "/containers/json", query, nil, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *Client) ContainerCreate(name string, config *ContainerCreateConfig) (*ContainerCreateResponse, error) {
+	query := url.Values{}
+	if name != "" {
+		query.Set("name", name)
+	}
+	res := &ContainerCreateResponse{}
+	if err := t.call(http.MethodPost, "/containers/create", query, config, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *Client) ContainerInspect(ref string) (*ContainerJSON, error) {
+	res := &ContainerJSON{}
+	if err := t.call(http.MethodGet, "/containers/"+url.PathEscape(ref)+"/json", nil, nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *Client) ContainerStart(ref string) error {
+	return t.call(http.MethodPost, "/containers/"+url.PathEscape(ref)+"/start", nil, nil, nil)
+}
+
+func (t *Client) ContainerStop(ref string) error {
+	return t.call(http.MethodPost, "/containers/"+url.PathEscape(ref)+"/stop", nil, nil, nil)
+}
+
+func (t *Client) ContainerRemove(ref string, force bool) error {
+	query := url.Values{}
+	if force {
+		query.Set("force", "1")
+	}
+	return t.call(http.MethodDelete, "/containers/"+url.PathEscape(ref), query, nil, nil)
+}
+
+func (t *Client) ContainerWait(ref string) (int, error) {
+	res := &ContainerWaitResponse{}
+	if err := t.call(http.MethodPost, "/containers/"+url.PathEscape(ref)+"/wait", nil, nil, res); err != nil {
+		return 0, err
+	}
+	return res.StatusCode, nil
+}
+
+// query为docker日志接口的参数 如follow tail timestamps stdout stderr
+func (t *Client) ContainerLogs(ref string, query url.Values, stdout io.Writer, stderr io.Writer) error {
+	resp, err := t.do(http.MethodGet, "/containers/"+url.PathEscape(ref)+"/logs", query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return stdCopy(stdout, stderr, resp.Body)
+}
+
+func (t *Client) ImageList() ([]ImageSummary, error) {
+	res := []ImageSummary{}
+	if err := t.call(http.MethodGet, "/images/json", nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *Client) ImageRemove(name string) error {
+	return t.call(http.MethodDelete, "/images/"+url.PathEscape(name), nil, nil, nil)
+}
+
+func (t *Client) NetworkList() ([]NetworkResource, error) {
+	res := []NetworkResource{}
+	if err := t.call(http.MethodGet, "/networks", nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *Client) NetworkCreate(req *NetworkCreateRequest) (*NetworkCreateResponse, error) {
+	res := &NetworkCreateResponse{}
+	if err := t.call(http.MethodPost, "/networks/create", nil, req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *Client) NetworkRemove(ref string) error {
+	return t.call(http.MethodDelete, "/networks/"+url.PathEscape(ref), nil, nil, nil)
+}
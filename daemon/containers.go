@@ -0,0 +1,357 @@
+package daemon
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kehaha-5/go-low-level-container/cgroups"
+	"github.com/kehaha-5/go-low-level-container/cgroups/limit"
+	"github.com/kehaha-5/go-low-level-container/container"
+	"github.com/kehaha-5/go-low-level-container/network"
+)
+
+// 把创建请求转换成run的参数 只支持后台运行的容器
+func (t *ContainerCreateConfig) runArgs(name string) (*container.RunCommandArgs, error) {
+	if t.Image == "" {
+		return nil, fmt.Errorf("missing image")
+	}
+	if len(t.Cmd) == 0 {
+		return nil, fmt.Errorf("missing command")
+	}
+	if t.Tty {
+		return nil, fmt.Errorf("tty is not supported")
+	}
+	res := &limit.ResourceConfig{
+		Cpu:       t.HostConfig.CpuShares,
+		PidsLimit: t.HostConfig.PidsLimit,
+	}
+	if t.HostConfig.Memory > 0 {
+		res.Memory = strconv.FormatInt(t.HostConfig.Memory, 10)
+	}
+	if t.HostConfig.NanoCpus > 0 {
+		res.Cpus = strconv.FormatFloat(float64(t.HostConfig.NanoCpus)/1e9, 'f', -1, 64)
+	}
+
+	ports := []string{}
+	for key, bindings := range t.HostConfig.PortBindings {
+		port, proto, _ := strings.Cut(key, "/")
+		if proto != "" && proto != "tcp" {
+			return nil, fmt.Errorf("unsupported port protocol %s", proto)
+		}
+		for _, binding := range bindings {
+			if binding.HostPort == "" {
+				return nil, fmt.Errorf("missing host port of %s", key)
+			}
+			ports = append(ports, binding.HostPort+":"+port)
+		}
+	}
+	sort.Strings(ports)
+
+	networkMode := t.HostConfig.NetworkMode
+	if networkMode == "default" {
+		networkMode = ""
+	}
+	netMode, netName := container.ParseNetMode(networkMode)
+	if len(ports) != 0 && netName == "" {
+		return nil, fmt.Errorf("port mapping requires a network")
+	}
+
+	restart := container.RestartPolicy{
+		Name:              t.HostConfig.RestartPolicy.Name,
+		MaximumRetryCount: t.HostConfig.RestartPolicy.MaximumRetryCount,
+	}
+	if err := restart.Validate(); err != nil {
+		return nil, err
+	}
+
+	labels := make([]string, 0, len(t.Labels))
+	for k, v := range t.Labels {
+		labels = append(labels, k+"="+v)
+	}
+	sort.Strings(labels)
+
+	logConfig, err := container.NewLogConfig("json-file", nil)
+	if err != nil {
+		return nil, err
+	}
+	cgroupParent := t.HostConfig.CgroupParent
+	if cgroupParent == "" {
+		cgroupParent = cgroups.DefaultCgroupParent
+	}
+	return &container.RunCommandArgs{
+		VolumeArg:     t.HostConfig.Binds,
+		LimitResConf:  res,
+		CommandArgs:   t.Cmd,
+		Detach:        true,
+		ContainerName: name,
+		ImageName:     t.Image,
+		EnvList:       t.Env,
+		Net:           netName,
+		PortMapping:   strings.Join(ports, " "),
+		Labels:        labels,
+		LogConfig:     logConfig,
+		CgroupParent:  cgroupParent,
+		Restart:       restart,
+		Namespaces:    container.NamespaceModes{Net: netMode},
+	}, nil
+}
+
+func unixTime(value string) int64 {
+	created, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0
+	}
+	return created.Unix()
+}
+
+func containerStatus(info *container.ContainerInfos) string {
+	switch info.Status {
+	case container.Running:
+		return "Up"
+	case container.Created:
+		return "Created"
+	case container.Stop:
+		return "Stopped"
+	}
+	return fmt.Sprintf("Exited (%d)", info.ExitCode)
+}
+
+func containerSummary(info *container.ContainerInfos) ContainerSummary {
+	return ContainerSummary{
+		Id:      info.Id,
+		Names:   []string{"/" + info.Name},
+		Image:   info.Image,
+		Command: info.Command,
+		Created: unixTime(info.CreateTime),
+		State:   info.Status,
+		Status:  containerStatus(info),
+		Labels:  info.Labels,
+	}
+}
+
+func containerJSON(info *container.ContainerInfos) *ContainerJSON {
+	args := strings.Fields(info.Command)
+	res := &ContainerJSON{
+		Id:      info.Id,
+		Name:    "/" + info.Name,
+		Created: info.CreateTime,
+		State: ContainerState{
+			Status:    info.Status,
+			Running:   info.IsAlive(),
+			ExitCode:  info.ExitCode,
+			OOMKilled: info.OOMKilled,
+		},
+		Image:        info.Image,
+		RestartCount: info.Restarts,
+		Config: ContainerConfig{
+			Image:  info.Image,
+			Cmd:    args,
+			Env:    info.Env,
+			Labels: info.Labels,
+		},
+		HostConfig: HostConfig{
+			Binds:        info.Volume,
+			NetworkMode:  info.Namespaces.Net,
+			PortBindings: map[string][]PortBinding{},
+			RestartPolicy: RestartPolicy{
+				Name:              info.Restart.String(),
+				MaximumRetryCount: info.Restart.MaximumRetryCount,
+			},
+			CgroupParent: info.CgParent,
+		},
+		NetworkSettings: NetworkSettings{Networks: map[string]EndpointSettings{}},
+	}
+	if len(args) != 0 {
+		res.Path = args[0]
+		res.Args = args[1:]
+	}
+	if res.State.Running {
+		res.State.Pid, _ = strconv.Atoi(info.Pid)
+	}
+	for _, mapping := range info.PortMapping {
+		host, port, _ := strings.Cut(mapping, ":")
+		res.HostConfig.PortBindings[port+"/tcp"] = append(res.HostConfig.PortBindings[port+"/tcp"], PortBinding{HostPort: host})
+	}
+	if ep := info.IpInfo; ep.Network != nil {
+		res.HostConfig.NetworkMode = ep.Network.Name
+		res.NetworkSettings.IPAddress = ep.IPAddress.String()
+		res.NetworkSettings.Networks[ep.Network.Name] = EndpointSettings{
+			NetworkID:  ep.Network.Id,
+			IPAddress:  ep.IPAddress.String(),
+			MacAddress: ep.MacAddress.String(),
+		}
+	}
+	return res
+}
+
+func resolveContainer(r *http.Request) (*container.ContainerInfos, error) {
+	name, err := container.ResolveContainerName(r.PathValue("id"))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	info := &container.ContainerInfos{}
+	if err := container.GetInfoByContainerName(name, info); err != nil {
+		return nil, notFound(err)
+	}
+	return info, nil
+}
+
+func (t *Daemon) containerList(w http.ResponseWriter, r *http.Request) error {
+	infos, err := container.ListContainerInfos()
+	if err != nil {
+		return err
+	}
+	all := queryBool(r, "all")
+	res := []ContainerSummary{}
+	for i := range infos {
+		if !all && infos[i].Status != container.Running {
+			continue
+		}
+		res = append(res, containerSummary(&infos[i]))
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Created > res[j].Created })
+	return writeJSON(w, http.StatusOK, res)
+}
+
+func (t *Daemon) containerCreate(w http.ResponseWriter, r *http.Request) error {
+	config := &ContainerCreateConfig{}
+	if err := readJSON(r, config); err != nil {
+		return err
+	}
+	name := r.URL.Query().Get("name")
+	args, err := config.runArgs(name)
+	if err != nil {
+		return badRequest(err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if name != "" {
+		if err := container.GetInfoByContainerName(name, &container.ContainerInfos{}); err == nil {
+			return conflict(fmt.Errorf("the container name %s is already in use", name))
+		}
+	}
+	if args.Net != "" {
+		if err := network.Init(); err != nil {
+			return err
+		}
+		if !network.Exist(args.Net) {
+			return notFound(fmt.Errorf("network %s not found", args.Net))
+		}
+	}
+	info, err := container.CreateContainer(args)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, &ContainerCreateResponse{Id: info.Id, Warnings: []string{}})
+}
+
+func (t *Daemon) containerInspect(w http.ResponseWriter, r *http.Request) error {
+	info, err := resolveContainer(r)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, containerJSON(info))
+}
+
+func (t *Daemon) containerStart(w http.ResponseWriter, r *http.Request) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	info, err := resolveContainer(r)
+	if err != nil {
+		return err
+	}
+	if info.ProcessAlive() {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	if err := t.startContainer(info.Name); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (t *Daemon) containerStop(w http.ResponseWriter, r *http.Request) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	info, err := resolveContainer(r)
+	if err != nil {
+		return err
+	}
+	// 状态被改写但进程还在时也要停止
+	if info.Status != container.Running && !info.ProcessAlive() {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	if err := container.StopContainerByName(info.Name); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (t *Daemon) containerRemove(w http.ResponseWriter, r *http.Request) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	info, err := resolveContainer(r)
+	if err != nil {
+		return err
+	}
+	force := queryBool(r, "force")
+	if info.Status == container.Running && !force {
+		return conflict(fmt.Errorf("container %s is running, stop it first or use force", info.Name))
+	}
+	if err := container.Rm(info.Name, force); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (t *Daemon) containerWait(w http.ResponseWriter, r *http.Request) error {
+	info, err := resolveContainer(r)
+	if err != nil {
+		return err
+	}
+	exitCode, err := container.WaitContainer(r.Context(), info.Name)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, &ContainerWaitResponse{StatusCode: exitCode})
+}
+
+// 日志按docker的格式把stdout和stderr复用到响应中
+func (t *Daemon) containerLogs(w http.ResponseWriter, r *http.Request) error {
+	info, err := resolveContainer(r)
+	if err != nil {
+		return err
+	}
+	query := r.URL.Query()
+	stdout, stderr := queryBool(r, "stdout"), queryBool(r, "stderr")
+	if !stdout && !stderr {
+		return badRequest(fmt.Errorf("you must choose at least one stream"))
+	}
+	tail := query.Get("tail")
+	if tail == "" {
+		tail = "all"
+	}
+	// 两个都要时不需要过滤
+	opts, err := container.NewLogsOptions(queryBool(r, "follow"), tail, query.Get("since"), query.Get("until"), queryBool(r, "timestamps"), stdout && !stderr, stderr && !stdout)
+	if err != nil {
+		return badRequest(err)
+	}
+	w.Header().Set("Content-Type", "application/vnd.docker.raw-stream")
+	w.WriteHeader(http.StatusOK)
+	outW, errW := newStdWriters(w)
+	// 响应头已经发送 之后的错误只能记录
+	if err := container.WriteContainerLogs(info.Name, opts, outW, errW); err != nil {
+		slog.Error("api", "method", r.Method, "path", r.URL.Path, "err", err)
+	}
+	return nil
+}
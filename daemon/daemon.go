@@ -0,0 +1,233 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kehaha-5/go-low-level-container/container"
+	"github.com/kehaha-5/go-low-level-container/store"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	DefaultSocket string = "/run/mydocker.sock"
+	ApiVersion    string = "1.41"
+	// shim进程执行的命令
+	ShimCommand string = "shim"
+
+	superviseInterval time.Duration = 2 * time.Second
+	shutdownTimeout   time.Duration = 5 * time.Second
+)
+
+// 常驻的守护进程 通过unix socket提供docker engine api的子集
+// 容器由shim进程启动和回收 daemon定期按重启策略重启退出的容器
+type Daemon struct {
+	// container和network包中的操作不是并发安全的 修改状态的请求串行执行
+	mu sync.Mutex
+	// 正在运行的shim 容器名称 => shim进程
+	shims   map[string]*exec.Cmd
+	shimsMu sync.Mutex
+}
+
+func Run(socket string) error {
+	// 能连接上说明已经有daemon在运行 否则是上次异常退出留下的socket文件
+	if conn, err := net.Dial("unix", socket); err == nil {
+		conn.Close()
+		return fmt.Errorf("daemon is already running on %s", socket)
+	}
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+	// 启动时迁移保存的数据 并修复宿主机重启等原因导致的不一致
+	if err := store.Migrate(); err != nil {
+		return err
+	}
+	if _, err := container.Reconcile(); err != nil {
+		slog.Error("reconcile", "err", err)
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return errors.Wrapf(err, "fail to listen on %s", socket)
+	}
+	defer os.Remove(socket)
+	if err := os.Chmod(socket, 0660); err != nil {
+		return errors.WithStack(err)
+	}
+
+	d := &Daemon{shims: map[string]*exec.Cmd{}}
+	server := &http.Server{Handler: d.routes()}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go d.supervise(ctx)
+	go func() {
+		<-ctx.Done()
+		slog.Info("daemon", "msg", "shutting down")
+		// 跟踪日志和等待容器的请求可能一直不结束 超时后直接关闭
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			server.Close()
+		}
+	}()
+
+	slog.Info("daemon", "listen", socket)
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// 定期把进程已经不存在的容器标记为退出 再按重启策略重启
+// 有shim的容器由shim记录退出码
+func (t *Daemon) supervise(ctx context.Context) {
+	for {
+		t.mu.Lock()
+		if err := t.reconcile(); err != nil {
+			slog.Error("supervise", "err", err)
+		}
+		t.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(superviseInterval):
+		}
+	}
+}
+
+func (t *Daemon) reconcile() error {
+	infos, err := container.ListContainerInfos()
+	if err != nil {
+		return err
+	}
+	for i := range infos {
+		info := &infos[i]
+		// 不是由shim启动的容器只要进程还在就不修改状态
+		if info.Status != container.Running || info.ProcessAlive() || t.hasShim(info.Name) {
+			continue
+		}
+		if err := container.MarkContainerExited(info.Name, info.Pid, -1); err != nil {
+			return err
+		}
+	}
+	if err := container.CheckPidsLimits(); err != nil {
+		return err
+	}
+	_, err = container.ApplyRestartPolicies(t.startContainer)
+	return err
+}
+
+func (t *Daemon) hasShim(name string) bool {
+	t.shimsMu.Lock()
+	defer t.shimsMu.Unlock()
+	_, ok := t.shims[name]
+	return ok
+}
+
+// shim通过fd 3返回的启动结果
+type shimResult struct {
+	Error string `json:"error"`
+}
+
+// 通过shim启动容器 容器启动完成后返回 shim继续等待容器退出
+func (t *Daemon) startContainer(name string) error {
+	if t.hasShim(name) {
+		return fmt.Errorf("container %s is already running", name)
+	}
+	resultR, resultW, err := os.Pipe()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resultR.Close()
+	cmd := exec.Command("/proc/self/exe", ShimCommand, name)
+	cmd.ExtraFiles = []*os.File{resultW}
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	// 脱离daemon的会话 daemon退出后shim继续等待容器
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		resultW.Close()
+		return errors.Wrap(err, "fail to start shim")
+	}
+	resultW.Close()
+
+	t.shimsMu.Lock()
+	t.shims[name] = cmd
+	t.shimsMu.Unlock()
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			slog.Error("shim", "name", name, "err", err)
+		}
+		t.shimsMu.Lock()
+		delete(t.shims, name)
+		t.shimsMu.Unlock()
+	}()
+
+	data, err := io.ReadAll(resultR)
+	if err != nil {
+		return errors.Wrap(err, "fail to read shim result")
+	}
+	result := shimResult{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("shim exited without result")
+	}
+	if result.Error != "" {
+		return fmt.Errorf("%s", result.Error)
+	}
+	return nil
+}
+
+// shim进程 启动容器后通过fd 3返回结果 然后等待容器退出并记录退出码
+// 容器进程是shim的子进程 logger等其他子进程在shim退出后由init回收
+func RunShim(name string) error {
+	result := os.NewFile(3, "result")
+	err := container.StartContainerByName(name)
+	res := shimResult{}
+	if err != nil {
+		res.Error = fmt.Sprintf("%+v", err)
+	}
+	if err := json.NewEncoder(result).Encode(res); err != nil {
+		slog.Error("shim", "name", name, "err", err)
+	}
+	result.Close()
+	if err != nil {
+		return err
+	}
+
+	info := container.ContainerInfos{}
+	if err := container.GetInfoByContainerName(name, &info); err != nil {
+		return errors.WithStack(err)
+	}
+	pid, err := strconv.Atoi(info.Pid)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var status unix.WaitStatus
+	for {
+		_, err = unix.Wait4(pid, &status, 0, nil)
+		if err != unix.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		return errors.Wrapf(err, "fail to wait container %s", name)
+	}
+	exitCode := status.ExitStatus()
+	if status.Signaled() {
+		exitCode = 128 + int(status.Signal())
+	}
+	slog.Info("shim", "name", name, "exitCode", exitCode)
+	return container.MarkContainerExited(name, info.Pid, exitCode)
+}
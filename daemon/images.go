@@ -0,0 +1,47 @@
+package daemon
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/kehaha-5/go-low-level-container/container"
+)
+
+// 镜像是载入的tar文件 名称去掉.tar后缀就是run使用的镜像名
+func imageName(file string) string {
+	return strings.TrimSuffix(file, ".tar")
+}
+
+func (t *Daemon) imageList(w http.ResponseWriter, r *http.Request) error {
+	images, err := container.ListImages()
+	if err != nil {
+		return err
+	}
+	res := make([]ImageSummary, 0, len(images))
+	for _, item := range images {
+		summary := ImageSummary{
+			Id:       item.ID,
+			RepoTags: []string{imageName(item.Name)},
+			Created:  unixTime(item.CreateTime),
+		}
+		if fInfo, err := os.Stat(container.GetImageFilePath(item.Name)); err == nil {
+			summary.Size = fInfo.Size()
+		}
+		res = append(res, summary)
+	}
+	return writeJSON(w, http.StatusOK, res)
+}
+
+func (t *Daemon) imageRemove(w http.ResponseWriter, r *http.Request) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	item, err := container.FindImage(r.PathValue("name"))
+	if err != nil {
+		return notFound(err)
+	}
+	if err := container.DelImageByName([]string{item.Name}); err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, []map[string]string{{"Deleted": item.ID}})
+}
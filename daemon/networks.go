@@ -0,0 +1,151 @@
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/kehaha-5/go-low-level-container/container"
+	"github.com/kehaha-5/go-low-level-container/network"
+)
+
+func networkResource(n *network.Network, infos []container.ContainerInfos) NetworkResource {
+	res := NetworkResource{
+		Name:       n.Name,
+		Id:         n.Id,
+		Created:    n.CreateTime,
+		Driver:     n.Driver,
+		IPAM:       IPAM{Driver: "default", Config: []IPAMConfig{}},
+		Labels:     n.Labels,
+		Containers: map[string]NetworkContainer{},
+	}
+	// 网段中第一个分配的地址是网关
+	if n.IpRange != nil {
+		subnet := &net.IPNet{IP: n.IpRange.IP.Mask(n.IpRange.Mask), Mask: n.IpRange.Mask}
+		res.IPAM.Config = append(res.IPAM.Config, IPAMConfig{Subnet: subnet.String(), Gateway: n.IpRange.IP.String()})
+	}
+	for _, info := range infos {
+		ep := info.IpInfo
+		if ep.Network == nil || ep.Network.Name != n.Name {
+			continue
+		}
+		ones, _ := n.IpRange.Mask.Size()
+		res.Containers[info.Id] = NetworkContainer{
+			Name:        info.Name,
+			IPv4Address: ep.IPAddress.String() + "/" + strconv.Itoa(ones),
+			MacAddress:  ep.MacAddress.String(),
+		}
+	}
+	return res
+}
+
+// 按名称或id找到网络 需要先调用network.Init
+func findNetwork(ref string) (*network.Network, error) {
+	for _, n := range network.ListNetworks() {
+		if n.Name == ref || n.Id == ref {
+			return n, nil
+		}
+	}
+	return nil, notFound(fmt.Errorf("network %s not found", ref))
+}
+
+func (t *Daemon) networkList(w http.ResponseWriter, r *http.Request) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := network.Init(); err != nil {
+		return err
+	}
+	infos, err := container.ListContainerInfos()
+	if err != nil {
+		return err
+	}
+	res := []NetworkResource{}
+	for _, n := range network.ListNetworks() {
+		res = append(res, networkResource(n, infos))
+	}
+	return writeJSON(w, http.StatusOK, res)
+}
+
+func (t *Daemon) networkInspect(w http.ResponseWriter, r *http.Request) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := network.Init(); err != nil {
+		return err
+	}
+	n, err := findNetwork(r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	infos, err := container.ListContainerInfos()
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, networkResource(n, infos))
+}
+
+func (t *Daemon) networkCreate(w http.ResponseWriter, r *http.Request) error {
+	req := &NetworkCreateRequest{}
+	if err := readJSON(r, req); err != nil {
+		return err
+	}
+	if req.Name == "" {
+		return badRequest(fmt.Errorf("missing network name"))
+	}
+	if len(req.IPAM.Config) != 1 || req.IPAM.Config[0].Subnet == "" {
+		return badRequest(fmt.Errorf("exactly one ipam config with a subnet is required"))
+	}
+	if req.Driver == "" {
+		req.Driver = "bridge"
+	}
+	labels := make([]string, 0, len(req.Labels))
+	for k, v := range req.Labels {
+		labels = append(labels, k+"="+v)
+	}
+	sort.Strings(labels)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := network.Init(); err != nil {
+		return err
+	}
+	if network.Exist(req.Name) {
+		return conflict(fmt.Errorf("network %s already exists", req.Name))
+	}
+	if err := network.CreateNetwork(req.Driver, req.IPAM.Config[0].Subnet, req.Name, labels); err != nil {
+		return err
+	}
+	if err := network.Init(); err != nil {
+		return err
+	}
+	n, err := findNetwork(req.Name)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, &NetworkCreateResponse{Id: n.Id})
+}
+
+func (t *Daemon) networkRemove(w http.ResponseWriter, r *http.Request) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := network.Init(); err != nil {
+		return err
+	}
+	n, err := findNetwork(r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	used, err := container.GetUsedNetworks()
+	if err != nil {
+		return err
+	}
+	if used[n.Name] {
+		return conflict(fmt.Errorf("network %s is used by containers", n.Name))
+	}
+	if err := network.RemoveNetwork(n.Name); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,116 @@
+package daemon
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// 客户端请求的路径可以带api版本前缀 如 /v1.41/containers/json
+var versionPrefix = regexp.MustCompile(`^/v[0-9]+(\.[0-9]+)?/`)
+
+// 需要返回特定状态码的错误 其他错误返回500
+type httpError struct {
+	code int
+	err  error
+}
+
+func (t *httpError) Error() string {
+	return t.err.Error()
+}
+
+func notFound(err error) error {
+	return &httpError{code: http.StatusNotFound, err: err}
+}
+
+func badRequest(err error) error {
+	return &httpError{code: http.StatusBadRequest, err: err}
+}
+
+func conflict(err error) error {
+	return &httpError{code: http.StatusConflict, err: err}
+}
+
+func (t *Daemon) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /_ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+	mux.HandleFunc("GET /version", t.handle(t.version))
+
+	mux.HandleFunc("GET /containers/json", t.handle(t.containerList))
+	mux.HandleFunc("POST /containers/create", t.handle(t.containerCreate))
+	mux.HandleFunc("GET /containers/{id}/json", t.handle(t.containerInspect))
+	mux.HandleFunc("POST /containers/{id}/start", t.handle(t.containerStart))
+	mux.HandleFunc("POST /containers/{id}/stop", t.handle(t.containerStop))
+	mux.HandleFunc("POST /containers/{id}/wait", t.handle(t.containerWait))
+	mux.HandleFunc("GET /containers/{id}/logs", t.handle(t.containerLogs))
+	mux.HandleFunc("DELETE /containers/{id}", t.handle(t.containerRemove))
+
+	mux.HandleFunc("GET /images/json", t.handle(t.imageList))
+	mux.HandleFunc("DELETE /images/{name}", t.handle(t.imageRemove))
+
+	mux.HandleFunc("GET /networks", t.handle(t.networkList))
+	mux.HandleFunc("GET /networks/{id}", t.handle(t.networkInspect))
+	mux.HandleFunc("POST /networks/create", t.handle(t.networkCreate))
+	mux.HandleFunc("DELETE /networks/{id}", t.handle(t.networkRemove))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if loc := versionPrefix.FindStringIndex(r.URL.Path); loc != nil {
+			r.URL.Path = r.URL.Path[loc[1]-1:]
+		}
+		w.Header().Set("Api-Version", ApiVersion)
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// 把处理函数返回的错误转换成docker api格式的错误响应
+func (t *Daemon) handle(fn func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := fn(w, r)
+		if err == nil {
+			return
+		}
+		code := http.StatusInternalServerError
+		var httpErr *httpError
+		if errors.As(err, &httpErr) {
+			code = httpErr.code
+		}
+		slog.Error("api", "method", r.Method, "path", r.URL.Path, "code", code, "err", err)
+		writeJSON(w, code, &ErrorResponse{Message: err.Error()})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	return errors.WithStack(json.NewEncoder(w).Encode(v))
+}
+
+func readJSON(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return badRequest(errors.Wrap(err, "invalid request body"))
+	}
+	return nil
+}
+
+// docker api的布尔参数可以是 1 true True
+func queryBool(r *http.Request, key string) bool {
+	value := r.URL.Query().Get(key)
+	return value == "1" || strings.EqualFold(value, "true")
+}
+
+func (t *Daemon) version(w http.ResponseWriter, r *http.Request) error {
+	return writeJSON(w, http.StatusOK, &VersionResponse{
+		Version:    ApiVersion,
+		ApiVersion: ApiVersion,
+		GoVersion:  runtime.Version(),
+		Os:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+	})
+}
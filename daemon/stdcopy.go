@@ -0,0 +1,74 @@
+package daemon
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// 日志接口和docker一样把stdout stderr复用到一个流中
+// 每一帧是8字节的头加数据 头的第一个字节为流的类型 后4个字节为大端序的数据长度
+const (
+	stdoutStream byte = 1
+	stderrStream byte = 2
+
+	stdcopyHeaderLen int = 8
+)
+
+type stdWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	stream byte
+}
+
+// 写入同一个响应的两个流共用一个锁 每次写入后立即发送给客户端
+func newStdWriters(w io.Writer) (stdout io.Writer, stderr io.Writer) {
+	mu := &sync.Mutex{}
+	return &stdWriter{mu: mu, w: w, stream: stdoutStream}, &stdWriter{mu: mu, w: w, stream: stderrStream}
+}
+
+func (t *stdWriter) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	header := make([]byte, stdcopyHeaderLen)
+	header[0] = t.stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(p)))
+	if _, err := t.w.Write(header); err != nil {
+		return 0, err
+	}
+	n, err := t.w.Write(p)
+	if flusher, ok := t.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
+
+// 把复用的流拆分写入stdout和stderr
+func stdCopy(stdout io.Writer, stderr io.Writer, src io.Reader) error {
+	header := make([]byte, stdcopyHeaderLen)
+	for {
+		if _, err := io.ReadFull(src, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return errors.Wrap(err, "fail to read log frame header")
+		}
+		var dst io.Writer
+		switch header[0] {
+		case stdoutStream:
+			dst = stdout
+		case stderrStream:
+			dst = stderr
+		default:
+			return fmt.Errorf("unknown log stream %d", header[0])
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(dst, src, size); err != nil {
+			return errors.Wrap(err, "fail to read log frame")
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package daemon
+
+// 和docker engine api一致的请求和响应结构 只包含支持的字段
+
+type ErrorResponse struct {
+	Message string `json:"message"`
+}
+
+type VersionResponse struct {
+	Version    string
+	ApiVersion string
+	GoVersion  string
+	Os         string
+	Arch       string
+}
+
+type RestartPolicy struct {
+	Name              string
+	MaximumRetryCount int
+}
+
+type PortBinding struct {
+	HostIp   string
+	HostPort string
+}
+
+type HostConfig struct {
+	Binds         []string
+	NetworkMode   string
+	PortBindings  map[string][]PortBinding // 容器端口/tcp => 宿主机端口
+	RestartPolicy RestartPolicy
+	Memory        int64 // 字节
+	NanoCpus      int64 // 10^-9 个cpu
+	CpuShares     int
+	PidsLimit     int64
+	CgroupParent  string
+}
+
+// POST /containers/create 的请求体
+type ContainerCreateConfig struct {
+	Image      string
+	Cmd        []string
+	Env        []string
+	Labels     map[string]string
+	Tty        bool
+	HostConfig HostConfig
+}
+
+type ContainerCreateResponse struct {
+	Id       string
+	Warnings []string
+}
+
+type ContainerWaitResponse struct {
+	StatusCode int
+}
+
+// GET /containers/json 中的一项
+type ContainerSummary struct {
+	Id      string
+	Names   []string
+	Image   string
+	Command string
+	Created int64
+	State   string
+	Status  string
+	Labels  map[string]string
+}
+
+type ContainerState struct {
+	Status    string
+	Running   bool
+	Pid       int
+	ExitCode  int
+	OOMKilled bool
+}
+
+type ContainerConfig struct {
+	Image  string
+	Cmd    []string
+	Env    []string
+	Labels map[string]string
+}
+
+type EndpointSettings struct {
+	NetworkID  string
+	IPAddress  string
+	MacAddress string
+}
+
+type NetworkSettings struct {
+	IPAddress string
+	Networks  map[string]EndpointSettings
+}
+
+// GET /containers/{id}/json 的响应
+type ContainerJSON struct {
+	Id              string
+	Name            string
+	Created         string
+	Path            string
+	Args            []string
+	State           ContainerState
+	Image           string
+	RestartCount    int
+	Config          ContainerConfig
+	HostConfig      HostConfig
+	NetworkSettings NetworkSettings
+}
+
+type ImageSummary struct {
+	Id       string
+	RepoTags []string
+	Created  int64
+	Size     int64
+}
+
+type IPAMConfig struct {
+	Subnet  string
+	Gateway string `json:",omitempty"`
+}
+
+type IPAM struct {
+	Driver string
+	Config []IPAMConfig
+}
+
+type NetworkContainer struct {
+	Name        string
+	IPv4Address string
+	MacAddress  string
+}
+
+type NetworkResource struct {
+	Name       string
+	Id         string
+	Created    string
+	Driver     string
+	IPAM       IPAM
+	Labels     map[string]string
+	Containers map[string]NetworkContainer
+}
+
+// POST /networks/create 的请求体
+type NetworkCreateRequest struct {
+	Name   string
+	Driver string
+	IPAM   IPAM
+	Labels map[string]string
+}
+
+type NetworkCreateResponse struct {
+	Id      string
+	Warning string
+}
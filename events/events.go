@@ -0,0 +1,242 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/pkg/errors"
+)
+
+// 事件的对象类型
+const (
+	Container string = "container"
+	Network   string = "network"
+	Image     string = "image"
+	Pod       string = "pod"
+)
+
+// 事件的动作
+const (
+	ActionCreate     string = "create"
+	ActionStart      string = "start"
+	ActionRestart    string = "restart"
+	ActionDie        string = "die"
+	ActionOom        string = "oom"
+	ActionStop       string = "stop"
+	ActionKill       string = "kill"
+	ActionPause      string = "pause"
+	ActionUnpause    string = "unpause"
+	ActionDestroy    string = "destroy"
+	ActionConnect    string = "connect"
+	ActionDisconnect string = "disconnect"
+	ActionLoad       string = "load"
+	ActionDelete     string = "delete"
+	ActionCheckpoint string = "checkpoint"
+	ActionRestore    string = "restore"
+	ActionPidsLimit  string = "pids-limit"
+)
+
+const (
+	defaultEventsFilename = "events.jsonl"
+	followInterval        = 200 * time.Millisecond
+)
+
+var (
+	defaultEventsPath string = common.ROOTPATH + "/events/"
+)
+
+type Event struct {
+	Type       string            `json:"type"`
+	Action     string            `json:"action"`
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	TimeNano   int64             `json:"timeNano"`
+}
+
+func getJournalPath() string {
+	return path.Join(defaultEventsPath, defaultEventsFilename)
+}
+
+// 追加一条事件到journal中 事件只用于监控 写入失败不影响调用方
+func Emit(eventType string, action string, id string, name string, attrs map[string]string) {
+	event := &Event{
+		Type:       eventType,
+		Action:     action,
+		ID:         id,
+		Name:       name,
+		Attributes: attrs,
+		TimeNano:   time.Now().UnixNano(),
+	}
+	if err := appendEvent(event); err != nil {
+		slog.Error("emit event", "type", eventType, "action", action, "err", err)
+	}
+}
+
+func appendEvent(event *Event) error {
+	if err := os.MkdirAll(defaultEventsPath, 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	jsonStr, err := json.Marshal(event)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// O_APPEND 保证多个进程同时写入时每行是完整的
+	f, err := os.OpenFile(getJournalPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+	_, err = f.Write(append(jsonStr, '\n'))
+	return errors.WithStack(err)
+}
+
+// events命令的过滤条件
+type Filter struct {
+	Since  time.Time
+	Until  time.Time
+	Follow bool
+	// type=container event=start container=name network=name image=name
+	Conditions map[string][]string
+}
+
+func ParseFilter(since string, until string, follow bool, filters []string) (*Filter, error) {
+	f := &Filter{Follow: follow, Conditions: map[string][]string{}}
+	var err error
+	if since != "" {
+		if f.Since, err = common.ParseTime(since); err != nil {
+			return nil, err
+		}
+	}
+	if until != "" {
+		if f.Until, err = common.ParseTime(until); err != nil {
+			return nil, err
+		}
+	}
+	for _, item := range filters {
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("bad format of filter %s (expected name=value)", item)
+		}
+		switch key {
+		case "type", "event", "container", "network", "image", "pod":
+			f.Conditions[key] = append(f.Conditions[key], value)
+		default:
+			return nil, fmt.Errorf("invalid filter %s", key)
+		}
+	}
+	return f, nil
+}
+
+func (t *Filter) match(event *Event) bool {
+	eventTime := time.Unix(0, event.TimeNano)
+	if !t.Since.IsZero() && eventTime.Before(t.Since) {
+		return false
+	}
+	if !t.Until.IsZero() && eventTime.After(t.Until) {
+		return false
+	}
+	for key, values := range t.Conditions {
+		matched := false
+		for _, value := range values {
+			switch key {
+			case "type":
+				matched = event.Type == value
+			case "event":
+				matched = event.Action == value
+			default:
+				// container network image pod 按对象名称或者id匹配
+				matched = event.Type == key && (event.Name == value || event.ID == value)
+			}
+			if matched {
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// 按行读取journal并输出匹配的事件 follow模式下持续等待新的事件
+func WriteEvents(w io.Writer, filter *Filter) error {
+	if err := os.MkdirAll(defaultEventsPath, 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	f, err := os.OpenFile(getJournalPath(), os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+	// follow模式没有指定since时只输出新的事件
+	if filter.Follow && filter.Since.IsZero() {
+		if _, err := f.Seek(0, io.SeekEnd); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	reader := bufio.NewReader(f)
+	var partial []byte
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// 没有换行的是还没写完的事件 等待下次读取
+			partial = append(partial, line...)
+			if !filter.Follow || (!filter.Until.IsZero() && time.Now().After(filter.Until)) {
+				return nil
+			}
+			time.Sleep(followInterval)
+			continue
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		line = append(partial, line...)
+		partial = nil
+
+		event := &Event{}
+		if err := json.Unmarshal(line, event); err != nil {
+			slog.Error("events", "bad event", string(line))
+			continue
+		}
+		if !filter.Until.IsZero() && time.Unix(0, event.TimeNano).After(filter.Until) && !filter.Follow {
+			return nil
+		}
+		if filter.match(event) {
+			event.writeTo(w)
+		}
+	}
+}
+
+func (t *Event) writeTo(w io.Writer) {
+	attrs := []string{}
+	if t.Name != "" {
+		attrs = append(attrs, "name="+t.Name)
+	}
+	keys := make([]string, 0, len(t.Attributes))
+	for k := range t.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attrs = append(attrs, k+"="+t.Attributes[k])
+	}
+	fmt.Fprintf(
+		w, "%s %s %s %s (%s)\n",
+		time.Unix(0, t.TimeNano).Format(time.RFC3339Nano),
+		t.Type,
+		t.Action,
+		t.ID,
+		strings.Join(attrs, ", "),
+	)
+}
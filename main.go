@@ -4,36 +4,68 @@ import (
 	"log/slog"
 	"os"
 
+	"github.com/kehaha-5/go-low-level-container/mydocker"
 	"github.com/urfave/cli"
 )
 
 func main() {
+	slog.SetLogLoggerLevel(slog.LevelDebug)
+	// 容器的init logger和pod的infra进程都是重新执行当前程序启动的
+	if ok, err := mydocker.Reexec(); ok {
+		if err != nil {
+			slog.Error(err.Error())
+		}
+		return
+	}
 
 	app := cli.NewApp()
 	app.Name = "simple-docker"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:   "host,H",
+			Usage:  "daemon socket to connect to (e.g. unix:///run/mydocker.sock)",
+			EnvVar: "MYDOCKER_HOST",
+		},
+	}
 
 	app.Commands = []cli.Command{
 		RunCmd,
-		InitCmd,
+		ShimCmd,
 		listContainer,
+		statsCmd,
 		logsContainer,
 		execContainer,
+		replayCmd,
 		stopContainer,
 		rmContainer,
 		commitContainer,
 		networkCmd,
+		podCmd,
+		projectCmd,
 		startCmd,
 		restartCmd,
 		loadCmd,
 		imagesCmd,
+		updateCmd,
+		cgroupCmd,
+		containerCmd,
+		volumeCmd,
+		systemCmd,
+		eventsCmd,
+		checkpointCmd,
+		daemonCmd,
+		waitCmd,
 	}
 
+	// 保存的数据在第一个修改状态的操作前才迁移和修正 只读的命令和--help不会修改
 	app.Before = func(context *cli.Context) error {
-		slog.SetLogLoggerLevel(slog.LevelDebug)
-		return nil
+		var err error
+		rt, err = mydocker.New()
+		return err
 	}
 	if err := app.Run(os.Args); err != nil {
 		slog.Error(err.Error())
+		os.Exit(1)
 	}
 
 	// setLogConf()
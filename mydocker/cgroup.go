@@ -0,0 +1,55 @@
+package mydocker
+
+import (
+	"context"
+	"io"
+	"text/tabwriter"
+
+	"github.com/kehaha-5/go-low-level-container/container"
+)
+
+// 以表格的形式输出容器使用的父资源组
+func (t *Runtime) WriteCgroups(ctx context.Context, w io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return newError("cgroups", "", nil, err)
+	}
+	tw := tabwriter.NewWriter(w, 12, 1, 5, ' ', tabwriter.TabIndent)
+	if err := container.WirteGroupsToTabwriter(tw); err != nil {
+		return newError("cgroups", "", nil, err)
+	}
+	return newError("cgroups", "", nil, tw.Flush())
+}
+
+// 修改父资源组的限制 组中的所有容器共享这些限制 res的含义和Update一致
+func (t *Runtime) UpdateCgroup(ctx context.Context, parent string, res *Resources) error {
+	if err := t.lock(ctx, "update cgroup", parent); err != nil {
+		return err
+	}
+	defer t.unlock()
+	conf, err := res.config()
+	if err != nil {
+		return newError("update cgroup", parent, ErrInvalidArgument, err)
+	}
+	return newError("update cgroup", parent, nil, container.UpdateGroupResource(parent, conf))
+}
+
+// 以表格的形式输出父资源组中所有容器的资源使用情况
+func (t *Runtime) WriteCgroupStats(ctx context.Context, w io.Writer, parent string) error {
+	if err := ctx.Err(); err != nil {
+		return newError("cgroup stats", parent, nil, err)
+	}
+	tw := tabwriter.NewWriter(w, 12, 1, 5, ' ', tabwriter.TabIndent)
+	if err := container.WirteGroupStatsToTabwriter(tw, parent); err != nil {
+		return newError("cgroup stats", parent, nil, err)
+	}
+	return newError("cgroup stats", parent, nil, tw.Flush())
+}
+
+// 删除父资源组以及其中的子资源组和限制
+func (t *Runtime) RemoveCgroup(ctx context.Context, parent string) error {
+	if err := t.lock(ctx, "remove cgroup", parent); err != nil {
+		return err
+	}
+	defer t.unlock()
+	return newError("remove cgroup", parent, nil, container.RemoveGroup(parent))
+}
@@ -0,0 +1,62 @@
+package mydocker
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/kehaha-5/go-low-level-container/container"
+)
+
+// 用criu保存运行中容器的状态 leaveRunning为false时保存后停止容器
+func (t *Runtime) Checkpoint(ctx context.Context, ref string, checkpointId string, leaveRunning bool) error {
+	if err := t.lock(ctx, "checkpoint", ref); err != nil {
+		return err
+	}
+	defer t.unlock()
+	info, err := t.resolve("checkpoint", ref)
+	if err != nil {
+		return err
+	}
+	if !info.ProcessAlive() {
+		return newError("checkpoint", ref, ErrNotRunning, fmt.Errorf("container %s is not running", info.Name))
+	}
+	return newError("checkpoint", ref, nil, container.CheckpointContainer(info.Name, checkpointId, leaveRunning))
+}
+
+// 从保存的状态恢复已经停止的容器
+func (t *Runtime) Restore(ctx context.Context, ref string, checkpointId string) error {
+	if err := t.lock(ctx, "restore", ref); err != nil {
+		return err
+	}
+	defer t.unlock()
+	info, err := t.resolve("restore", ref)
+	if err != nil {
+		return err
+	}
+	return newError("restore", ref, nil, container.RestoreContainer(info.Name, checkpointId))
+}
+
+// 输出容器保存过的状态
+func (t *Runtime) WriteCheckpoints(ctx context.Context, w io.Writer, ref string) error {
+	if err := ctx.Err(); err != nil {
+		return newError("checkpoints", ref, nil, err)
+	}
+	info, err := t.resolve("checkpoints", ref)
+	if err != nil {
+		return err
+	}
+	return newError("checkpoints", ref, nil, container.ListCheckpoints(info.Name, w))
+}
+
+func (t *Runtime) RemoveCheckpoint(ctx context.Context, ref string, checkpointId string) error {
+	if err := t.lock(ctx, "remove checkpoint", ref); err != nil {
+		return err
+	}
+	defer t.unlock()
+	info, err := t.resolve("remove checkpoint", ref)
+	if err != nil {
+		return err
+	}
+	return newError("remove checkpoint", ref, nil, container.RemoveCheckpoint(info.Name, checkpointId))
+}
@@ -0,0 +1,339 @@
+package mydocker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/kehaha-5/go-low-level-container/container"
+	"github.com/kehaha-5/go-low-level-container/network"
+	"github.com/pkg/errors"
+)
+
+// 通过完整id 名称或者唯一的id前缀找到容器
+func (t *Runtime) resolve(op string, ref string) (*container.ContainerInfos, error) {
+	name, err := container.ResolveContainerName(ref)
+	if err != nil {
+		return nil, newError(op, ref, ErrNotFound, err)
+	}
+	info := &container.ContainerInfos{}
+	if err := container.GetInfoByContainerName(name, info); err != nil {
+		return nil, newError(op, ref, ErrNotFound, err)
+	}
+	return info, nil
+}
+
+// 检查参数以及容器依赖的镜像 网络和pod 调用者需要持有锁
+func (t *Runtime) checkCreate(op string, opts *ContainerOptions, run *RunOptions) (*container.RunCommandArgs, error) {
+	args, err := opts.runArgs(run)
+	if err != nil {
+		return nil, newError(op, opts.Name, ErrInvalidArgument, err)
+	}
+	if opts.Name != "" {
+		if err := container.GetInfoByContainerName(opts.Name, &container.ContainerInfos{}); err == nil {
+			return nil, newError(op, opts.Name, ErrConflict, fmt.Errorf("the container name %s is already in use", opts.Name))
+		}
+	}
+	if !common.FileExist(container.GetImageFilePath(opts.Image + ".tar")) {
+		return nil, newError(op, opts.Name, ErrNotFound, fmt.Errorf("no such image: %s", opts.Image))
+	}
+	if args.Net != "" {
+		if err := network.Init(); err != nil {
+			return nil, newError(op, opts.Name, nil, err)
+		}
+		if !network.Exist(args.Net) {
+			return nil, newError(op, opts.Name, ErrNotFound, fmt.Errorf("no such network: %s", args.Net))
+		}
+	}
+	if args.Pod != "" {
+		if _, err := container.GetPod(args.Pod); err != nil {
+			return nil, newError(op, opts.Name, ErrNotFound, err)
+		}
+	}
+	return args, nil
+}
+
+// 只保存容器的配置 第一次Start时才创建工作目录 网络和进程
+func (t *Runtime) Create(ctx context.Context, opts *ContainerOptions) (*Container, error) {
+	if err := t.lock(ctx, "create", opts.Name); err != nil {
+		return nil, err
+	}
+	defer t.unlock()
+	args, err := t.checkCreate("create", opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	info, err := container.CreateContainer(args)
+	if err != nil {
+		return nil, newError("create", opts.Name, nil, err)
+	}
+	return newContainer(info), nil
+}
+
+// 创建并启动容器 run为nil或者不使用tty时容器在后台运行 启动后立即返回
+// 使用tty时容器退出后才返回 期间同一个Runtime的其他修改操作会等待
+func (t *Runtime) Run(ctx context.Context, opts *ContainerOptions, run *RunOptions) (*Container, error) {
+	if err := t.lock(ctx, "run", opts.Name); err != nil {
+		return nil, err
+	}
+	defer t.unlock()
+	args, err := t.checkCreate("run", opts, run)
+	if err != nil {
+		return nil, err
+	}
+	info, err := container.RunContainer(args)
+	if err != nil {
+		return nil, newError("run", opts.Name, nil, err)
+	}
+	return newContainer(info), nil
+}
+
+// 启动创建的或者已经停止的容器 容器已经在运行时什么都不做
+func (t *Runtime) Start(ctx context.Context, ref string) error {
+	if err := t.lock(ctx, "start", ref); err != nil {
+		return err
+	}
+	defer t.unlock()
+	info, err := t.resolve("start", ref)
+	if err != nil {
+		return err
+	}
+	// 状态被改写但进程还在时也不能再启动一个init进程
+	if info.ProcessAlive() {
+		return nil
+	}
+	err = container.StartContainerByName(info.Name)
+	// 其他进程在检查之后启动了容器
+	if errors.Is(err, container.ErrContainerRunning) {
+		return newError("start", ref, ErrConflict, err)
+	}
+	return newError("start", ref, nil, err)
+}
+
+// 停止的容器不会按重启策略重启
+func (t *Runtime) Stop(ctx context.Context, ref string) error {
+	if err := t.lock(ctx, "stop", ref); err != nil {
+		return err
+	}
+	defer t.unlock()
+	info, err := t.resolve("stop", ref)
+	if err != nil {
+		return err
+	}
+	return newError("stop", ref, nil, container.StopContainerByName(info.Name))
+}
+
+func (t *Runtime) Remove(ctx context.Context, ref string, opts *RemoveOptions) error {
+	if err := t.lock(ctx, "remove", ref); err != nil {
+		return err
+	}
+	defer t.unlock()
+	info, err := t.resolve("remove", ref)
+	if err != nil {
+		return err
+	}
+	force := opts != nil && opts.Force
+	if (info.Status == container.Running || info.ProcessAlive()) && !force {
+		return newError("remove", ref, ErrConflict, fmt.Errorf("container %s is running, stop it first or use force", info.Name))
+	}
+	return newError("remove", ref, nil, container.Rm(info.Name, force))
+}
+
+// 停止后再启动容器 容器没有运行时直接启动
+func (t *Runtime) Restart(ctx context.Context, ref string) error {
+	if err := t.lock(ctx, "restart", ref); err != nil {
+		return err
+	}
+	defer t.unlock()
+	info, err := t.resolve("restart", ref)
+	if err != nil {
+		return err
+	}
+	return newError("restart", ref, nil, container.RestartContainer(info.Name))
+}
+
+// 修改容器的资源限制 res中为零值的字段保持原来的限制 -1表示取消该限制
+// 设备限制的速率为0时取消该设备的限制
+func (t *Runtime) Update(ctx context.Context, ref string, res *Resources) error {
+	if err := t.lock(ctx, "update", ref); err != nil {
+		return err
+	}
+	defer t.unlock()
+	conf, err := res.config()
+	if err != nil {
+		return newError("update", ref, ErrInvalidArgument, err)
+	}
+	info, err := t.resolve("update", ref)
+	if err != nil {
+		return err
+	}
+	return newError("update", ref, nil, container.UpdateContainerResource(info.Name, conf))
+}
+
+// 把容器的根目录打包成镜像文件 file不带.tar后缀
+func (t *Runtime) Export(ctx context.Context, ref string, file string) error {
+	if err := t.lock(ctx, "export", ref); err != nil {
+		return err
+	}
+	defer t.unlock()
+	info, err := t.resolve("export", ref)
+	if err != nil {
+		return err
+	}
+	return newError("export", ref, nil, container.ExportCommitContainer(info.Name, file))
+}
+
+// 以表格的形式输出容器的资源使用情况 refs为空时输出所有运行中的容器
+func (t *Runtime) WriteStats(ctx context.Context, w io.Writer, refs []string) error {
+	if err := ctx.Err(); err != nil {
+		return newError("stats", "", nil, err)
+	}
+	tw := tabwriter.NewWriter(w, 12, 1, 5, ' ', tabwriter.TabIndent)
+	if err := container.WirteStatsToTabwriter(tw, refs); err != nil {
+		return newError("stats", "", nil, err)
+	}
+	return newError("stats", "", nil, tw.Flush())
+}
+
+// 输出容器的日志 opts为nil时输出所有日志 Follow时等到容器退出才返回
+func (t *Runtime) Logs(ctx context.Context, ref string, opts *LogsOptions, stdout io.Writer, stderr io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return newError("logs", ref, nil, err)
+	}
+	logsOpts, err := opts.logsOptions()
+	if err != nil {
+		return newError("logs", ref, ErrInvalidArgument, err)
+	}
+	info, err := t.resolve("logs", ref)
+	if err != nil {
+		return err
+	}
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	return newError("logs", ref, nil, container.WriteContainerLogs(info.Name, logsOpts, stdout, stderr))
+}
+
+// 容器录制的会话 按录制时间排序
+func (t *Runtime) Sessions(ctx context.Context, ref string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, newError("sessions", ref, nil, err)
+	}
+	info, err := t.resolve("sessions", ref)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := container.ListSessions(info.Name)
+	if err != nil {
+		return nil, newError("sessions", ref, nil, err)
+	}
+	return sessions, nil
+}
+
+// 按录制时的节奏把会话输出到w session为空时回放最新的会话
+// speed为回放的倍速 idleLimit不为0时限制两次输出之间的最长间隔
+func (t *Runtime) Replay(ctx context.Context, ref string, session string, speed float64, idleLimit time.Duration, w io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return newError("replay", ref, nil, err)
+	}
+	info, err := t.resolve("replay", ref)
+	if err != nil {
+		return err
+	}
+	return newError("replay", ref, nil, container.Replay(info.Name, session, speed, idleLimit, w))
+}
+
+// 在运行中的容器内执行命令 返回命令的退出码 ctx结束时kill执行命令的进程
+func (t *Runtime) Exec(ctx context.Context, ref string, cmd []string, opts *ExecOptions) (int, error) {
+	if len(cmd) == 0 {
+		return 0, newError("exec", ref, ErrInvalidArgument, fmt.Errorf("missing command"))
+	}
+	if opts == nil {
+		opts = &ExecOptions{}
+	}
+	if opts.Record && !opts.Tty {
+		return 0, newError("exec", ref, ErrInvalidArgument, fmt.Errorf("record only work with tty"))
+	}
+	info, err := t.resolve("exec", ref)
+	if err != nil {
+		return 0, err
+	}
+	if !info.ProcessAlive() {
+		return 0, newError("exec", ref, ErrNotRunning, fmt.Errorf("container %s is not running", info.Name))
+	}
+	exitCode, err := container.ExecContainer(ctx, info.Name, cmd, &container.ExecConfig{
+		Tty:    opts.Tty,
+		Record: opts.Record,
+		Stdout: opts.Stdout,
+		Stderr: opts.Stderr,
+	})
+	if err != nil {
+		return 0, newError("exec", ref, nil, err)
+	}
+	return exitCode, nil
+}
+
+// 等待容器退出并返回退出码
+func (t *Runtime) Wait(ctx context.Context, ref string) (int, error) {
+	info, err := t.resolve("wait", ref)
+	if err != nil {
+		return 0, err
+	}
+	exitCode, err := container.WaitContainer(ctx, info.Name)
+	if err != nil {
+		return 0, newError("wait", ref, nil, err)
+	}
+	return exitCode, nil
+}
+
+func (t *Runtime) Inspect(ctx context.Context, ref string) (*Container, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, newError("inspect", ref, nil, err)
+	}
+	info, err := t.resolve("inspect", ref)
+	if err != nil {
+		return nil, err
+	}
+	return newContainer(info), nil
+}
+
+func (t *Runtime) List(ctx context.Context, opts *ListOptions) ([]*Container, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, newError("list", "", nil, err)
+	}
+	if opts == nil {
+		opts = &ListOptions{}
+	}
+	infos, err := container.ListContainerInfos()
+	if err != nil {
+		return nil, newError("list", "", nil, err)
+	}
+	res := []*Container{}
+	for i := range infos {
+		item := newContainer(&infos[i])
+		if !opts.All && !item.Running {
+			continue
+		}
+		if !matchLabels(item.Labels, opts.Labels) {
+			continue
+		}
+		res = append(res, item)
+	}
+	return res, nil
+}
+
+func matchLabels(labels map[string]string, want map[string]string) bool {
+	for k, v := range want {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package mydocker
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Runtime返回的错误都是*Error 可以用errors.Is判断错误的类型
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrInvalidArgument = errors.New("invalid argument")
+	ErrNotRunning      = errors.New("not running")
+)
+
+type Error struct {
+	Op   string // 出错的操作 如 create start
+	Ref  string // 操作的容器 镜像或网络
+	Kind error  // 上面的错误类型之一 无法归类时为nil
+	Err  error
+}
+
+func (t *Error) Error() string {
+	if t.Ref == "" {
+		return fmt.Sprintf("%s: %v", t.Op, t.Err)
+	}
+	return fmt.Sprintf("%s %s: %v", t.Op, t.Ref, t.Err)
+}
+
+func (t *Error) Unwrap() error {
+	return t.Err
+}
+
+func (t *Error) Is(target error) bool {
+	return t.Kind != nil && t.Kind == target
+}
+
+func newError(op string, ref string, kind error, err error) error {
+	if err == nil {
+		return nil
+	}
+	// 已经归类的错误不再重复包装
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	return &Error{Op: op, Ref: ref, Kind: kind, Err: err}
+}
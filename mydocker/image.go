@@ -0,0 +1,53 @@
+package mydocker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/kehaha-5/go-low-level-container/container"
+)
+
+// 按名称排序的所有镜像
+func (t *Runtime) Images(ctx context.Context) ([]*Image, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, newError("images", "", nil, err)
+	}
+	images, err := container.ListImages()
+	if err != nil {
+		return nil, newError("images", "", nil, err)
+	}
+	res := make([]*Image, 0, len(images))
+	for i := range images {
+		res = append(res, newImage(&images[i]))
+	}
+	return res, nil
+}
+
+// 载入导出的容器tar文件作为镜像 镜像名为去掉.tar后缀的文件名
+func (t *Runtime) LoadImage(ctx context.Context, file string) error {
+	if err := t.lock(ctx, "load", file); err != nil {
+		return err
+	}
+	defer t.unlock()
+	if !common.FileExist(file) {
+		return newError("load", file, ErrNotFound, fmt.Errorf("the image %s not existed", file))
+	}
+	return newError("load", file, nil, container.LoadImage(file))
+}
+
+// ref可以是镜像文件名 镜像名或者id
+func (t *Runtime) RemoveImage(ctx context.Context, ref string) (*Image, error) {
+	if err := t.lock(ctx, "remove image", ref); err != nil {
+		return nil, err
+	}
+	defer t.unlock()
+	item, err := container.FindImage(ref)
+	if err != nil {
+		return nil, newError("remove image", ref, ErrNotFound, err)
+	}
+	if err := container.DelImageByName([]string{item.Name}); err != nil {
+		return nil, newError("remove image", ref, nil, err)
+	}
+	return newImage(item), nil
+}
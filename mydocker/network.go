@@ -0,0 +1,102 @@
+package mydocker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/kehaha-5/go-low-level-container/container"
+	"github.com/kehaha-5/go-low-level-container/network"
+)
+
+// 按名称排序的所有网络
+func (t *Runtime) Networks(ctx context.Context) ([]*Network, error) {
+	if err := t.lock(ctx, "networks", ""); err != nil {
+		return nil, err
+	}
+	defer t.unlock()
+	if err := network.Init(); err != nil {
+		return nil, newError("networks", "", nil, err)
+	}
+	networks := network.ListNetworks()
+	res := make([]*Network, 0, len(networks))
+	for _, n := range networks {
+		res = append(res, newNetwork(n))
+	}
+	return res, nil
+}
+
+func (t *Runtime) CreateNetwork(ctx context.Context, opts *NetworkOptions) (*Network, error) {
+	if err := t.lock(ctx, "create network", opts.Name); err != nil {
+		return nil, err
+	}
+	defer t.unlock()
+	if opts.Name == "" || opts.Driver == "" || opts.Subnet == "" {
+		return nil, newError("create network", opts.Name, ErrInvalidArgument, fmt.Errorf("name driver and subnet are required"))
+	}
+	if err := network.Init(); err != nil {
+		return nil, newError("create network", opts.Name, nil, err)
+	}
+	if network.Exist(opts.Name) {
+		return nil, newError("create network", opts.Name, ErrConflict, fmt.Errorf("network name %s has existed", opts.Name))
+	}
+	if err := network.CreateNetwork(opts.Driver, opts.Subnet, opts.Name, labelArgs(opts.Labels)); err != nil {
+		return nil, newError("create network", opts.Name, nil, err)
+	}
+	// 重新加载才能拿到驱动分配的网关等信息
+	if err := network.Init(); err != nil {
+		return nil, newError("create network", opts.Name, nil, err)
+	}
+	for _, n := range network.ListNetworks() {
+		if n.Name == opts.Name {
+			return newNetwork(n), nil
+		}
+	}
+	return nil, newError("create network", opts.Name, ErrNotFound, fmt.Errorf("network %s not found after create", opts.Name))
+}
+
+// 还有容器使用的网络不能删除
+func (t *Runtime) RemoveNetwork(ctx context.Context, name string) error {
+	if err := t.lock(ctx, "remove network", name); err != nil {
+		return err
+	}
+	defer t.unlock()
+	if err := network.Init(); err != nil {
+		return newError("remove network", name, nil, err)
+	}
+	if !network.Exist(name) {
+		return newError("remove network", name, ErrNotFound, fmt.Errorf("no such network: %s", name))
+	}
+	used, err := container.GetUsedNetworks()
+	if err != nil {
+		return newError("remove network", name, nil, err)
+	}
+	if used[name] {
+		return newError("remove network", name, ErrConflict, fmt.Errorf("network %s is in use by containers", name))
+	}
+	return newError("remove network", name, nil, network.RemoveNetwork(name))
+}
+
+// 删除没有容器使用的网络 filters和prune命令的--filter一致
+func (t *Runtime) PruneNetworks(ctx context.Context, filters []string) (*PruneReport, error) {
+	if err := t.lock(ctx, "prune networks", ""); err != nil {
+		return nil, err
+	}
+	defer t.unlock()
+	filter, err := common.ParseFilters(filters)
+	if err != nil {
+		return nil, newError("prune networks", "", ErrInvalidArgument, err)
+	}
+	if err := network.Init(); err != nil {
+		return nil, newError("prune networks", "", nil, err)
+	}
+	used, err := container.GetUsedNetworks()
+	if err != nil {
+		return nil, newError("prune networks", "", nil, err)
+	}
+	deleted, err := network.PruneNetworks(filter, used)
+	if err != nil {
+		return nil, newError("prune networks", "", nil, err)
+	}
+	return &PruneReport{Deleted: deleted}, nil
+}
@@ -0,0 +1,290 @@
+package mydocker
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/kehaha-5/go-low-level-container/cgroups"
+	"github.com/kehaha-5/go-low-level-container/cgroups/limit"
+	"github.com/kehaha-5/go-low-level-container/container"
+)
+
+// 创建容器的参数 字符串格式的参数和命令行的一致
+type ContainerOptions struct {
+	Name         string
+	Image        string // 已经载入的镜像名称 不带.tar后缀
+	Cmd          []string
+	Env          []string
+	Volumes      []string // host:container
+	Network      string   // 网络名称 或者 host none container:<name>
+	Ports        []string // hostPort:containerPort
+	Labels       map[string]string
+	Resources    *Resources // 为nil时不限制
+	Restart      string     // no always unless-stopped on-failure[:max-retries] 为空时是no
+	LogDriver    string     // json-file syslog gelf none 为空时是json-file
+	LogOpts      []string
+	CgroupParent string // 为空时使用默认的父资源组 在pod中时为pod的资源组
+	OomScoreAdj  int
+	Ulimits      []string // nofile=1024:2048
+	Sysctls      []string // net.core.somaxconn=1024
+	Pid          string   // host container:<name>
+	Ipc          string   // host private shareable container:<name>
+	Uts          string   // host
+	Pod          string   // 加入pod 和pod共享网络 ipc和uts
+}
+
+// 前台运行容器的参数 只能在Run中使用
+type RunOptions struct {
+	Tty        bool // 使用伪终端接管当前终端 容器退出后才返回
+	AutoRemove bool // 容器退出后删除 需要Tty
+	Record     bool // 录制会话 需要Tty
+}
+
+// 容器的资源限制 零值表示不限制 内存大小可以带单位 如 512m 1.5g
+type Resources struct {
+	CpuShares         int
+	Cpus              string // 可使用的cpu核数 如1.5 和CpuQuota不能同时设置
+	CpuPeriod         int64  // 单位微秒
+	CpuQuota          int64  // 单位微秒
+	CpusetCpus        string // 0-3,6
+	CpusetMems        string
+	Memory            string
+	MemoryHigh        string
+	MemorySwap        string // -1表示不限制swap
+	MemoryReservation string
+	KernelMemory      string
+	OomKillDisable    bool
+	PidsLimit         int64
+	BlkioWeight       uint16
+	DeviceReadBps     []string // /dev/sda:1mb
+	DeviceWriteBps    []string
+	DeviceReadIops    []string // /dev/sda:1000
+	DeviceWriteIops   []string
+	HugepageLimits    []string // 2MB:1G
+}
+
+type ExecOptions struct {
+	Tty    bool // 使用伪终端接管当前终端 此时忽略Stdout和Stderr
+	Record bool // 录制会话 需要Tty
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// 字符串格式的参数和logs命令的一致
+type LogsOptions struct {
+	Follow     bool   // 容器退出前持续输出新的日志
+	Tail       string // 只输出最后几行 为空或者all时输出所有日志
+	Since      string // 10m 或者 RFC3339格式的时间
+	Until      string
+	Timestamps bool
+	Stdout     bool // Stdout和Stderr都为false时两个都输出
+	Stderr     bool
+}
+
+type ListOptions struct {
+	All    bool              // 包括没有运行的容器
+	Labels map[string]string // 只返回有这些标签的容器
+}
+
+type RemoveOptions struct {
+	Force bool // 先停止运行中的容器
+}
+
+type PodOptions struct {
+	Name    string
+	Network string   // 为空时不连接网络
+	Ports   []string // hostPort:containerPort
+	Labels  map[string]string
+}
+
+// 项目文件和项目名称 和project命令的-f -p一致
+type ProjectOptions struct {
+	File string // yaml或者json格式的项目文件 为空时是project.yml
+	Name string // 为空时使用文件中的名称或者所在目录的名称
+}
+
+type NetworkOptions struct {
+	Name   string
+	Driver string // 目前只支持bridge
+	Subnet string // 192.168.0.0/24
+	Labels map[string]string
+}
+
+// 转换成container包中的参数 参数不合法时返回ErrInvalidArgument
+func (t *ContainerOptions) runArgs(run *RunOptions) (*container.RunCommandArgs, error) {
+	if run == nil {
+		run = &RunOptions{}
+	}
+	if t.Image == "" {
+		return nil, fmt.Errorf("missing image")
+	}
+	if len(t.Cmd) == 0 {
+		return nil, fmt.Errorf("missing command")
+	}
+	if run.AutoRemove && !run.Tty {
+		return nil, fmt.Errorf("auto remove only work with tty")
+	}
+	if run.Record && !run.Tty {
+		return nil, fmt.Errorf("record only work with tty")
+	}
+	if t.OomScoreAdj < -1000 || t.OomScoreAdj > 1000 {
+		return nil, fmt.Errorf("invalid oom score adj %d (range is -1000 to 1000)", t.OomScoreAdj)
+	}
+	ulimits, err := container.ParseUlimits(t.Ulimits)
+	if err != nil {
+		return nil, err
+	}
+	sysctls, err := container.ParseSysctls(t.Sysctls)
+	if err != nil {
+		return nil, err
+	}
+	restart := container.RestartPolicy{Name: container.RestartNo}
+	if t.Restart != "" {
+		if restart, err = container.ParseRestartPolicy(t.Restart); err != nil {
+			return nil, err
+		}
+	}
+	if run.AutoRemove && restart.Name != container.RestartNo {
+		return nil, fmt.Errorf("auto remove and restart policy can not work together")
+	}
+	logDriver := t.LogDriver
+	if logDriver == "" {
+		logDriver = "json-file"
+	}
+	logConfig, err := container.NewLogConfig(logDriver, t.LogOpts)
+	if err != nil {
+		return nil, err
+	}
+	res, err := t.Resources.config()
+	if err != nil {
+		return nil, err
+	}
+
+	labels := labelArgs(t.Labels)
+
+	netMode, netName := container.ParseNetMode(t.Network)
+	args := &container.RunCommandArgs{
+		Tty:           run.Tty,
+		VolumeArg:     t.Volumes,
+		LimitResConf:  res,
+		CommandArgs:   t.Cmd,
+		Detach:        !run.Tty,
+		ContainerName: t.Name,
+		ImageName:     t.Image,
+		EnvList:       t.Env,
+		Net:           netName,
+		PortMapping:   strings.Join(t.Ports, " "),
+		Labels:        labels,
+		LogConfig:     logConfig,
+		AutoRemove:    run.AutoRemove,
+		Record:        run.Record,
+		OomScoreAdj:   t.OomScoreAdj,
+		CgroupParent:  t.CgroupParent,
+		Ulimits:       ulimits,
+		Sysctls:       sysctls,
+		Restart:       restart,
+		Namespaces: container.NamespaceModes{
+			Net: netMode,
+			Pid: t.Pid,
+			Ipc: t.Ipc,
+			Uts: t.Uts,
+		},
+	}
+	// pod的成员使用pod的网络 默认放在pod的父资源组中
+	if args.Pod = t.Pod; args.Pod != "" {
+		if t.Network != "" || len(t.Ports) != 0 || t.Ipc != "" || t.Uts != "" {
+			return nil, fmt.Errorf("network ports ipc and uts can not work with pod")
+		}
+		args.Namespaces.Net = "pod:" + args.Pod
+		args.Namespaces.Ipc = "pod:" + args.Pod
+		args.Namespaces.Uts = "pod:" + args.Pod
+		if args.CgroupParent == "" {
+			args.CgroupParent = container.PodCgroupParent(args.Pod)
+		}
+	}
+	if args.CgroupParent == "" {
+		args.CgroupParent = cgroups.DefaultCgroupParent
+	}
+	if err := args.Namespaces.Validate(); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+func (t *LogsOptions) logsOptions() (*container.LogsOptions, error) {
+	if t == nil {
+		t = &LogsOptions{}
+	}
+	return container.NewLogsOptions(t.Follow, t.Tail, t.Since, t.Until, t.Timestamps, t.Stdout, t.Stderr)
+}
+
+// 转换成命令行中key=value格式的标签
+func labelArgs(labels map[string]string) []string {
+	res := make([]string, 0, len(labels))
+	for k, v := range labels {
+		res = append(res, k+"="+v)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (t *ProjectOptions) load() (*container.Project, error) {
+	file := t.File
+	if file == "" {
+		file = "project.yml"
+	}
+	return container.LoadProject(file, t.Name)
+}
+
+// 转换成cgroup使用的配置 内存大小统一换算成字节
+func (t *Resources) config() (*limit.ResourceConfig, error) {
+	res := &limit.ResourceConfig{}
+	if t == nil {
+		return res, nil
+	}
+	res.Cpu = t.CpuShares
+	res.Cpus = t.Cpus
+	res.CpuPeriod = t.CpuPeriod
+	res.CpuQuota = t.CpuQuota
+	res.Cpuset = t.CpusetCpus
+	res.CpusetMems = t.CpusetMems
+	res.OomKillDisable = t.OomKillDisable
+	res.PidsLimit = t.PidsLimit
+	res.BlkioWeight = t.BlkioWeight
+	for _, item := range []struct {
+		name  string
+		value string
+		res   *string
+	}{
+		{"memory", t.Memory, &res.Memory},
+		{"memory high", t.MemoryHigh, &res.MemoryHigh},
+		{"memory swap", t.MemorySwap, &res.MemorySwap},
+		{"memory reservation", t.MemoryReservation, &res.MemoryReservation},
+		{"kernel memory", t.KernelMemory, &res.KernelMemory},
+	} {
+		value, err := limit.NormalizeMemory(item.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %s", item.name, item.value)
+		}
+		*item.res = value
+	}
+	var err error
+	if res.DeviceReadBps, err = limit.ParseThrottleDevices(t.DeviceReadBps, true); err != nil {
+		return nil, err
+	}
+	if res.DeviceWriteBps, err = limit.ParseThrottleDevices(t.DeviceWriteBps, true); err != nil {
+		return nil, err
+	}
+	if res.DeviceReadIops, err = limit.ParseThrottleDevices(t.DeviceReadIops, false); err != nil {
+		return nil, err
+	}
+	if res.DeviceWriteIops, err = limit.ParseThrottleDevices(t.DeviceWriteIops, false); err != nil {
+		return nil, err
+	}
+	if res.HugepageLimits, err = limit.ParseHugepageLimits(t.HugepageLimits); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
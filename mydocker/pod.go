@@ -0,0 +1,65 @@
+package mydocker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/kehaha-5/go-low-level-container/container"
+)
+
+// 创建并启动pod的infra进程 pod中的容器共享它的网络 ipc和uts
+func (t *Runtime) CreatePod(ctx context.Context, opts *PodOptions) error {
+	if err := t.lock(ctx, "create pod", opts.Name); err != nil {
+		return err
+	}
+	defer t.unlock()
+	if opts.Name == "" {
+		return newError("create pod", opts.Name, ErrInvalidArgument, fmt.Errorf("missing pod name"))
+	}
+	if _, err := container.GetPod(opts.Name); err == nil {
+		return newError("create pod", opts.Name, ErrConflict, fmt.Errorf("pod %s already exists", opts.Name))
+	}
+	return newError("create pod", opts.Name, nil, container.CreatePod(opts.Name, opts.Network, opts.Ports, labelArgs(opts.Labels)))
+}
+
+// 启动pod的infra进程和pod中的容器
+func (t *Runtime) StartPod(ctx context.Context, name string) error {
+	return t.podOp(ctx, "start pod", name, container.StartPod)
+}
+
+// 停止pod中的容器和infra进程
+func (t *Runtime) StopPod(ctx context.Context, name string) error {
+	return t.podOp(ctx, "stop pod", name, container.StopPod)
+}
+
+// force为true时先停止pod并删除pod中的容器
+func (t *Runtime) RemovePod(ctx context.Context, name string, force bool) error {
+	return t.podOp(ctx, "remove pod", name, func(name string) error {
+		return container.RmPod(name, force)
+	})
+}
+
+// 以表格的形式输出所有pod
+func (t *Runtime) WritePods(ctx context.Context, w io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return newError("pods", "", nil, err)
+	}
+	tw := tabwriter.NewWriter(w, 12, 1, 5, ' ', tabwriter.TabIndent)
+	if err := container.WirtePodsToTabwriter(tw); err != nil {
+		return newError("pods", "", nil, err)
+	}
+	return newError("pods", "", nil, tw.Flush())
+}
+
+func (t *Runtime) podOp(ctx context.Context, op string, name string, fn func(name string) error) error {
+	if err := t.lock(ctx, op, name); err != nil {
+		return err
+	}
+	defer t.unlock()
+	if _, err := container.GetPod(name); err != nil {
+		return newError(op, name, ErrNotFound, err)
+	}
+	return newError(op, name, nil, fn(name))
+}
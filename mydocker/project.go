@@ -0,0 +1,70 @@
+package mydocker
+
+import (
+	"context"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/kehaha-5/go-low-level-container/container"
+)
+
+// 按依赖顺序创建项目的网络和容器
+func (t *Runtime) ProjectUp(ctx context.Context, opts *ProjectOptions) error {
+	return t.projectOp(ctx, "project up", opts, container.ProjectUp)
+}
+
+// 删除项目的容器和网络
+func (t *Runtime) ProjectDown(ctx context.Context, opts *ProjectOptions) error {
+	return t.projectOp(ctx, "project down", opts, container.ProjectDown)
+}
+
+// 以表格的形式输出项目的容器
+func (t *Runtime) WriteProject(ctx context.Context, w io.Writer, opts *ProjectOptions) error {
+	if err := ctx.Err(); err != nil {
+		return newError("project ps", opts.File, nil, err)
+	}
+	project, err := opts.load()
+	if err != nil {
+		return newError("project ps", opts.File, ErrInvalidArgument, err)
+	}
+	tw := tabwriter.NewWriter(w, 12, 1, 5, ' ', tabwriter.TabIndent)
+	if err := container.WirteProjectToTabwriter(project, tw); err != nil {
+		return newError("project ps", opts.File, nil, err)
+	}
+	return newError("project ps", opts.File, nil, tw.Flush())
+}
+
+// 输出项目中服务的日志 services为空时输出所有服务
+func (t *Runtime) ProjectLogs(ctx context.Context, opts *ProjectOptions, services []string, logs *LogsOptions, stdout io.Writer, stderr io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return newError("project logs", opts.File, nil, err)
+	}
+	project, err := opts.load()
+	if err != nil {
+		return newError("project logs", opts.File, ErrInvalidArgument, err)
+	}
+	logsOpts, err := logs.logsOptions()
+	if err != nil {
+		return newError("project logs", opts.File, ErrInvalidArgument, err)
+	}
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	return newError("project logs", opts.File, nil, container.WriteProjectLogs(project, services, logsOpts, stdout, stderr))
+}
+
+func (t *Runtime) projectOp(ctx context.Context, op string, opts *ProjectOptions, fn func(*container.Project) error) error {
+	if err := t.lock(ctx, op, opts.File); err != nil {
+		return err
+	}
+	defer t.unlock()
+	project, err := opts.load()
+	if err != nil {
+		return newError(op, opts.File, ErrInvalidArgument, err)
+	}
+	return newError(op, opts.File, nil, fn(project))
+}
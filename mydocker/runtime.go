@@ -0,0 +1,105 @@
+// mydocker 是嵌入到其他go程序中使用的容器运行时 命令行工具也通过它管理容器 镜像和网络
+//
+// 容器的init logger等进程都是重新执行当前程序启动的 使用Runtime的程序需要在main函数开始时调用Reexec
+//
+//	func main() {
+//		if ok, err := mydocker.Reexec(); ok {
+//			if err != nil {
+//				log.Fatal(err)
+//			}
+//			return
+//		}
+//		rt, err := mydocker.New()
+//		...
+//	}
+package mydocker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/kehaha-5/go-low-level-container/container"
+	_ "github.com/kehaha-5/go-low-level-container/nsenter"
+	"github.com/kehaha-5/go-low-level-container/store"
+)
+
+// container和network包中的操作不是并发安全的 同一个Runtime中修改状态的操作串行执行
+// 不同进程之间由store中的文件锁保证一致
+// 零值也可以使用
+type Runtime struct {
+	mu       sync.Mutex
+	prepared bool
+}
+
+// 不做任何修改 保存的数据在第一个修改状态的操作前才迁移和修正
+// 只读的操作按观察到的进程状态返回 不修改记录
+func New() (*Runtime, error) {
+	return &Runtime{}, nil
+}
+
+// 迁移保存的数据并修正宿主机重启等原因导致的状态不一致 完整的修复由 system reconcile 执行
+func (t *Runtime) prepare() error {
+	if t.prepared {
+		return nil
+	}
+	if err := store.Migrate(); err != nil {
+		return err
+	}
+	// 修正失败不影响之后的操作
+	if _, err := container.ReconcileStatus(); err != nil {
+		slog.Error("reconcile status", "err", err)
+	}
+	t.prepared = true
+	return nil
+}
+
+// 当前进程是Runtime重新执行启动的内部进程时执行对应的逻辑并返回true 调用者之后应该直接退出
+func Reexec() (bool, error) {
+	if len(os.Args) < 2 {
+		return false, nil
+	}
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case container.InitCommand:
+		if err := container.RunContainerProgram(); err != nil {
+			return true, fmt.Errorf("init error %+v", err)
+		}
+	case container.LoggerCommand:
+		if len(args) < 2 {
+			return true, fmt.Errorf("miss logger args")
+		}
+		if err := container.RunLogger(args[0], args[1]); err != nil {
+			return true, fmt.Errorf("logger error %+v", err)
+		}
+	case container.PodInfraCommand:
+		if len(args) < 1 {
+			return true, fmt.Errorf("miss pod name")
+		}
+		if err := container.RunPodInfra(args[0]); err != nil {
+			return true, fmt.Errorf("pod infra error %+v", err)
+		}
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// 加锁前检查ctx 已经取消的操作不再执行 第一次加锁时迁移和修正保存的数据
+func (t *Runtime) lock(ctx context.Context, op string, ref string) error {
+	if err := ctx.Err(); err != nil {
+		return newError(op, ref, nil, err)
+	}
+	t.mu.Lock()
+	if err := t.prepare(); err != nil {
+		t.mu.Unlock()
+		return newError(op, ref, nil, err)
+	}
+	return nil
+}
+
+func (t *Runtime) unlock() {
+	t.mu.Unlock()
+}
@@ -0,0 +1,100 @@
+package mydocker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/kehaha-5/go-low-level-container/container"
+)
+
+// 删除已经停止的容器 filters和prune命令的--filter一致 如 until=24h label=key=value label!=key
+func (t *Runtime) PruneContainers(ctx context.Context, filters []string) (*PruneReport, error) {
+	return t.prune(ctx, "prune containers", filters, container.PruneContainers)
+}
+
+// 删除没有容器使用的镜像解压层 all为true时连同镜像文件一起删除
+func (t *Runtime) PruneImages(ctx context.Context, filters []string, all bool) (*PruneReport, error) {
+	return t.prune(ctx, "prune images", filters, func(filter *common.Filter) (*container.PruneReport, error) {
+		return container.PruneImages(filter, all)
+	})
+}
+
+// 删除没有容器使用的命名卷
+func (t *Runtime) PruneVolumes(ctx context.Context, filters []string) (*PruneReport, error) {
+	return t.prune(ctx, "prune volumes", filters, container.PruneVolumes)
+}
+
+func (t *Runtime) prune(ctx context.Context, op string, filters []string, fn func(*common.Filter) (*container.PruneReport, error)) (*PruneReport, error) {
+	if err := t.lock(ctx, op, ""); err != nil {
+		return nil, err
+	}
+	defer t.unlock()
+	filter, err := common.ParseFilters(filters)
+	if err != nil {
+		return nil, newError(op, "", ErrInvalidArgument, err)
+	}
+	report, err := fn(filter)
+	if err != nil {
+		return nil, newError(op, "", nil, err)
+	}
+	return report, nil
+}
+
+// 修复崩溃或者宿主机重启后不一致的容器状态 返回执行过的修复 出错时也返回已经执行的部分
+func (t *Runtime) Repair(ctx context.Context) ([]string, error) {
+	if err := t.lock(ctx, "repair", ""); err != nil {
+		return nil, err
+	}
+	defer t.unlock()
+	report, err := container.Reconcile()
+	var actions []string
+	if report != nil {
+		actions = report.Actions
+	}
+	return actions, newError("repair", "", nil, err)
+}
+
+// 以表格的形式输出镜像 容器 卷和日志占用的磁盘空间
+func (t *Runtime) WriteDiskUsage(ctx context.Context, w io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return newError("df", "", nil, err)
+	}
+	tw := tabwriter.NewWriter(w, 12, 1, 5, ' ', tabwriter.TabIndent)
+	fmt.Fprint(tw, "TYPE\tTOTAL\tACTIVE\tSIZE\tRECLAIMABLE\n")
+	if err := container.WirteDiskUsageToTabwriter(tw); err != nil {
+		return newError("df", "", nil, err)
+	}
+	return newError("df", "", nil, tw.Flush())
+}
+
+// 新容器默认使用的ulimit 格式为 nofile=1024:2048
+func (t *Runtime) DefaultUlimits(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, newError("defaults", "", nil, err)
+	}
+	defaults, err := container.GetDefaults()
+	if err != nil {
+		return nil, newError("defaults", "", nil, err)
+	}
+	res := make([]string, 0, len(defaults.Ulimits))
+	for _, item := range defaults.Ulimits {
+		res = append(res, item.String())
+	}
+	return res, nil
+}
+
+// 修改新容器默认使用的ulimit 已有的同名限制会被覆盖 reset为true时先清空原来的默认值
+func (t *Runtime) SetDefaultUlimits(ctx context.Context, ulimits []string, reset bool) error {
+	if err := t.lock(ctx, "defaults", ""); err != nil {
+		return err
+	}
+	defer t.unlock()
+	parsed, err := container.ParseUlimits(ulimits)
+	if err != nil {
+		return newError("defaults", "", ErrInvalidArgument, err)
+	}
+	return newError("defaults", "", nil, container.SetDefaultUlimits(parsed, reset))
+}
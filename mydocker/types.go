@@ -0,0 +1,110 @@
+package mydocker
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/kehaha-5/go-low-level-container/container"
+	"github.com/kehaha-5/go-low-level-container/network"
+)
+
+// 容器状态
+const (
+	StatusCreated string = container.Created
+	StatusRunning string = container.Running
+	StatusStopped string = container.Stop
+	StatusExited  string = container.Exit
+)
+
+type Container struct {
+	Id           string
+	Name         string
+	Image        string
+	Command      string
+	Status       string
+	Pid          int  // 最后一次启动的init进程在宿主机上的pid 还没有启动过时为0
+	Running      bool // 状态为运行中并且进程还存在
+	ExitCode     int  // 最后一次退出的退出码 -1为未知
+	OOMKilled    bool
+	Created      time.Time
+	Labels       map[string]string
+	Network      string
+	IPAddress    string
+	Ports        []string // hostPort:containerPort
+	Restart      string
+	RestartCount int
+	Pod          string
+}
+
+type Image struct {
+	Id      string
+	Name    string // 镜像文件名
+	Size    string // 便于阅读的镜像文件大小 如 2MB
+	Created time.Time
+}
+
+type Network struct {
+	Id      string
+	Name    string
+	Driver  string
+	Subnet  string
+	Created time.Time
+	Labels  map[string]string
+}
+
+// prune的结果 Deleted为删除的容器id 镜像 卷或者网络名称
+type PruneReport = container.PruneReport
+
+// 时间都是RFC3339格式保存的 解析失败时为零值
+func parseTime(value string) time.Time {
+	res, _ := time.Parse(time.RFC3339, value)
+	return res
+}
+
+func newContainer(info *container.ContainerInfos) *Container {
+	res := &Container{
+		Id:           info.Id,
+		Name:         info.Name,
+		Image:        info.Image,
+		Command:      info.Command,
+		Status:       info.ObservedStatus(),
+		Running:      info.IsAlive(),
+		ExitCode:     info.ExitCode,
+		OOMKilled:    info.OOMKilled,
+		Created:      parseTime(info.CreateTime),
+		Labels:       info.Labels,
+		Ports:        info.PortMapping,
+		Restart:      info.Restart.String(),
+		RestartCount: info.Restarts,
+		Pod:          info.Pod,
+	}
+	res.Pid, _ = strconv.Atoi(info.Pid)
+	if ep := info.IpInfo; ep.Network != nil {
+		res.Network = ep.Network.Name
+		res.IPAddress = ep.IPAddress.String()
+	}
+	return res
+}
+
+func newImage(info *container.ImageInfo) *Image {
+	return &Image{
+		Id:      info.ID,
+		Name:    info.Name,
+		Size:    info.Size,
+		Created: parseTime(info.CreateTime),
+	}
+}
+
+func newNetwork(n *network.Network) *Network {
+	res := &Network{
+		Id:      n.Id,
+		Name:    n.Name,
+		Driver:  n.Driver,
+		Created: parseTime(n.CreateTime),
+		Labels:  n.Labels,
+	}
+	if n.IpRange != nil {
+		res.Subnet = n.IpRange.String()
+	}
+	return res
+}
@@ -38,43 +38,51 @@ func (t *DridgeNetworkDriver) Create(subnet string, bridgeName string) (*Network
 		return nil, errors.WithStack(fmt.Errorf("interface name has existed"))
 	}
 
+	if err := t.Setup(n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// 根据网络信息创建并配置网桥 宿主机重启后网桥会丢失 也通过这里重新创建
+func (t *DridgeNetworkDriver) Setup(n *Network) error {
 	bridge := &netlink.Bridge{
 		LinkAttrs: netlink.LinkAttrs{
-			Name: bridgeName,
+			Name: n.Name,
 		},
 	}
 
 	// ip link add name ${bridgeName} type bridge
 	if err := netlink.LinkAdd(bridge); err != nil {
-		return nil, errors.Wrapf(err, "bridge creation failed for bridge %s", bridgeName)
+		return errors.Wrapf(err, "bridge creation failed for bridge %s", n.Name)
 	}
 	// add ip to bridge
-	netAddr, err := netlink.ParseAddr(ipRange.String())
+	netAddr, err := netlink.ParseAddr(n.IpRange.String())
 	if err != nil {
-		return nil, errors.Wrap(err, "error ParseAddr")
+		return errors.Wrap(err, "error ParseAddr")
 	}
 	// it will be automatically computed based on the IP mask ip addr add (subnet) dev ${bridgeName}
 	if err = netlink.AddrAdd(bridge, netAddr); err != nil {
-		return nil, errors.Wrap(err, "set ip to bridge")
+		return errors.Wrap(err, "set ip to bridge")
 	}
 	// Bring up the bridge interface
 	if err := netlink.LinkSetUp(bridge); err != nil {
-		return nil, errors.Wrap(err, "setting bridge up")
+		return errors.Wrap(err, "setting bridge up")
 	}
 	ipt, err := iptables.New()
 	if err != nil {
-		return nil, errors.Wrap(err, "fail to new ipt")
+		return errors.Wrap(err, "fail to new ipt")
 	}
-	_, ipRangeForIpt, _ := net.ParseCIDR(subnet) //要生成类似 172.47.0.0/24 或 192.168.1.0/24 不能添加ip 如 192.168.1.1/24
-	if err := ipt.Append("nat", "POSTROUTING", "-s", ipRangeForIpt.String(), "-j", "MASQUERADE"); err != nil {
-		return nil, errors.Wrap(err, "fail to set ipt command")
+	_, ipRangeForIpt, _ := net.ParseCIDR(n.IpRange.String()) //要生成类似 172.47.0.0/24 或 192.168.1.0/24 不能添加ip 如 192.168.1.1/24
+	if err := ipt.AppendUnique("nat", "POSTROUTING", "-s", ipRangeForIpt.String(), "-j", "MASQUERADE"); err != nil {
+		return errors.Wrap(err, "fail to set ipt command")
 	}
 	rule, err := ipt.List("nat", "PREROUTING")
 	if err != nil {
-		slog.Debug("fail to get rule", err)
+		slog.Debug("fail to get rule", "err", err)
 	}
 	slog.Debug("configMapping", "ipt rule ", rule)
-	return n, nil
+	return nil
 }
 
 func (t *DridgeNetworkDriver) Delete(bridgeName string) error {
@@ -96,7 +104,7 @@ func (t *DridgeNetworkDriver) Connect(n *Network, ep *Endpoint) error {
 		LinkAttrs: netlink.LinkAttrs{
 			Name: ep.ID[:5], //宿主机显示名称
 		},
-		PeerName: "cif-" + ep.ID[:5], //容器显示名称
+		PeerName: containerVethPrefix + ep.ID[:5], //容器显示名称
 	}
 	//因为上面指定了 link 的MasterIndex 是网络对应的 Linux Bridge
 	//所以Veth 的一端就己经挂载到了网络对应的 Linux Bridge 上
@@ -4,6 +4,7 @@ package network
 type NetworkDriver interface {
 	Name() string
 	Create(subnet string, name string) (*Network, error)
+	Setup(n *Network) error
 	Delete(bridgeName string) error
 	Connect(n *Network, ep *Endpoint) error
 	DisConnect() error
@@ -8,12 +8,14 @@ import (
 	"strings"
 
 	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/kehaha-5/go-low-level-container/store"
 
 	"github.com/pkg/errors"
 )
 
 const ipamDefaultAllocatorPath = "ipam"
 const ipamSaveIpAllocatorFile = "subnet.json"
+const ipamLockName = "ipam"
 
 type IPAM struct {
 	SubnetAllocatorPath string
@@ -27,7 +29,7 @@ var ipAllocator = &IPAM{
 func (ipam *IPAM) load() error {
 	if !common.FileExist(ipam.SubnetAllocatorPath) {
 		filepath, _ := path.Split(ipam.SubnetAllocatorPath)
-		os.MkdirAll(filepath, 0644)
+		os.MkdirAll(filepath, 0755)
 		return nil
 	}
 	subnetJson, err := os.ReadFile(ipam.SubnetAllocatorPath)
@@ -42,10 +44,25 @@ func (ipam *IPAM) dump() error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(ipam.SubnetAllocatorPath, subnetJson, 0644)
+	return store.WriteFileAtomic(ipam.SubnetAllocatorPath, subnetJson, 0644)
 }
 
+// 分配和释放都是 读取-修改-写入 需要加锁 否则同时运行的容器会分配到同一个ip
 func (ipam *IPAM) Allocate(subnet *net.IPNet) (ip net.IP, err error) {
+	err = store.WithLock(ipamLockName, func() error {
+		ip, err = ipam.allocate(subnet)
+		return err
+	})
+	return
+}
+
+func (ipam *IPAM) Release(subnet *net.IPNet, ipaddr *net.IP) error {
+	return store.WithLock(ipamLockName, func() error {
+		return ipam.release(subnet, ipaddr)
+	})
+}
+
+func (ipam *IPAM) allocate(subnet *net.IPNet) (ip net.IP, err error) {
 	// 存放网段中地址分配信息的数组
 	ipam.Subnets = &map[string]string{}
 
@@ -93,7 +110,7 @@ func (ipam *IPAM) Allocate(subnet *net.IPNet) (ip net.IP, err error) {
 	return
 }
 
-func (ipam *IPAM) Release(subnet *net.IPNet, ipaddr *net.IP) error {
+func (ipam *IPAM) release(subnet *net.IPNet, ipaddr *net.IP) error {
 	ipam.Subnets = &map[string]string{}
 
 	_, subnet, _ = net.ParseCIDR(subnet.String())
@@ -10,11 +10,14 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"syscall"
-	"text/tabwriter"
+	"time"
 
 	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/kehaha-5/go-low-level-container/events"
+	"github.com/kehaha-5/go-low-level-container/store"
 	"golang.org/x/sys/unix"
 
 	"github.com/coreos/go-iptables/iptables"
@@ -58,18 +61,25 @@ type Endpoint struct {
 
 // 每一个驱动中有同子网网络 192.168.0.0/24 172.17.0.0/24 等
 type Network struct {
-	Id      string
-	Name    string
-	IpRange *net.IPNet //192.168.0.0/24
-	Driver  string
+	Id         string
+	Name       string
+	IpRange    *net.IPNet //192.168.0.0/24
+	Driver     string
+	CreateTime string
+	Labels     map[string]string
 }
 
+func getNetworkLockName(name string) string {
+	return "network/" + name
+}
+
+// 写入网络的配置文件 调用者需要持有网络锁
 func (t *Network) dump() error {
 	nsJson, err := json.Marshal(t)
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	err = os.WriteFile(path.Join(defaultNetworkPath, t.Name), nsJson, 0644)
+	err = store.WriteFileAtomic(path.Join(defaultNetworkPath, t.Name), nsJson, 0644)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -92,20 +102,11 @@ func (t *Network) remove() error {
 	return errors.WithStack(os.RemoveAll(path.Join(defaultNetworkPath, t.Name)))
 }
 
-func (t *Network) wirteInfoToTabwriter(w *tabwriter.Writer) {
-	// "ID\tNAME\tIpRange\tDriver\n"
-	fmt.Fprintf(
-		w, "%s\t%s\t%s\t%s\n",
-		t.Id,
-		t.Name,
-		t.IpRange,
-		t.Driver,
-	)
-}
-
+// 从保存的文件重新加载所有网络 daemon等常驻进程每次使用前都需要调用
 func Init() error {
+	networks = map[string]*Network{}
 	if exist, _ := common.PathExist(defaultNetworkPath); !exist {
-		if err := os.MkdirAll(defaultNetworkPath, 0644); err != nil {
+		if err := os.MkdirAll(defaultNetworkPath, 0755); err != nil {
 			return errors.WithStack(err)
 		}
 	}
@@ -123,6 +124,9 @@ func Init() error {
 		}
 
 		_, file := filepath.Split(path)
+		if store.IsTempFile(file) {
+			return nil
+		}
 
 		network := &Network{
 			Name: file,
@@ -138,7 +142,16 @@ func Init() error {
 	return nil
 }
 
-func CreateNetwork(driver, subnet, name string) error {
+func CreateNetwork(driver, subnet, name string, labels []string) error {
+	return store.WithLock(getNetworkLockName(name), func() error {
+		if common.FileExist(path.Join(defaultNetworkPath, name)) {
+			return fmt.Errorf("network name %s has existed", name)
+		}
+		return createNetwork(driver, subnet, name, labels)
+	})
+}
+
+func createNetwork(driver, subnet, name string, labels []string) error {
 	// subnet string to RFC 4632 and RFC 4291.
 	_, ipNet, err := net.ParseCIDR(subnet)
 	if err != nil {
@@ -161,10 +174,22 @@ func CreateNetwork(driver, subnet, name string) error {
 		defer ipAllocator.Release(ipNet, &aip)
 		return errors.WithStack(err)
 	}
-	return network.dump()
+	network.CreateTime = time.Now().Format(time.RFC3339)
+	network.Labels = common.ParseLabels(labels)
+	if err := network.dump(); err != nil {
+		return err
+	}
+	events.Emit(events.Network, events.ActionCreate, network.Id, network.Name, map[string]string{"driver": network.Driver})
+	return nil
 }
 
 func RemoveNetwork(name string) error {
+	return store.WithLock(getNetworkLockName(name), func() error {
+		return removeNetwork(name)
+	})
+}
+
+func removeNetwork(name string) error {
 	n, eixst := networks[name]
 	if !eixst {
 		return errors.WithStack(fmt.Errorf("netwrok name %s not exist", name))
@@ -175,13 +200,27 @@ func RemoveNetwork(name string) error {
 	if err := d.Delete(n.Name); err != nil {
 		return errors.WithStack(err)
 	}
-	return errors.WithStack(n.remove())
+	if err := n.remove(); err != nil {
+		return errors.WithStack(err)
+	}
+	events.Emit(events.Network, events.ActionDestroy, n.Id, n.Name, nil)
+	return nil
+}
+
+// 网络是否已经创建 需要先调用Init
+func Exist(name string) bool {
+	_, ok := networks[name]
+	return ok
 }
 
-func ShowAllNetworks(w *tabwriter.Writer) {
-	for _, itme := range networks {
-		itme.wirteInfoToTabwriter(w)
+// 按名称排序的所有网络 需要先调用Init
+func ListNetworks() []*Network {
+	res := make([]*Network, 0, len(networks))
+	for _, n := range networks {
+		res = append(res, n)
 	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
 }
 
 func Connect(networkName string, containerId string, netnsName string, portMapping []string) (*Endpoint, error) {
@@ -219,6 +258,7 @@ func Connect(networkName string, containerId string, netnsName string, portMappi
 		defer ipAllocator.Release(network.IpRange, &ip)
 		return nil, errors.WithStack(err)
 	}
+	events.Emit(events.Network, events.ActionConnect, network.Id, network.Name, map[string]string{"container": containerId})
 	return ep, nil
 }
 
@@ -269,6 +309,25 @@ func configContainerNetwork(ep *Endpoint, netnsName string, gwIpNet *net.IPNet,
 	return errors.Wrapf(netlink.RouteAdd(defaultRoute), "fail to add route %s", defaultRoute.String())
 }
 
+// 没有连接网络的容器只启动lo
+func SetUpLoopback(netnsName string) error {
+	nsHandle, err := netns.GetFromName(netnsName)
+	if err != nil {
+		return errors.Wrapf(err, "fail to get netns %s", netnsName)
+	}
+	defer nsHandle.Close()
+	handle, err := netlink.NewHandleAt(nsHandle)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer handle.Delete()
+	loLink, err := handle.LinkByName("lo")
+	if err != nil {
+		return errors.Wrap(err, "fail to get lo link")
+	}
+	return errors.Wrap(handle.LinkSetUp(loLink), "fail to add setup loLink")
+}
+
 func enterContainerNetns(enLink *netlink.Link, netnsName string) func() {
 
 	// 找到容器的Net Namespace
@@ -325,7 +384,7 @@ func ConfigMapping(ep *Endpoint) error {
 		ep.IptCommand = append(ep.IptCommand, iptCommand)
 		slog.Debug("ipt", "command", iptCommand)
 		if err := ipt.Append("nat", "PREROUTING", strings.Split(iptCommand, " ")...); err != nil {
-			slog.Error("fail to set ipt command %v", err)
+			slog.Error("fail to set ipt command", "err", err)
 			continue
 		}
 	}
@@ -345,6 +404,8 @@ func DisConnect(ep *Endpoint) error {
 	if err := ipAllocator.Release(ep.Network.IpRange, &ep.IPAddress); err != nil {
 		return errors.Wrap(ipAllocator.Release(ep.Network.IpRange, &ep.IPAddress), "fail to release ip")
 	}
+	containerId := strings.TrimSuffix(ep.ID, "-"+ep.Network.Name)
+	events.Emit(events.Network, events.ActionDisconnect, ep.Network.Id, ep.Network.Name, map[string]string{"container": containerId})
 	return nil
 }
 
@@ -0,0 +1,42 @@
+package network
+
+import (
+	"log/slog"
+
+	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/kehaha-5/go-low-level-container/store"
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+)
+
+// 删除没有被容器使用的网络 网桥已经不存在的网络只删除保存的网络文件
+// used 为正在被容器使用的网络名称
+func PruneNetworks(filter *common.Filter, used map[string]bool) ([]string, error) {
+	deleted := []string{}
+	for name, n := range networks {
+		if used[name] {
+			continue
+		}
+		if !filter.MatchTime(n.CreateTime) || !filter.MatchLabels(n.Labels) {
+			continue
+		}
+		if _, err := netlink.LinkByName(n.Name); err != nil {
+			// 残留的网络文件
+			slog.Info("prune network", "name", name, "msg", "bridge not exist, remove the stale network file")
+			if n.IpRange != nil {
+				if err := ipAllocator.Release(n.IpRange, &n.IpRange.IP); err != nil {
+					slog.Error("prune network", "name", name, "err", err)
+				}
+			}
+			if err := store.WithLock(getNetworkLockName(name), n.remove); err != nil {
+				return deleted, errors.WithStack(err)
+			}
+		} else if err := RemoveNetwork(name); err != nil {
+			slog.Error("prune network", "name", name, "err", err)
+			continue
+		}
+		delete(networks, name)
+		deleted = append(deleted, name)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,204 @@
+package network
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/coreos/go-iptables/iptables"
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+const (
+	defaultNetnsPath    = "/var/run/netns"
+	containerVethPrefix = "cif-"
+)
+
+// 重新创建丢失的网桥 返回执行的修复操作
+func ReconcileNetworks() ([]string, error) {
+	actions := []string{}
+	for name, n := range networks {
+		if _, err := netlink.LinkByName(name); err == nil {
+			continue
+		}
+		d, exist := dirvers[n.Driver]
+		if !exist || n.IpRange == nil {
+			slog.Error("reconcile network", "name", name, "err", "unknown driver or ip range")
+			continue
+		}
+		if err := d.Setup(n); err != nil {
+			return actions, errors.Wrapf(err, "fail to setup network %s", name)
+		}
+		actions = append(actions, fmt.Sprintf("network %s: recreate bridge", name))
+	}
+	return actions, nil
+}
+
+// 容器的veth不存在时重新创建veth并配置容器网络
+func ReconcileEndpoint(ep *Endpoint, netnsName string) ([]string, error) {
+	actions := []string{}
+	if ep.Network == nil {
+		return actions, nil
+	}
+	if _, err := netlink.LinkByName(ep.Device.Name); err == nil {
+		return actions, nil
+	}
+	n, exist := networks[ep.Network.Name]
+	if !exist {
+		return actions, fmt.Errorf("network name %s not exist", ep.Network.Name)
+	}
+	if err := dirvers[n.Driver].Connect(n, ep); err != nil {
+		return actions, errors.WithStack(err)
+	}
+	if err := configContainerNetwork(ep, netnsName, n.IpRange, &ep.IPAddress); err != nil {
+		return actions, errors.WithStack(err)
+	}
+	actions = append(actions, fmt.Sprintf("endpoint %s: recreate veth %s", ep.ID, ep.Device.Name))
+	return actions, nil
+}
+
+// 补上运行中容器丢失的端口映射规则
+func EnsureIptRules(ep *Endpoint) ([]string, error) {
+	actions := []string{}
+	ipt, err := iptables.New()
+	if err != nil {
+		return actions, errors.Wrap(err, "fail to new iptables error")
+	}
+	for _, item := range ep.IptCommand {
+		exist, err := ipt.Exists("nat", "PREROUTING", strings.Split(item, " ")...)
+		if err != nil {
+			return actions, errors.WithStack(err)
+		}
+		if exist {
+			continue
+		}
+		if err := ipt.Append("nat", "PREROUTING", strings.Split(item, " ")...); err != nil {
+			return actions, errors.WithStack(err)
+		}
+		actions = append(actions, fmt.Sprintf("endpoint %s: restore iptables rule %s", ep.ID, item))
+	}
+	return actions, nil
+}
+
+// 清理不属于任何容器的内核对象
+// runningEps 为运行中容器的endpoint 只有它们的端口映射规则会被保留
+// eps 为所有容器的endpoint netnsNames 为所有容器的net namespace名称
+func CleanOrphans(runningEps []*Endpoint, eps []*Endpoint, netnsNames map[string]bool) ([]string, error) {
+	actions := []string{}
+
+	// 端口映射规则
+	ipt, err := iptables.New()
+	if err != nil {
+		return actions, errors.Wrap(err, "fail to new iptables error")
+	}
+	knownRules := map[string]bool{}
+	for _, ep := range runningEps {
+		for _, item := range ep.IptCommand {
+			knownRules[item] = true
+		}
+	}
+	rules, err := ipt.List("nat", "PREROUTING")
+	if err != nil {
+		return actions, errors.WithStack(err)
+	}
+	for _, rule := range rules {
+		spec, ok := strings.CutPrefix(rule, "-A PREROUTING ")
+		if !ok || knownRules[spec] || !isOwnedDnatRule(spec) {
+			continue
+		}
+		if err := ipt.Delete("nat", "PREROUTING", strings.Split(spec, " ")...); err != nil {
+			slog.Error("clean orphans", "del ipt", err)
+			continue
+		}
+		actions = append(actions, fmt.Sprintf("delete orphaned iptables rule %s", spec))
+	}
+
+	// 挂在网桥上的veth
+	knownVeths := map[string]bool{}
+	for _, ep := range eps {
+		knownVeths[ep.Device.Name] = true
+	}
+	bridgeIndexs := map[int]bool{}
+	for name := range networks {
+		if link, err := netlink.LinkByName(name); err == nil {
+			bridgeIndexs[link.Attrs().Index] = true
+		}
+	}
+	links, err := netlink.LinkList()
+	if err != nil {
+		return actions, errors.WithStack(err)
+	}
+	for _, link := range links {
+		if link.Type() != "veth" || !bridgeIndexs[link.Attrs().MasterIndex] || knownVeths[link.Attrs().Name] {
+			continue
+		}
+		if err := netlink.LinkDel(link); err != nil {
+			slog.Error("clean orphans", "del veth", err)
+			continue
+		}
+		actions = append(actions, fmt.Sprintf("delete orphaned veth %s", link.Attrs().Name))
+	}
+
+	// 只删除里面有容器veth的net namespace 避免误删其他程序创建的
+	entries, err := os.ReadDir(defaultNetnsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return actions, errors.WithStack(err)
+	}
+	for _, entry := range entries {
+		if netnsNames[entry.Name()] || !hasContainerVeth(entry.Name()) {
+			continue
+		}
+		if err := netns.DeleteNamed(entry.Name()); err != nil {
+			slog.Error("clean orphans", "del netns", err)
+			continue
+		}
+		actions = append(actions, fmt.Sprintf("delete orphaned netns %s", entry.Name()))
+	}
+	return actions, nil
+}
+
+// 目标地址在已有网络中的DNAT规则才是由ConfigMapping添加的
+func isOwnedDnatRule(spec string) bool {
+	_, dest, ok := strings.Cut(spec, "-j DNAT --to-destination ")
+	if !ok {
+		return false
+	}
+	host, _, err := net.SplitHostPort(dest)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	for _, n := range networks {
+		if n.IpRange != nil && n.IpRange.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasContainerVeth(netnsName string) bool {
+	ns, err := netns.GetFromName(netnsName)
+	if err != nil {
+		return false
+	}
+	defer ns.Close()
+	handle, err := netlink.NewHandleAt(ns)
+	if err != nil {
+		return false
+	}
+	defer handle.Delete()
+	links, err := handle.LinkList()
+	if err != nil {
+		return false
+	}
+	for _, link := range links {
+		if strings.HasPrefix(link.Attrs().Name, containerVethPrefix) {
+			return true
+		}
+	}
+	return false
+}
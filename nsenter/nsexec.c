@@ -6,6 +6,7 @@
 #include <string.h>
 #include <errno.h>
 #include <sched.h>
+#include <sys/wait.h>
 #include "fcntl.h"
 
 #define DEBUG 0
@@ -72,6 +73,11 @@ void nsexec()
         }
     }
     int res = system(exce_cmd);
-    exit(0);
+    // 把命令的退出码返回给调用方 健康检查等依赖这个结果
+    if (res == -1 || !WIFEXITED(res))
+    {
+        exit(1);
+    }
+    exit(WEXITSTATUS(res));
     return;
 }
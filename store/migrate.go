@@ -0,0 +1,230 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultVersionFile string = "store.json"
+	// 当前的存储格式版本
+	CurrentVersion int = 1
+)
+
+type storeVersion struct {
+	Version int `json:"version"`
+}
+
+type migration struct {
+	version int
+	desc    string
+	migrate func() error
+}
+
+// 按版本顺序执行的迁移 新增存储格式时在末尾追加
+var migrations = []migration{
+	{
+		version: 1,
+		desc:    "migrate container records to 64-hex ids, startTime and cgParent",
+		migrate: migrateToV1,
+	},
+}
+
+func getVersionPath() string {
+	return path.Join(root, defaultVersionFile)
+}
+
+// 把磁盘上的数据迁移到当前版本 每个命令执行前调用
+func Migrate() error {
+	version := &storeVersion{}
+	if _, err := ReadJSON(getVersionPath(), version); err != nil {
+		return err
+	}
+	if version.Version == CurrentVersion {
+		return nil
+	}
+	if version.Version > CurrentVersion {
+		return fmt.Errorf("store version %d is newer than supported version %d", version.Version, CurrentVersion)
+	}
+
+	return WithLock(globalLockName, func() error {
+		// 拿到锁之后再读一次 可能已经被其他进程迁移了
+		if _, err := ReadJSON(getVersionPath(), version); err != nil {
+			return err
+		}
+		for _, item := range migrations {
+			if item.version <= version.Version {
+				continue
+			}
+			slog.Info("migrate store", "version", item.version, "desc", item.desc)
+			if err := item.migrate(); err != nil {
+				return errors.Wrapf(err, "fail to migrate store to version %d", item.version)
+			}
+			version.Version = item.version
+			if err := WriteJSON(getVersionPath(), version); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 旧版本用0644创建了目录 并且写文件不是原子的 可能残留写了一半的临时文件
+// 旧版本的容器记录使用10位的id 没有记录init进程的启动时间和父资源组
+func migrateToV1() error {
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil
+	}
+	if err := cleanupToV1(); err != nil {
+		return err
+	}
+	files, err := filepath.Glob(path.Join(root, "runEnv/info/*/config.json"))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, file := range files {
+		name := path.Base(path.Dir(file))
+		// 和容器包中修改记录使用同一个锁
+		record := map[string]json.RawMessage{}
+		if err := UpdateJSON("container/"+name, file, &record, func() error {
+			return migrateRecordToV1(record)
+		}); err != nil {
+			return errors.Wrapf(err, "fail to migrate container %s", name)
+		}
+	}
+	return nil
+}
+
+func cleanupToV1() error {
+	for _, dir := range []string{"images", "network", "network/ipam", "runEnv/info"} {
+		if err := os.Chmod(path.Join(root, dir), 0755); err != nil && !os.IsNotExist(err) {
+			return errors.WithStack(err)
+		}
+	}
+	return errors.WithStack(filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// 容器的挂载点和写层中是用户数据
+		if d.IsDir() && (d.Name() == "mnt" || d.Name() == "wirteOnly" || d.Name() == "work" || d.Name() == "readOnly" || d.Name() == "volumes") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && strings.HasSuffix(d.Name(), defaultTmpSuffix) {
+			return os.Remove(p)
+		}
+		return nil
+	}))
+}
+
+// 用原始json修改记录 保留其他字段原样不动
+func migrateRecordToV1(record map[string]json.RawMessage) error {
+	var id string
+	if err := unmarshalField(record, "id", &id); err != nil {
+		return err
+	}
+	if !isHexId(id) {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return errors.Wrap(err, "fail to generate container id")
+		}
+		if err := marshalField(record, "id", hex.EncodeToString(b)); err != nil {
+			return err
+		}
+	}
+
+	var cg struct {
+		Path string
+	}
+	var cgParent string
+	if err := unmarshalField(record, "cg", &cg); err != nil {
+		return err
+	}
+	if err := unmarshalField(record, "cgParent", &cgParent); err != nil {
+		return err
+	}
+	// 旧版本的资源组直接建在根资源组下 没有父资源组可以记录
+	if cgParent == "" && cg.Path != "" && path.Dir(cg.Path) != "." {
+		if err := marshalField(record, "cgParent", path.Dir(cg.Path)); err != nil {
+			return err
+		}
+	}
+
+	var status, pid string
+	var startTime uint64
+	for key, v := range map[string]any{"status": &status, "pid": &pid, "startTime": &startTime} {
+		if err := unmarshalField(record, key, v); err != nil {
+			return err
+		}
+	}
+	if status != "running" || startTime != 0 {
+		return nil
+	}
+	if startTime, ok := legacyStartTime(pid); ok {
+		return marshalField(record, "startTime", startTime)
+	}
+	return nil
+}
+
+// 旧版本没有记录启动时间 只有进程不在宿主机的mount namespace中时才认为是容器的init进程
+func legacyStartTime(pid string) (uint64, bool) {
+	if _, err := strconv.Atoi(pid); err != nil {
+		return 0, false
+	}
+	hostNs, err := os.Readlink("/proc/self/ns/mnt")
+	if err != nil {
+		return 0, false
+	}
+	if ns, err := os.Readlink(path.Join("/proc", pid, "ns/mnt")); err != nil || ns == hostNs {
+		return 0, false
+	}
+	data, err := os.ReadFile(path.Join("/proc", pid, "stat"))
+	if err != nil {
+		return 0, false
+	}
+	idx := strings.LastIndexByte(string(data), ')')
+	if idx < 0 {
+		return 0, false
+	}
+	fields := strings.Fields(string(data[idx+1:]))
+	if len(fields) < 20 {
+		return 0, false
+	}
+	startTime, err := strconv.ParseUint(fields[19], 10, 64)
+	return startTime, err == nil
+}
+
+func isHexId(id string) bool {
+	if len(id) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil && strings.ToLower(id) == id
+}
+
+func unmarshalField(record map[string]json.RawMessage, key string, v any) error {
+	raw, ok := record[key]
+	if !ok || string(raw) == "null" {
+		return nil
+	}
+	return errors.Wrapf(json.Unmarshal(raw, v), "invalid field %s", key)
+}
+
+func marshalField(record map[string]json.RawMessage, key string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	record[key] = raw
+	return nil
+}
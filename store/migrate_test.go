@@ -0,0 +1,40 @@
+package store
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMigrateRecordToV1(t *testing.T) {
+	record := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(`{"id":"0123456789","name":"web","status":"stopped","pid":"","cg":{"Path":"web"},"exitCode":-1}`), &record); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateRecordToV1(record); err != nil {
+		t.Fatal(err)
+	}
+	var id string
+	if err := json.Unmarshal(record["id"], &id); err != nil || !isHexId(id) {
+		t.Errorf("id not migrated: %s", record["id"])
+	}
+	// 根资源组下的旧容器没有父资源组
+	if _, ok := record["cgParent"]; ok {
+		t.Errorf("unexpected cgParent %s", record["cgParent"])
+	}
+	if string(record["exitCode"]) != "-1" {
+		t.Errorf("other fields changed: %s", record["exitCode"])
+	}
+
+	// 已经是新格式的记录保持不变
+	newId := id
+	record["cg"] = json.RawMessage(`{"Path":"mydocker/web"}`)
+	if err := migrateRecordToV1(record); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(record["id"], &id); err != nil || id != newId {
+		t.Errorf("id changed again: %s", record["id"])
+	}
+	if string(record["cgParent"]) != `"mydocker"` {
+		t.Errorf("cgParent not backfilled: %s", record["cgParent"])
+	}
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/kehaha-5/go-low-level-container/common"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultLockDir   string = "locks"
+	defaultLockExt   string = ".lock"
+	globalLockName   string = "global"
+	defaultTmpSuffix string = ".tmp"
+)
+
+var (
+	root string = common.ROOTPATH
+)
+
+// 基于flock的文件锁 进程退出后内核会自动释放 不会因为崩溃留下死锁
+type FileLock struct {
+	f *os.File
+}
+
+func getLockPath(name string) string {
+	// 对象名称中可能有 / 例如 container/web
+	return path.Join(root, defaultLockDir, strings.ReplaceAll(name, "/", "_")+defaultLockExt)
+}
+
+// 获取名为name的排他锁 会一直阻塞到拿到锁为止
+func Lock(name string) (*FileLock, error) {
+	lockPath := getLockPath(name)
+	if err := os.MkdirAll(path.Dir(lockPath), 0755); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "fail to lock %s", name)
+	}
+	return &FileLock{f: f}, nil
+}
+
+// 全局锁 用于需要同时修改多个对象的操作 比如迁移
+func LockGlobal() (*FileLock, error) {
+	return Lock(globalLockName)
+}
+
+func (t *FileLock) Unlock() error {
+	if t == nil || t.f == nil {
+		return nil
+	}
+	defer t.f.Close()
+	return errors.WithStack(syscall.Flock(int(t.f.Fd()), syscall.LOCK_UN))
+}
+
+// 持有name锁的情况下执行fn
+func WithLock(name string, fn func() error) error {
+	lock, err := Lock(name)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	return fn()
+}
+
+// 先写入同目录下的临时文件并落盘 再rename覆盖目标文件
+// rename是原子的 崩溃时文件要么是旧内容要么是新内容 不会出现写了一半的文件
+func WriteFileAtomic(filePath string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(filePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(filePath)+".*"+defaultTmpSuffix)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.WithStack(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return errors.WithStack(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return errors.WithStack(err)
+	}
+	return syncDir(dir)
+}
+
+// rename之后目录项也要落盘
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer d.Close()
+	return errors.WithStack(d.Sync())
+}
+
+// WriteFileAtomic 写入过程中产生的临时文件 遍历目录时需要跳过
+func IsTempFile(name string) bool {
+	return strings.HasSuffix(name, defaultTmpSuffix)
+}
+
+// 原子的写入json文件
+func WriteJSON(filePath string, v any) error {
+	jsonStr, err := json.Marshal(v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return WriteFileAtomic(filePath, jsonStr, 0644)
+}
+
+// 读取json文件 文件不存在时返回false
+func ReadJSON(filePath string, v any) (bool, error) {
+	jsonStr, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, errors.WithStack(err)
+	}
+	if err := json.Unmarshal(jsonStr, v); err != nil {
+		return true, errors.Wrapf(err, "fail to unmarshal json %s", filePath)
+	}
+	return true, nil
+}
+
+// 在name锁中完成 读取-修改-写入 fn返回错误时不会写入
+func UpdateJSON(name string, filePath string, v any, fn func() error) error {
+	return WithLock(name, func() error {
+		if _, err := ReadJSON(filePath, v); err != nil {
+			return err
+		}
+		if err := fn(); err != nil {
+			return err
+		}
+		return WriteJSON(filePath, v)
+	})
+}